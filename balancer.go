@@ -0,0 +1,468 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoEndpoint = errors.New("httpclient: no endpoint")
+
+// BalancerStrategy is the choice of the endpoint of a request
+type BalancerStrategy int
+
+const (
+	// RoundRobin chooses the endpoints in turn
+	RoundRobin BalancerStrategy = iota
+	// WeightedRoundRobin chooses the endpoints in turn proportionally to their weight
+	WeightedRoundRobin
+	// LeastOutstanding chooses the endpoint with the fewest requests in progress
+	LeastOutstanding
+	// PowerOfTwoChoices chooses the endpoint with the fewest requests in progress
+	// between two random endpoints
+	PowerOfTwoChoices
+	// ConsistentHash chooses the endpoint from the hash of a key of the request
+	ConsistentHash
+)
+
+// Number of points of an endpoint of weight 1 on the hash ring
+const hashRingReplicas = 100
+
+// Endpoint is a replica of the service
+type Endpoint struct {
+	// URL of the replica like http://10.0.0.1:8080, without path and query
+	// which are the ones of the base URL of the client
+	URL string `json:"url"`
+	// Weight of the replica for WeightedRoundRobin and ConsistentHash, 1 by default
+	Weight int `json:"weight,omitempty"`
+}
+
+// LoadBalancerConfig is the configuration of a LoadBalancer
+type LoadBalancerConfig struct {
+	Strategy BalancerStrategy
+	// HashKey returns the key of ConsistentHash, the path of the URL by default
+	HashKey func(r *http.Request) string
+	// Resolver discovers the endpoints, they are refreshed in the background until Close
+	Resolver Resolver
+
+	// MaxFailures is the number of consecutive errors and 5xx responses ejecting an endpoint,
+	// 5 by default and the ejection is disabled when negative
+	MaxFailures int
+	// EjectionTime of the first ejection, multiplied by the number of ejections, 30 seconds by default
+	EjectionTime time.Duration
+	// MaxEjectionPercent is the maximum percentage of endpoints ejected, 50 by default
+	MaxEjectionPercent int
+
+	// HealthCheckPath is requested on every endpoint, the active health checks are disabled when empty
+	HealthCheckPath string
+	// HealthCheckInterval between two checks, 10 seconds by default
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout of a check, 2 seconds by default
+	HealthCheckTimeout time.Duration
+}
+
+// LoadBalancer balances the requests of a Client between the endpoints
+type LoadBalancer struct {
+	config    LoadBalancerConfig
+	endpoints []*endpoint
+	ring      []ringPoint
+
+	counter uint64
+	mu      sync.Mutex
+	random  *rand.Rand
+
+	start  sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// endpoint is the state of an Endpoint
+type endpoint struct {
+	url    *url.URL
+	weight int
+
+	inflight int64
+	// current weight of the smooth weighted round robin
+	current int
+
+	// Guarded by the mutex of the LoadBalancer
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+	unhealthy    bool
+}
+
+// ringPoint is a point of an endpoint on the hash ring
+type ringPoint struct {
+	hash     uint32
+	endpoint *endpoint
+}
+
+// NewLoadBalancer returns a load balancer of the endpoints, the health checks
+// start with the Client and stop with Close. The endpoints are replaced by
+// the ones of the Resolver of the configuration when it's set.
+func NewLoadBalancer(endpoints []Endpoint, config LoadBalancerConfig) (*LoadBalancer, error) {
+	if len(endpoints) == 0 && config.Resolver == nil {
+		return nil, ErrNoEndpoint
+	}
+	if config.MaxFailures == 0 {
+		config.MaxFailures = 5
+	}
+	if config.EjectionTime <= 0 {
+		config.EjectionTime = 30 * time.Second
+	}
+	if config.MaxEjectionPercent == 0 {
+		config.MaxEjectionPercent = 50
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = 10 * time.Second
+	}
+	if config.HealthCheckTimeout <= 0 {
+		config.HealthCheckTimeout = 2 * time.Second
+	}
+	if config.HashKey == nil {
+		config.HashKey = func(r *http.Request) string { return r.URL.Path }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lb := &LoadBalancer{
+		config: config,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if err := lb.setEndpoints(endpoints); err != nil {
+		cancel()
+		return nil, err
+	}
+	if config.Resolver != nil {
+		// The static endpoints are kept when the first resolution fails
+		next, err := lb.resolve(ctx)
+		if err != nil && len(endpoints) == 0 {
+			cancel()
+			return nil, err
+		}
+		go lb.discover(ctx, next)
+	}
+	return lb, nil
+}
+
+// setEndpoints replaces the endpoints, the state of the endpoints kept is preserved
+func (lb *LoadBalancer) setEndpoints(endpoints []Endpoint) error {
+	lb.mu.Lock()
+	previous := make(map[string]*endpoint, len(lb.endpoints))
+	for _, e := range lb.endpoints {
+		previous[e.url.String()] = e
+	}
+	lb.mu.Unlock()
+
+	var (
+		states []*endpoint
+		ring   []ringPoint
+	)
+	for _, e := range endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("httpclient: invalid endpoint %q", e.URL)
+		}
+		// Only the scheme and the host replace the ones of the requests
+		if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("httpclient: endpoint %q with a path, the path belongs to the base URL", e.URL)
+		}
+		weight := e.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		ep, ok := previous[u.String()]
+		if !ok {
+			ep = &endpoint{url: u}
+		}
+		states = append(states, ep)
+		for i := 0; i < hashRingReplicas*weight; i++ {
+			ring = append(ring, ringPoint{hash: hashKey(u.Host + "#" + strconv.Itoa(i)), endpoint: ep})
+		}
+		lb.mu.Lock()
+		ep.weight = weight
+		lb.mu.Unlock()
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.endpoints = states
+	lb.ring = ring
+	return nil
+}
+
+// Close stops the health checks and the discovery of the endpoints
+func (lb *LoadBalancer) Close() {
+	lb.cancel()
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// available returns the endpoints not ejected and healthy,
+// all the endpoints when none is available, with the hash ring
+func (lb *LoadBalancer) available() ([]*endpoint, []ringPoint) {
+	now := time.Now()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	available := make([]*endpoint, 0, len(lb.endpoints))
+	for _, e := range lb.endpoints {
+		if !e.unhealthy && !now.Before(e.ejectedUntil) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		return lb.endpoints, lb.ring
+	}
+	return available, lb.ring
+}
+
+// pick chooses the endpoint of the request
+func (lb *LoadBalancer) pick(r *http.Request) (*endpoint, error) {
+	available, ring := lb.available()
+	switch len(available) {
+	case 0:
+		return nil, ErrNoEndpoint
+	case 1:
+		return available[0], nil
+	}
+	switch lb.config.Strategy {
+	case WeightedRoundRobin:
+		// Smooth weighted round robin of nginx
+		lb.mu.Lock()
+		defer lb.mu.Unlock()
+		total := 0
+		var best *endpoint
+		for _, e := range available {
+			e.current += e.weight
+			total += e.weight
+			if best == nil || e.current > best.current {
+				best = e
+			}
+		}
+		best.current -= total
+		return best, nil
+	case LeastOutstanding:
+		start := int(atomic.AddUint64(&lb.counter, 1))
+		best := available[start%len(available)]
+		for i := 1; i < len(available); i++ {
+			e := available[(start+i)%len(available)]
+			if atomic.LoadInt64(&e.inflight) < atomic.LoadInt64(&best.inflight) {
+				best = e
+			}
+		}
+		return best, nil
+	case PowerOfTwoChoices:
+		lb.mu.Lock()
+		i := lb.random.Intn(len(available))
+		j := lb.random.Intn(len(available) - 1)
+		lb.mu.Unlock()
+		if j >= i {
+			j++
+		}
+		if atomic.LoadInt64(&available[j].inflight) < atomic.LoadInt64(&available[i].inflight) {
+			return available[j], nil
+		}
+		return available[i], nil
+	case ConsistentHash:
+		isAvailable := make(map[*endpoint]bool, len(available))
+		for _, e := range available {
+			isAvailable[e] = true
+		}
+		h := hashKey(lb.config.HashKey(r))
+		start := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+		// The next available endpoint clockwise on the ring
+		for i := 0; i < len(ring); i++ {
+			point := ring[(start+i)%len(ring)]
+			if isAvailable[point.endpoint] {
+				return point.endpoint, nil
+			}
+		}
+		return available[0], nil
+	default:
+		return available[int(atomic.AddUint64(&lb.counter, 1)-1)%len(available)], nil
+	}
+}
+
+// report records the result of a request for the outlier ejection
+func (lb *LoadBalancer) report(e *endpoint, success bool) {
+	if lb.config.MaxFailures < 0 {
+		return
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if success {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures < lb.config.MaxFailures {
+		return
+	}
+
+	// Keep enough endpoints to serve the requests
+	now := time.Now()
+	ejected := 0
+	for _, other := range lb.endpoints {
+		if now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > lb.config.MaxEjectionPercent*len(lb.endpoints) {
+		return
+	}
+	e.failures = 0
+	e.ejections++
+	e.ejectedUntil = now.Add(lb.config.EjectionTime * time.Duration(e.ejections))
+}
+
+// healthCheck requests the health check path of all the endpoints periodically
+func (lb *LoadBalancer) healthCheck(ctx context.Context, transport http.RoundTripper) {
+	client := &http.Client{Transport: transport, Timeout: lb.config.HealthCheckTimeout}
+	ticker := time.NewTicker(lb.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		lb.mu.Lock()
+		endpoints := lb.endpoints
+		lb.mu.Unlock()
+		var wg sync.WaitGroup
+		for _, e := range endpoints {
+			wg.Add(1)
+			go func(e *endpoint) {
+				defer wg.Done()
+				healthy := false
+				u := e.url.JoinPath(lb.config.HealthCheckPath)
+				r, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+				if err == nil {
+					if resp, err := client.Do(r); err == nil {
+						_, _ = io.Copy(io.Discard, resp.Body)
+						_ = resp.Body.Close()
+						healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+					}
+				}
+				lb.mu.Lock()
+				e.unhealthy = !healthy
+				if healthy && e.ejections > 0 && !time.Now().Before(e.ejectedUntil) {
+					e.ejections = 0
+				}
+				lb.mu.Unlock()
+			}(e)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithLoadBalancer is to send the requests to the endpoints of the load balancer,
+// the host of the base URL is replaced by the host of an endpoint on every request.
+//
+// For Example:
+//
+//	lb, err := httpclient.NewLoadBalancer([]httpclient.Endpoint{
+//		{URL: "http://10.0.0.1:8080"},
+//		{URL: "http://10.0.0.2:8080"},
+//	}, httpclient.LoadBalancerConfig{
+//		Strategy:        httpclient.PowerOfTwoChoices,
+//		HealthCheckPath: "/healthz",
+//	})
+//	defer lb.Close()
+//	client, err := httpclient.NewClient("http://orders/api/v1", httpclient.WithLoadBalancer(lb))
+func WithLoadBalancer(lb *LoadBalancer) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.balancer = lb
+	}
+}
+
+// applyBalancer wraps the transport to rewrite the host of the requests
+func (cc *clientConfig) applyBalancer(baseURL string) error {
+	lb := cc.balancer
+	if lb == nil {
+		return nil
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	transport := cc.Transport
+	if lb.config.HealthCheckPath != "" {
+		lb.start.Do(func() {
+			go lb.healthCheck(lb.ctx, transport)
+		})
+	}
+	cc.Transport = &balancerTransport{lb: lb, host: base.Host, next: transport}
+	return nil
+}
+
+// balancerTransport sends the requests of the host to the endpoints
+type balancerTransport struct {
+	lb   *LoadBalancer
+	host string
+	next http.RoundTripper
+}
+
+func (t *balancerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// The redirects to other hosts are not balanced
+	if r.URL.Host != t.host {
+		return t.next.RoundTrip(r)
+	}
+	e, err := t.lb.pick(r)
+	if err != nil {
+		return nil, err
+	}
+	balanced := r.Clone(r.Context())
+	balanced.URL.Scheme = e.url.Scheme
+	balanced.URL.Host = e.url.Host
+	balanced.Host = ""
+
+	atomic.AddInt64(&e.inflight, 1)
+	resp, err := t.next.RoundTrip(balanced)
+	if err != nil {
+		atomic.AddInt64(&e.inflight, -1)
+		t.lb.report(e, false)
+		return nil, err
+	}
+	t.lb.report(e, resp.StatusCode < http.StatusInternalServerError)
+	// The request is outstanding until its body is closed
+	resp.Body = &inflightBody{ReadCloser: resp.Body, inflight: &e.inflight}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the transport
+func (t *balancerTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// inflightBody decrements the requests in progress of an endpoint when it's closed
+type inflightBody struct {
+	io.ReadCloser
+	inflight *int64
+	once     sync.Once
+}
+
+func (b *inflightBody) Close() error {
+	b.once.Do(func() { atomic.AddInt64(b.inflight, -1) })
+	return b.ReadCloser.Close()
+}
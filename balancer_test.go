@@ -0,0 +1,201 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithLoadBalancer(t *testing.T) {
+	// newReplica returns a server counting its requests
+	newReplica := func(status *int32, hits *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				w.WriteHeader(int(atomic.LoadInt32(status)))
+				return
+			}
+			atomic.AddInt32(hits, 1)
+			w.WriteHeader(int(atomic.LoadInt32(status)))
+		}))
+	}
+
+	tests := []struct {
+		name     string
+		config   LoadBalancerConfig
+		weights  []int
+		statuses []int32
+		paths    []string
+		wantHits []int32
+	}{
+		{
+			name:     "ok case - round robin",
+			statuses: []int32{204, 204, 204},
+			paths:    []string{"/a", "/a", "/a", "/a", "/a", "/a"},
+			wantHits: []int32{2, 2, 2},
+		},
+		{
+			name:     "ok case - weighted round robin",
+			config:   LoadBalancerConfig{Strategy: WeightedRoundRobin},
+			weights:  []int{3, 1},
+			statuses: []int32{204, 204},
+			paths:    []string{"/a", "/a", "/a", "/a", "/a", "/a", "/a", "/a"},
+			wantHits: []int32{6, 2},
+		},
+		{
+			name:     "ok case - consistent hashing",
+			config:   LoadBalancerConfig{Strategy: ConsistentHash},
+			statuses: []int32{204, 204, 204},
+			paths:    []string{"/orders/1", "/orders/1", "/orders/1", "/orders/1"},
+			wantHits: []int32{-4},
+		},
+		{
+			name:     "ok case - failing endpoint ejected",
+			config:   LoadBalancerConfig{MaxFailures: 2},
+			statuses: []int32{500, 204},
+			paths:    []string{"/a", "/a", "/a", "/a", "/a", "/a", "/a", "/a"},
+			wantHits: []int32{2, 6},
+		},
+		{
+			name:     "ok case - least outstanding",
+			config:   LoadBalancerConfig{Strategy: LeastOutstanding},
+			statuses: []int32{204, 204},
+			paths:    []string{"/a", "/a", "/a", "/a"},
+			wantHits: []int32{2, 2},
+		},
+		{
+			name:     "ok case - power of two choices",
+			config:   LoadBalancerConfig{Strategy: PowerOfTwoChoices},
+			statuses: []int32{204, 204},
+			paths:    []string{"/a", "/a", "/a", "/a"},
+			wantHits: []int32{-4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := tt.statuses
+			hits := make([]int32, len(statuses))
+			endpoints := make([]Endpoint, len(statuses))
+			for i := range statuses {
+				s := newReplica(&statuses[i], &hits[i])
+				defer s.Close()
+				endpoints[i] = Endpoint{URL: s.URL}
+				if tt.weights != nil {
+					endpoints[i].Weight = tt.weights[i]
+				}
+			}
+			lb, err := NewLoadBalancer(endpoints, tt.config)
+			if err != nil {
+				t.Fatalf("NewLoadBalancer() error = %v", err)
+			}
+			defer lb.Close()
+			c, err := NewClient("http://service", WithLoadBalancer(lb))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			for _, path := range tt.paths {
+				_, _ = c.Get(context.Background(), path, nil, nil)
+			}
+
+			// A negative count is the total of the hits on any endpoints,
+			// and all of them on a single endpoint for consistent hashing
+			if len(tt.wantHits) == 1 && tt.wantHits[0] < 0 {
+				total, most := int32(0), int32(0)
+				for _, h := range hits {
+					total += h
+					if h > most {
+						most = h
+					}
+				}
+				if total != -tt.wantHits[0] {
+					t.Errorf("hits = %v, want %v in total", hits, -tt.wantHits[0])
+				}
+				if tt.config.Strategy == ConsistentHash && most != total {
+					t.Errorf("hits = %v, want on a single endpoint", hits)
+				}
+				return
+			}
+			for i, want := range tt.wantHits {
+				if hits[i] != want {
+					t.Errorf("hits = %v, want %v", hits, tt.wantHits)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBalancer_HealthCheck(t *testing.T) {
+	statuses := []int32{http.StatusServiceUnavailable, http.StatusNoContent}
+	hits := make([]int32, len(statuses))
+	endpoints := make([]Endpoint, len(statuses))
+	for i := range statuses {
+		status, count := &statuses[i], &hits[i]
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				atomic.AddInt32(count, 1)
+			}
+			w.WriteHeader(int(atomic.LoadInt32(status)))
+		}))
+		defer s.Close()
+		endpoints[i] = Endpoint{URL: s.URL}
+	}
+
+	lb, err := NewLoadBalancer(endpoints, LoadBalancerConfig{
+		HealthCheckPath:     "/healthz",
+		HealthCheckInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewLoadBalancer() error = %v", err)
+	}
+	defer lb.Close()
+	c, err := NewClient("http://service", WithLoadBalancer(lb))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// wait returns when only the endpoint i is available
+	wait := func(i int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if available, _ := lb.available(); len(available) == 1 && available[0] == lb.endpoints[i] {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("endpoint %d is not the only one available", i)
+	}
+	wait(1)
+	for i := 0; i < 4; i++ {
+		if _, err := c.Get(context.Background(), "/", nil, nil); err != nil {
+			t.Fatalf("Client.Get() error = %v", err)
+		}
+	}
+	if hits[0] != 0 || hits[1] != 4 {
+		t.Errorf("hits = %v, want [0 4]", hits)
+	}
+
+	// The endpoints recover and fail with the health checks
+	atomic.StoreInt32(&statuses[0], http.StatusNoContent)
+	atomic.StoreInt32(&statuses[1], http.StatusInternalServerError)
+	wait(0)
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	if _, err := NewLoadBalancer(nil, LoadBalancerConfig{}); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("NewLoadBalancer() error = %v, want %v", err, ErrNoEndpoint)
+	}
+	if _, err := NewLoadBalancer([]Endpoint{{URL: "10.0.0.1"}}, LoadBalancerConfig{}); err == nil {
+		t.Errorf("NewLoadBalancer() without scheme error = %v", err)
+	}
+	for _, endpoint := range []string{"http://10.0.0.1:8080/svc", "http://10.0.0.1:8080/?zone=a"} {
+		if _, err := NewLoadBalancer([]Endpoint{{URL: endpoint}}, LoadBalancerConfig{}); err == nil {
+			t.Errorf("NewLoadBalancer() with endpoint %s error = %v", endpoint, err)
+		}
+	}
+	if _, err := NewLoadBalancer([]Endpoint{{URL: "http://10.0.0.1:8080/"}}, LoadBalancerConfig{}); err != nil {
+		t.Errorf("NewLoadBalancer() with endpoint without path error = %v", err)
+	}
+}
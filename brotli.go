@@ -0,0 +1,986 @@
+package httpclient
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Decoder of the Brotli streams of RFC 7932
+
+var errBrotliCorrupted = errors.New("httpclient: corrupted brotli stream")
+
+// brotliDictionary is the static dictionary of RFC 7932 appendix A
+//
+//go:embed brotli_dictionary.bin
+var brotliDictionary []byte
+
+// Bytes decoded at most by a call of next
+const brotliChunk = 1 << 16
+
+// Number of bits of the index of the words of the dictionary by length, and their offsets
+var (
+	brotliDictionaryBits = [25]uint8{
+		0, 0, 0, 0, 10, 10, 11, 11, 10, 10, 10, 10, 10, 9, 9, 8, 7, 7, 8, 7, 7, 6, 6, 5, 5,
+	}
+	brotliDictionaryOffsets = func() (offsets [25]int) {
+		for l := 4; l < 24; l++ {
+			offsets[l+1] = offsets[l] + l<<brotliDictionaryBits[l]
+		}
+		return offsets
+	}()
+)
+
+// Transforms of the words of the dictionary of RFC 7932 appendix B, the kinds
+// 1 to 9 omit the last bytes and 12 to 20 the first bytes of the word
+const (
+	brotliUppercaseFirst = 10
+	brotliUppercaseAll   = 11
+)
+
+var brotliTransforms = [121]struct {
+	prefix string
+	kind   int
+	suffix string
+}{
+	{"", 0, ""}, {"", 0, " "}, {" ", 0, " "},
+	{"", 12, ""}, {"", 10, " "}, {"", 0, " the "},
+	{" ", 0, ""}, {"s ", 0, " "}, {"", 0, " of "},
+	{"", 10, ""}, {"", 0, " and "}, {"", 13, ""},
+	{"", 1, ""}, {", ", 0, " "}, {"", 0, ", "},
+	{" ", 10, " "}, {"", 0, " in "}, {"", 0, " to "},
+	{"e ", 0, " "}, {"", 0, "\""}, {"", 0, "."},
+	{"", 0, "\">"}, {"", 0, "\n"}, {"", 3, ""},
+	{"", 0, "]"}, {"", 0, " for "}, {"", 14, ""},
+	{"", 2, ""}, {"", 0, " a "}, {"", 0, " that "},
+	{" ", 10, ""}, {"", 0, ". "}, {".", 0, ""},
+	{" ", 0, ", "}, {"", 15, ""}, {"", 0, " with "},
+	{"", 0, "'"}, {"", 0, " from "}, {"", 0, " by "},
+	{"", 16, ""}, {"", 17, ""}, {" the ", 0, ""},
+	{"", 4, ""}, {"", 0, ". The "}, {"", 11, ""},
+	{"", 0, " on "}, {"", 0, " as "}, {"", 0, " is "},
+	{"", 7, ""}, {"", 1, "ing "}, {"", 0, "\n\t"},
+	{"", 0, ":"}, {" ", 0, ". "}, {"", 0, "ed "},
+	{"", 20, ""}, {"", 18, ""}, {"", 6, ""},
+	{"", 0, "("}, {"", 10, ", "}, {"", 8, ""},
+	{"", 0, " at "}, {"", 0, "ly "}, {" the ", 0, " of "},
+	{"", 5, ""}, {"", 9, ""}, {" ", 10, ", "},
+	{"", 10, "\""}, {".", 0, "("}, {"", 11, " "},
+	{"", 10, "\">"}, {"", 0, "=\""}, {" ", 0, "."},
+	{".com/", 0, ""}, {" the ", 0, " of the "}, {"", 10, "'"},
+	{"", 0, ". This "}, {"", 0, ","}, {".", 0, " "},
+	{"", 10, "("}, {"", 10, "."}, {"", 0, " not "},
+	{" ", 0, "=\""}, {"", 0, "er "}, {" ", 11, " "},
+	{"", 0, "al "}, {" ", 11, ""}, {"", 0, "='"},
+	{"", 11, "\""}, {"", 10, ". "}, {" ", 0, "("},
+	{"", 0, "ful "}, {" ", 10, ". "}, {"", 0, "ive "},
+	{"", 0, "less "}, {"", 11, "'"}, {"", 0, "est "},
+	{" ", 10, "."}, {"", 11, "\">"}, {" ", 0, "='"},
+	{"", 10, ","}, {"", 0, "ize "}, {"", 11, "."},
+	{"\u00a0", 0, ""}, {" ", 0, ","}, {"", 10, "=\""},
+	{"", 11, "=\""}, {"", 0, "ous "}, {"", 11, ", "},
+	{"", 10, "='"}, {" ", 10, ","}, {" ", 11, "=\""},
+	{" ", 11, ", "}, {"", 11, ","}, {"", 11, "("},
+	{"", 11, ". "}, {" ", 11, "."}, {"", 11, "='"},
+	{" ", 11, ". "}, {" ", 10, "=\""}, {" ", 11, "='"},
+	{" ", 10, "='"},
+}
+
+// Code lengths of the code of the code lengths, by the next 4 bits
+var (
+	brotliCodeLengthOrder = [18]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	brotliCodeLengthValue = [16]uint8{0, 4, 3, 2, 0, 4, 3, 1, 0, 4, 3, 2, 0, 4, 3, 5}
+	brotliCodeLengthBits  = [16]uint8{2, 2, 2, 3, 2, 2, 2, 4, 2, 2, 2, 3, 2, 2, 2, 4}
+)
+
+// Baselines and numbers of extra bits of the block counts, insert lengths and copy lengths
+var (
+	brotliBlockCountBase = [26]int{
+		1, 5, 9, 13, 17, 25, 33, 41, 49, 65, 81, 97, 113, 145, 177, 209,
+		241, 305, 369, 497, 753, 1265, 2289, 4337, 8433, 16625,
+	}
+	brotliBlockCountBits = [26]uint8{
+		2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 6, 6, 7, 8, 9, 10, 11, 12, 13, 24,
+	}
+	brotliInsertBase = [24]int{
+		0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98,
+		130, 194, 322, 578, 1090, 2114, 6210, 22594,
+	}
+	brotliInsertBits = [24]uint8{
+		0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24,
+	}
+	brotliCopyBase = [24]int{
+		2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54,
+		70, 102, 134, 198, 326, 582, 1094, 2118,
+	}
+	brotliCopyBits = [24]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24,
+	}
+	// First insert and copy length codes of the cells of the insert-and-copy codes
+	brotliCommandCells = [11][2]int{
+		{0, 0}, {0, 8}, {0, 0}, {0, 8}, {8, 0}, {8, 8}, {0, 16}, {16, 0}, {8, 16}, {16, 8}, {16, 16},
+	}
+	// Index in the last distances and offset of the distance codes 0 to 15
+	brotliDistanceIndex  = [16]int{0, 1, 2, 3, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1}
+	brotliDistanceOffset = [16]int{0, 0, 0, 0, -1, 1, -2, 2, -3, 3, -1, 1, -2, 2, -3, 3}
+)
+
+// Context lookup tables of the UTF8 context mode
+var (
+	brotliUTF8Lut0 = [256]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 4, 4, 0, 0, 4, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		8, 12, 16, 12, 12, 20, 12, 16, 24, 28, 12, 12, 32, 12, 36, 12,
+		44, 44, 44, 44, 44, 44, 44, 44, 44, 44, 32, 32, 24, 40, 28, 12,
+		12, 48, 52, 52, 52, 48, 52, 52, 52, 48, 52, 52, 52, 52, 52, 48,
+		52, 52, 52, 52, 52, 48, 52, 52, 52, 52, 52, 24, 12, 28, 12, 12,
+		12, 56, 60, 60, 60, 56, 60, 60, 60, 56, 60, 60, 60, 60, 60, 56,
+		60, 60, 60, 60, 60, 56, 60, 60, 60, 60, 60, 24, 12, 28, 12, 0,
+		0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1,
+		0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1,
+		0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1,
+		0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1,
+		2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3,
+		2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3,
+		2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3,
+		2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3,
+	}
+	brotliUTF8Lut1 = [256]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1,
+		1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
+		1, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+		3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 1, 1, 1, 1, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+	}
+)
+
+// brotliContext returns the context of a literal from the two previous bytes
+func brotliContext(mode uint8, p1, p2 byte) int {
+	switch mode {
+	case 0:
+		return int(p1 & 0x3F)
+	case 1:
+		return int(p1 >> 2)
+	case 2:
+		return int(brotliUTF8Lut0[p1] | brotliUTF8Lut1[p2])
+	}
+	return brotliSignedContext(p1)<<3 | brotliSignedContext(p2)
+}
+
+// brotliSignedContext is the lookup table of the signed context mode
+func brotliSignedContext(b byte) int {
+	switch {
+	case b == 0:
+		return 0
+	case b < 16:
+		return 1
+	case b < 64:
+		return 2
+	case b < 128:
+		return 3
+	case b < 192:
+		return 4
+	case b < 240:
+		return 5
+	case b < 255:
+		return 6
+	}
+	return 7
+}
+
+// States of the decoding of a command
+const (
+	brotliCommand = iota
+	brotliInsert
+	brotliDistance
+	brotliCopy
+)
+
+// brotliReader decodes a brotli stream
+type brotliReader struct {
+	br brotliBitReader
+
+	started    bool
+	last       bool
+	windowSize int
+	// Decoded bytes, the window followed by the bytes not read yet
+	hist    []byte
+	pos     int
+	written int
+
+	// Last distances, kept between the meta-blocks
+	distances [4]int
+	distIndex int
+
+	// State of the current meta-block
+	remaining    int
+	uncompressed bool
+	literal      brotliBlocks
+	command      brotliBlocks
+	distance     brotliBlocks
+	npostfix     uint
+	ndirect      int
+	contextModes []uint8
+	literalMap   []uint8
+	distanceMap  []uint8
+	literalCodes []*brotliHuffman
+	commandCodes []*brotliHuffman
+	distCodes    []*brotliHuffman
+
+	// State of the current command
+	state        int
+	insertLeft   int
+	copyLength   int
+	implicitZero bool
+	copyDistance int
+
+	err error
+}
+
+func newBrotliReader(r io.Reader) *brotliReader {
+	byteReader, ok := r.(io.ByteReader)
+	if !ok {
+		byteReader = bufio.NewReader(r)
+	}
+	return &brotliReader{
+		br:        brotliBitReader{r: byteReader},
+		distances: [4]int{16, 15, 11, 4},
+		distIndex: 4,
+	}
+}
+
+func (d *brotliReader) Read(p []byte) (int, error) {
+	for d.pos == len(d.hist) {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+		if d.err == nil {
+			d.err = d.br.err
+		}
+	}
+	n := copy(p, d.hist[d.pos:])
+	d.pos += n
+	return n, nil
+}
+
+// next decodes the next chunk of the stream
+func (d *brotliReader) next() error {
+	// Only the window is kept, all the bytes are read
+	if len(d.hist) > 2*d.windowSize+brotliChunk {
+		drop := len(d.hist) - d.windowSize
+		d.hist = d.hist[:copy(d.hist, d.hist[drop:])]
+		d.pos -= drop
+	}
+	if !d.started {
+		d.started = true
+		return d.readWindowBits()
+	}
+	if d.remaining == 0 {
+		if d.last {
+			return io.EOF
+		}
+		return d.readMetaBlockHeader()
+	}
+	if d.uncompressed {
+		n := d.remaining
+		if n > brotliChunk {
+			n = brotliChunk
+		}
+		for i := 0; i < n; i++ {
+			b, err := d.br.readByte()
+			if err != nil {
+				return err
+			}
+			d.hist = append(d.hist, b)
+		}
+		d.remaining -= n
+		d.written += n
+		return nil
+	}
+	return d.decodeCommands()
+}
+
+// readWindowBits reads the size of the window of the stream header
+func (d *brotliReader) readWindowBits() error {
+	br := &d.br
+	wbits := 16
+	if br.read(1) == 1 {
+		if n := br.read(3); n != 0 {
+			wbits = 17 + int(n)
+		} else if n := br.read(3); n == 1 {
+			return errors.New("httpclient: brotli large window not supported")
+		} else if n != 0 {
+			wbits = 8 + int(n)
+		} else {
+			wbits = 17
+		}
+	}
+	d.windowSize = 1<<wbits - 16
+	return nil
+}
+
+// readMetaBlockHeader reads the header of the next meta-block
+func (d *brotliReader) readMetaBlockHeader() error {
+	br := &d.br
+	d.last = br.read(1) == 1
+	if d.last && br.read(1) == 1 {
+		return nil
+	}
+	nibbles := int(br.read(2))
+	if nibbles == 3 {
+		// Metadata which is skipped
+		if br.read(1) != 0 {
+			return errBrotliCorrupted
+		}
+		size, n := 0, int(br.read(2))
+		for i := 0; i < n; i++ {
+			b := int(br.read(8))
+			if i == n-1 && n > 1 && b == 0 {
+				return errBrotliCorrupted
+			}
+			size |= b << (8 * i)
+		}
+		if n > 0 {
+			size++
+		}
+		if !br.align() {
+			return errBrotliCorrupted
+		}
+		for i := 0; i < size; i++ {
+			if _, err := br.readByte(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	nibbles += 4
+	length := 0
+	for i := 0; i < nibbles; i++ {
+		v := int(br.read(4))
+		if i == nibbles-1 && nibbles > 4 && v == 0 {
+			return errBrotliCorrupted
+		}
+		length |= v << (4 * i)
+	}
+	d.remaining = length + 1
+	d.uncompressed = !d.last && br.read(1) == 1
+	if d.uncompressed {
+		if !br.align() {
+			return errBrotliCorrupted
+		}
+		return nil
+	}
+
+	for _, blocks := range []*brotliBlocks{&d.literal, &d.command, &d.distance} {
+		if err := d.readBlocks(blocks); err != nil {
+			return err
+		}
+	}
+	d.npostfix = uint(br.read(2))
+	d.ndirect = int(br.read(4)) << d.npostfix
+	d.contextModes = make([]uint8, d.literal.types)
+	for i := range d.contextModes {
+		d.contextModes[i] = uint8(br.read(2))
+	}
+
+	literalTrees := br.readCount()
+	var err error
+	if d.literalMap, err = d.readContextMap(64*d.literal.types, literalTrees); err != nil {
+		return err
+	}
+	distanceTrees := br.readCount()
+	if d.distanceMap, err = d.readContextMap(4*d.distance.types, distanceTrees); err != nil {
+		return err
+	}
+	if d.literalCodes, err = d.readPrefixCodes(literalTrees, 256); err != nil {
+		return err
+	}
+	if d.commandCodes, err = d.readPrefixCodes(d.command.types, 704); err != nil {
+		return err
+	}
+	d.distCodes, err = d.readPrefixCodes(distanceTrees, 16+d.ndirect+48<<d.npostfix)
+	d.state = brotliCommand
+	return err
+}
+
+// decodeCommands decodes the commands of a compressed meta-block
+func (d *brotliReader) decodeCommands() error {
+	br := &d.br
+	for produced := 0; d.remaining > 0 && produced < brotliChunk && br.err == nil; {
+		switch d.state {
+		case brotliCommand:
+			d.nextBlock(&d.command)
+			code := d.commandCodes[d.command.current].decode(br)
+			cell := brotliCommandCells[code>>6]
+			insertCode, copyCode := cell[0]+(code>>3)&7, cell[1]+code&7
+			d.insertLeft = brotliInsertBase[insertCode] + int(br.read(uint(brotliInsertBits[insertCode])))
+			d.copyLength = brotliCopyBase[copyCode] + int(br.read(uint(brotliCopyBits[copyCode])))
+			d.implicitZero = code < 128
+			if d.insertLeft > d.remaining {
+				return errBrotliCorrupted
+			}
+			d.state = brotliInsert
+
+		case brotliInsert:
+			for ; d.insertLeft > 0 && br.err == nil; d.insertLeft-- {
+				d.nextBlock(&d.literal)
+				var p1, p2 byte
+				if n := len(d.hist); n > 1 {
+					p1, p2 = d.hist[n-1], d.hist[n-2]
+				} else if n == 1 {
+					p1 = d.hist[0]
+				}
+				context := brotliContext(d.contextModes[d.literal.current], p1, p2)
+				tree := d.literalMap[d.literal.current*64+context]
+				d.hist = append(d.hist, byte(d.literalCodes[tree].decode(br)))
+				d.remaining--
+				d.written++
+				produced++
+			}
+			// The copy of the last command of the meta-block is ignored
+			if d.remaining == 0 {
+				d.state = brotliCommand
+			} else {
+				d.state = brotliDistance
+			}
+
+		case brotliDistance:
+			distance, code := d.distances[(d.distIndex-1)&3], 0
+			if !d.implicitZero {
+				d.nextBlock(&d.distance)
+				context := d.copyLength - 2
+				if context > 3 {
+					context = 3
+				}
+				code = d.distCodes[d.distanceMap[d.distance.current*4+context]].decode(br)
+				distance = d.distanceOf(code)
+				if distance <= 0 {
+					return errBrotliCorrupted
+				}
+			}
+			maxDistance := d.written
+			if maxDistance > d.windowSize {
+				maxDistance = d.windowSize
+			}
+			if distance > maxDistance {
+				n, err := d.copyDictionaryWord(distance - maxDistance - 1)
+				if err != nil {
+					return err
+				}
+				produced += n
+				d.state = brotliCommand
+				continue
+			}
+			if code != 0 {
+				d.distances[d.distIndex&3] = distance
+				d.distIndex++
+			}
+			if d.copyLength > d.remaining {
+				return errBrotliCorrupted
+			}
+			d.copyDistance = distance
+			d.state = brotliCopy
+
+		case brotliCopy:
+			n := d.copyLength
+			if n > brotliChunk {
+				n = brotliChunk
+			}
+			start := len(d.hist) - d.copyDistance
+			if d.copyDistance >= n {
+				d.hist = append(d.hist, d.hist[start:start+n]...)
+			} else {
+				// The copy overlaps the bytes it writes
+				for i := 0; i < n; i++ {
+					d.hist = append(d.hist, d.hist[start+i])
+				}
+			}
+			d.copyLength -= n
+			d.remaining -= n
+			d.written += n
+			produced += n
+			if d.copyLength == 0 {
+				d.state = brotliCommand
+			}
+		}
+	}
+	return nil
+}
+
+// distanceOf returns the distance of a distance code
+func (d *brotliReader) distanceOf(code int) int {
+	if code < 16 {
+		return d.distances[(d.distIndex-1-brotliDistanceIndex[code])&3] + brotliDistanceOffset[code]
+	}
+	if code < 16+d.ndirect {
+		return code - 15
+	}
+	c := code - d.ndirect - 16
+	nbits := uint(1 + c>>(d.npostfix+1))
+	offset := (2+(c>>d.npostfix)&1)<<nbits - 4
+	return (offset+int(d.br.read(nbits)))<<d.npostfix + c&(1<<d.npostfix-1) + d.ndirect + 1
+}
+
+// copyDictionaryWord appends the transformed word of the static dictionary
+func (d *brotliReader) copyDictionaryWord(id int) (int, error) {
+	length := d.copyLength
+	if length < 4 || length > 24 {
+		return 0, errBrotliCorrupted
+	}
+	nbits := brotliDictionaryBits[length]
+	transform := id >> nbits
+	if transform >= len(brotliTransforms) {
+		return 0, errBrotliCorrupted
+	}
+	offset := brotliDictionaryOffsets[length] + (id&(1<<nbits-1))*length
+	word := brotliDictionary[offset : offset+length]
+
+	t := brotliTransforms[transform]
+	start := len(d.hist)
+	d.hist = append(d.hist, t.prefix...)
+	switch {
+	case t.kind >= 1 && t.kind <= 9:
+		if t.kind > len(word) {
+			word = nil
+		} else {
+			word = word[:len(word)-t.kind]
+		}
+	case t.kind >= 12:
+		if skip := t.kind - 11; skip > len(word) {
+			word = nil
+		} else {
+			word = word[skip:]
+		}
+	}
+	wordStart := len(d.hist)
+	d.hist = append(d.hist, word...)
+	switch t.kind {
+	case brotliUppercaseFirst:
+		brotliUppercase(d.hist[wordStart:])
+	case brotliUppercaseAll:
+		for p := d.hist[wordStart:]; len(p) > 0; {
+			p = p[brotliUppercase(p):]
+		}
+	}
+	d.hist = append(d.hist, t.suffix...)
+
+	n := len(d.hist) - start
+	if n > d.remaining {
+		return 0, errBrotliCorrupted
+	}
+	d.remaining -= n
+	d.written += n
+	return n, nil
+}
+
+// brotliUppercase changes the case of the first character in UTF-8 and returns its size
+func brotliUppercase(p []byte) int {
+	switch {
+	case p[0] < 0xC0:
+		if p[0] >= 'a' && p[0] <= 'z' {
+			p[0] ^= 32
+		}
+		return 1
+	case p[0] < 0xE0:
+		if len(p) < 2 {
+			return len(p)
+		}
+		p[1] ^= 32
+		return 2
+	}
+	if len(p) < 3 {
+		return len(p)
+	}
+	p[2] ^= 5
+	return 3
+}
+
+// brotliBlocks is the state of the blocks of a category
+type brotliBlocks struct {
+	types     int
+	typeCode  *brotliHuffman
+	countCode *brotliHuffman
+	current   int
+	previous  int
+	left      int
+}
+
+// readBlocks reads the block types and the first block count of a category
+func (d *brotliReader) readBlocks(b *brotliBlocks) error {
+	*b = brotliBlocks{types: d.br.readCount(), previous: 1, left: -1}
+	if b.types < 2 {
+		return nil
+	}
+	var err error
+	if b.typeCode, err = d.readPrefixCode(b.types + 2); err != nil {
+		return err
+	}
+	if b.countCode, err = d.readPrefixCode(26); err != nil {
+		return err
+	}
+	b.left = d.readBlockCount(b.countCode)
+	return nil
+}
+
+// readBlockCount reads the count of a block
+func (d *brotliReader) readBlockCount(code *brotliHuffman) int {
+	symbol := code.decode(&d.br)
+	return brotliBlockCountBase[symbol] + int(d.br.read(uint(brotliBlockCountBits[symbol])))
+}
+
+// nextBlock counts an item of the category, the block type switches at the end of the block
+func (d *brotliReader) nextBlock(b *brotliBlocks) {
+	if b.types < 2 {
+		return
+	}
+	if b.left == 0 {
+		var next int
+		switch code := b.typeCode.decode(&d.br); code {
+		case 0:
+			next = b.previous
+		case 1:
+			next = b.current + 1
+		default:
+			next = code - 2
+		}
+		if next >= b.types {
+			next -= b.types
+		}
+		b.previous, b.current = b.current, next
+		b.left = d.readBlockCount(b.countCode)
+	}
+	b.left--
+}
+
+// readContextMap reads a context map of the trees
+func (d *brotliReader) readContextMap(size int, trees int) ([]uint8, error) {
+	br := &d.br
+	m := make([]uint8, size)
+	if trees < 2 {
+		return m, nil
+	}
+	rleMax := 0
+	if br.read(1) == 1 {
+		rleMax = int(br.read(4)) + 1
+	}
+	code, err := d.readPrefixCode(trees + rleMax)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < size && br.err == nil; {
+		switch symbol := code.decode(br); {
+		case symbol == 0:
+			i++
+		case symbol <= rleMax:
+			// Run of zeros
+			i += 1<<symbol + int(br.read(uint(symbol)))
+			if i > size {
+				return nil, errBrotliCorrupted
+			}
+		default:
+			m[i] = uint8(symbol - rleMax)
+			i++
+		}
+	}
+	if br.read(1) == 1 {
+		// Inverse move-to-front transform
+		var mtf [256]uint8
+		for i := range mtf {
+			mtf[i] = uint8(i)
+		}
+		for i, index := range m {
+			value := mtf[index]
+			m[i] = value
+			copy(mtf[1:index+1], mtf[:index])
+			mtf[0] = value
+		}
+	}
+	return m, br.err
+}
+
+// readPrefixCodes reads n prefix codes of an alphabet
+func (d *brotliReader) readPrefixCodes(n int, alphabet int) ([]*brotliHuffman, error) {
+	codes := make([]*brotliHuffman, n)
+	for i := range codes {
+		var err error
+		if codes[i], err = d.readPrefixCode(alphabet); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// readPrefixCode reads a simple or complex prefix code of an alphabet
+func (d *brotliReader) readPrefixCode(alphabet int) (*brotliHuffman, error) {
+	br := &d.br
+	lengths := make([]uint8, alphabet)
+	skip := int(br.read(2))
+	if skip == 1 {
+		nbits := uint(bits.Len(uint(alphabet - 1)))
+		n := int(br.read(2)) + 1
+		symbols := make([]int, n)
+		for i := range symbols {
+			symbols[i] = int(br.read(nbits))
+			if symbols[i] >= alphabet {
+				return nil, errBrotliCorrupted
+			}
+			for _, s := range symbols[:i] {
+				if s == symbols[i] {
+					return nil, errBrotliCorrupted
+				}
+			}
+		}
+		switch n {
+		case 1:
+			lengths[symbols[0]] = 1
+		case 2:
+			lengths[symbols[0]], lengths[symbols[1]] = 1, 1
+		case 3:
+			lengths[symbols[0]], lengths[symbols[1]], lengths[symbols[2]] = 1, 2, 2
+		default:
+			if br.read(1) == 0 {
+				for _, s := range symbols {
+					lengths[s] = 2
+				}
+			} else {
+				lengths[symbols[0]], lengths[symbols[1]], lengths[symbols[2]], lengths[symbols[3]] = 1, 2, 3, 3
+			}
+		}
+		return newBrotliHuffman(lengths), br.err
+	}
+
+	// Code of the code lengths
+	var codeLengths [18]uint8
+	space, count := 32, 0
+	for i := skip; i < 18; i++ {
+		p := br.peek(4)
+		br.skip(uint(brotliCodeLengthBits[p]))
+		v := brotliCodeLengthValue[p]
+		codeLengths[brotliCodeLengthOrder[i]] = v
+		if v != 0 {
+			space -= 32 >> v
+			count++
+			if space <= 0 {
+				break
+			}
+		}
+	}
+	if count != 1 && space != 0 {
+		return nil, errBrotliCorrupted
+	}
+	codeLengthCode := newBrotliHuffman(codeLengths[:])
+
+	// Code lengths of the symbols with the repeat codes 16 and 17
+	previous, repeat, repeatLength := uint8(8), 0, uint8(0)
+	space = 1 << 15
+	for symbol := 0; symbol < alphabet && space > 0; {
+		if br.err != nil {
+			return nil, br.err
+		}
+		length := codeLengthCode.decode(br)
+		if length < 16 {
+			repeat = 0
+			lengths[symbol] = uint8(length)
+			symbol++
+			if length != 0 {
+				previous = uint8(length)
+				space -= 1 << 15 >> length
+			}
+			continue
+		}
+		extraBits, newLength := uint(2), previous
+		if length == 17 {
+			extraBits, newLength = 3, 0
+		}
+		if repeatLength != newLength {
+			repeat, repeatLength = 0, newLength
+		}
+		old := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extraBits
+		}
+		repeat += int(br.read(extraBits)) + 3
+		delta := repeat - old
+		if symbol+delta > alphabet {
+			return nil, errBrotliCorrupted
+		}
+		for i := 0; i < delta; i++ {
+			lengths[symbol] = repeatLength
+			symbol++
+		}
+		if repeatLength != 0 {
+			space -= delta << (15 - repeatLength)
+		}
+	}
+	if space != 0 {
+		return nil, errBrotliCorrupted
+	}
+	return newBrotliHuffman(lengths), br.err
+}
+
+// brotliHuffman decodes a canonical prefix code, the codes up to 8 bits with a table
+type brotliHuffman struct {
+	// single is the symbol of a code with only one symbol, decoded without bits
+	single int
+	// root has the symbol and length of the codes by the next 8 bits,
+	// the length is zero for the longer codes
+	root    [256]uint16
+	counts  [16]int
+	symbols []uint16
+}
+
+func newBrotliHuffman(lengths []uint8) *brotliHuffman {
+	h := &brotliHuffman{single: -1}
+	total := 0
+	for s, l := range lengths {
+		if l > 0 {
+			h.counts[l]++
+			total++
+			h.single = s
+		}
+	}
+	if total != 1 {
+		h.single = -1
+	}
+
+	// Symbols by length then by value
+	var offsets [16]int
+	for l := 1; l < 15; l++ {
+		offsets[l+1] = offsets[l] + h.counts[l]
+	}
+	h.symbols = make([]uint16, total)
+	for s, l := range lengths {
+		if l > 0 {
+			h.symbols[offsets[l]] = uint16(s)
+			offsets[l]++
+		}
+	}
+
+	code, index := 0, 0
+	for l := 1; l <= 8; l++ {
+		for i := 0; i < h.counts[l]; i++ {
+			entry := h.symbols[index]<<4 | uint16(l)
+			// The codes are read from their most significant bit
+			reversed := int(bits.Reverse16(uint16(code)) >> (16 - l))
+			for k := reversed; k < len(h.root); k += 1 << l {
+				h.root[k] = entry
+			}
+			code++
+			index++
+		}
+		code <<= 1
+	}
+	return h
+}
+
+// decode reads a symbol
+func (h *brotliHuffman) decode(br *brotliBitReader) int {
+	if h.single >= 0 {
+		return h.single
+	}
+	if entry := h.root[br.peek(8)]; entry&15 != 0 {
+		br.skip(uint(entry & 15))
+		return int(entry >> 4)
+	}
+	code, first, index := 0, 0, 0
+	for l := 1; l < 16; l++ {
+		code |= int(br.read(1))
+		if code-first < h.counts[l] {
+			return int(h.symbols[index+code-first])
+		}
+		index += h.counts[l]
+		first = (first + h.counts[l]) << 1
+		code <<= 1
+	}
+	if br.err == nil {
+		br.err = errBrotliCorrupted
+	}
+	return 0
+}
+
+// brotliBitReader reads a bitstream from its start, the least significant bits first
+type brotliBitReader struct {
+	r     io.ByteReader
+	value uint64
+	bits  uint
+	err   error
+}
+
+// peek returns the next n bits, completed with zeros at the end of the stream
+func (b *brotliBitReader) peek(n uint) int {
+	for b.bits < n {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			if err != io.EOF && b.err == nil {
+				b.err = err
+			}
+			break
+		}
+		b.value |= uint64(c) << b.bits
+		b.bits += 8
+	}
+	return int(b.value & (1<<n - 1))
+}
+
+func (b *brotliBitReader) skip(n uint) {
+	if n > b.bits {
+		b.value, b.bits = 0, 0
+		if b.err == nil {
+			b.err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	b.value >>= n
+	b.bits -= n
+}
+
+func (b *brotliBitReader) read(n uint) int {
+	if n == 0 {
+		return 0
+	}
+	v := b.peek(n)
+	b.skip(n)
+	return v
+}
+
+// readCount reads the number of block types or trees from 1 to 256
+func (b *brotliBitReader) readCount() int {
+	if b.read(1) == 0 {
+		return 1
+	}
+	n := uint(b.read(3))
+	return 1<<n + b.read(n) + 1
+}
+
+// align skips the bits up to the next byte, they must be zero
+func (b *brotliBitReader) align() bool {
+	n := b.bits % 8
+	return b.read(n) == 0
+}
+
+// readByte reads a byte of an aligned stream
+func (b *brotliBitReader) readByte() (byte, error) {
+	if b.bits >= 8 {
+		return byte(b.read(8)), nil
+	}
+	c, err := b.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
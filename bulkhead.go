@@ -0,0 +1,276 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrBulkheadFull = errors.New("httpclient: bulkhead full")
+
+// LimitAlgorithm is the algorithm of the concurrency limit of a Bulkhead
+type LimitAlgorithm int
+
+const (
+	// FixedLimit keeps MaxConcurrent as limit
+	FixedLimit LimitAlgorithm = iota
+	// AIMDLimit increases the limit additively on success and decreases it
+	// multiplicatively on an error, a 429 or a 503
+	AIMDLimit
+	// GradientLimit adapts the limit to the ratio between the long-term
+	// latency and the latency of the last request
+	GradientLimit
+)
+
+// Factor of the limit after a drop for AIMDLimit
+const aimdBackoff = 0.9
+
+// Duration without request before a partition and its limit are removed
+const bulkheadIdleTimeout = 5 * time.Minute
+
+// Bulkhead limits the requests in progress by partition
+type Bulkhead struct {
+	// MaxConcurrent requests in progress by partition, 10 by default.
+	// It's the initial limit of the adaptive algorithms.
+	MaxConcurrent int
+	// MaxQueue is the number of requests waiting by partition, they are rejected
+	// without waiting when it's zero
+	MaxQueue int
+	// QueueTimeout is the maximum wait of a request, the context of the request only when zero
+	QueueTimeout time.Duration
+	// Partition returns the partition of the request, the host by default.
+	// For Example, the method and the route to limit by route.
+	Partition func(r *http.Request) string
+
+	Algorithm LimitAlgorithm
+	// MinLimit and MaxLimit bound the adaptive limit, 1 and 200 by default
+	MinLimit int
+	MaxLimit int
+
+	mu         sync.Mutex
+	partitions map[string]*partition
+	lastSweep  time.Time
+}
+
+// partition is the state of a partition, guarded by the mutex of the Bulkhead
+type partition struct {
+	limit    float64
+	inflight int
+	queue    []*bulkheadWaiter
+
+	// Latencies of GradientLimit
+	longRTT float64
+	// lastUsed is the time of the last release
+	lastUsed time.Time
+}
+
+// bulkheadWaiter is a request waiting in the queue
+type bulkheadWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Limit returns the current limit of the partition
+func (b *Bulkhead) Limit(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int(b.partition(key).limit)
+}
+
+// partition returns the partition of the key, the lock must be held
+func (b *Bulkhead) partition(key string) *partition {
+	if b.partitions == nil {
+		b.partitions = map[string]*partition{}
+	}
+	p, ok := b.partitions[key]
+	if !ok {
+		b.sweep()
+		limit := b.MaxConcurrent
+		if limit <= 0 {
+			limit = 10
+		}
+		p = &partition{limit: float64(limit)}
+		b.partitions[key] = p
+	}
+	return p
+}
+
+// sweep removes the idle partitions once by idle timeout, the lock must be held
+func (b *Bulkhead) sweep() {
+	now := time.Now()
+	if now.Sub(b.lastSweep) < bulkheadIdleTimeout {
+		return
+	}
+	b.lastSweep = now
+	for key, p := range b.partitions {
+		if p.inflight == 0 && len(p.queue) == 0 && now.Sub(p.lastUsed) >= bulkheadIdleTimeout {
+			delete(b.partitions, key)
+		}
+	}
+}
+
+// bounds returns the bounds of the adaptive limit
+func (b *Bulkhead) bounds() (float64, float64) {
+	lower, upper := b.MinLimit, b.MaxLimit
+	if lower <= 0 {
+		lower = 1
+	}
+	if upper <= 0 {
+		upper = 200
+	}
+	return float64(lower), float64(upper)
+}
+
+// acquire waits for a place in the partition
+func (b *Bulkhead) acquire(ctx context.Context, key string) error {
+	b.mu.Lock()
+	p := b.partition(key)
+	if p.inflight < int(p.limit) && len(p.queue) == 0 {
+		p.inflight++
+		b.mu.Unlock()
+		return nil
+	}
+	if len(p.queue) >= b.MaxQueue {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %d requests in progress for %s", ErrBulkheadFull, p.inflight, key)
+	}
+	w := &bulkheadWaiter{ready: make(chan struct{})}
+	p.queue = append(p.queue, w)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.QueueTimeout > 0 {
+		timer := time.NewTimer(b.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timeout:
+		err = fmt.Errorf("%w: queue timeout for %s", ErrBulkheadFull, key)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w.granted {
+		// The place was given while the request stopped waiting
+		p.inflight--
+		b.grant(p)
+		return err
+	}
+	for i, queued := range p.queue {
+		if queued == w {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			break
+		}
+	}
+	return err
+}
+
+// grant gives the free places to the requests waiting, the lock must be held
+func (b *Bulkhead) grant(p *partition) {
+	for len(p.queue) > 0 && p.inflight < int(p.limit) {
+		w := p.queue[0]
+		p.queue = p.queue[1:]
+		w.granted = true
+		p.inflight++
+		close(w.ready)
+	}
+}
+
+// release frees the place of a request and adapts the limit
+func (b *Bulkhead) release(key string, latency time.Duration, dropped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.partition(key)
+	inflight := p.inflight
+	p.inflight--
+	p.lastUsed = time.Now()
+
+	lower, upper := b.bounds()
+	switch b.Algorithm {
+	case AIMDLimit:
+		if dropped {
+			p.limit *= aimdBackoff
+		} else if float64(inflight)*2 >= p.limit {
+			// The limit increases only when it's used
+			p.limit += 1 / p.limit
+		}
+	case GradientLimit:
+		rtt := float64(latency)
+		if p.longRTT == 0 {
+			p.longRTT = rtt
+		}
+		p.longRTT = p.longRTT*0.99 + rtt*0.01
+		gradient := 0.5
+		if !dropped && rtt > 0 {
+			gradient = math.Max(0.5, math.Min(1, p.longRTT/rtt))
+		}
+		next := p.limit*gradient + math.Sqrt(p.limit)
+		p.limit = p.limit*0.8 + next*0.2
+	default:
+		b.grant(p)
+		return
+	}
+	p.limit = math.Max(lower, math.Min(upper, p.limit))
+	b.grant(p)
+}
+
+// WithBulkhead is a Decorator limiting the requests in progress by partition,
+// a request is rejected with ErrBulkheadFull when the queue is full or after QueueTimeout.
+// A request is in progress until the body of its response is closed.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://inventory.internal",
+//		httpclient.WithDecorator(httpclient.WithBulkhead(&httpclient.Bulkhead{
+//			MaxConcurrent: 20,
+//			MaxQueue:      100,
+//			QueueTimeout:  time.Second,
+//			Algorithm:     httpclient.GradientLimit,
+//		})))
+func WithBulkhead(b *Bulkhead) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			key := r.URL.Host
+			if b.Partition != nil {
+				key = b.Partition(r)
+			}
+			if err := b.acquire(r.Context(), key); err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			resp, err := d.Do(r)
+			if err != nil || resp == nil || resp.Body == nil {
+				b.release(key, time.Since(start), err != nil && r.Context().Err() == nil)
+				return resp, err
+			}
+			dropped := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+			resp.Body = &bulkheadBody{ReadCloser: resp.Body, release: func() {
+				b.release(key, time.Since(start), dropped)
+			}}
+			return resp, nil
+		})
+	}
+}
+
+// bulkheadBody releases the place of the request when it's closed
+type bulkheadBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *bulkheadBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_WithBulkhead(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	bulkhead := &Bulkhead{
+		MaxConcurrent: 2,
+		MaxQueue:      1,
+		Partition:     func(r *http.Request) string { return r.URL.Path },
+	}
+	c, err := NewClient(s.URL, WithDecorator(WithBulkhead(bulkhead)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	// state waits the requests in progress and in the queue of the partition
+	state := func(key string, inflight, queued int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			bulkhead.mu.Lock()
+			p := bulkhead.partition(key)
+			ok := p.inflight == inflight && len(p.queue) == queued
+			bulkhead.mu.Unlock()
+			if ok {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("partition %s is not %d in progress and %d queued", key, inflight, queued)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.Get(context.Background(), "/slow", nil, nil)
+		}(i)
+		state("/slow", []int{1, 2, 2}[i], []int{0, 0, 1}[i])
+	}
+
+	// The queue is full
+	if _, err := c.Get(context.Background(), "/slow", nil, nil); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Client.Get() error = %v, want %v", err, ErrBulkheadFull)
+	}
+	// The partitions are independent
+	if _, err := c.Get(context.Background(), "/fast", nil, nil); err != nil {
+		t.Errorf("Client.Get() other partition error = %v", err)
+	}
+	// A canceled request leaves the queue
+	bulkhead.mu.Lock()
+	bulkhead.MaxQueue = 2
+	bulkhead.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, "/slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Client.Get() canceled error = %v", err)
+	}
+	state("/slow", 2, 1)
+
+	// The queued request is sent when a place is free
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Client.Get() %d error = %v", i, err)
+		}
+	}
+	state("/slow", 0, 0)
+}
+
+func TestClient_WithBulkhead_Body(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("streamed body"))
+	}))
+	defer s.Close()
+
+	bulkhead := &Bulkhead{MaxConcurrent: 1}
+	c, err := NewClient(s.URL, WithDecorator(WithBulkhead(bulkhead)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatalf("Client.Do() error = %v", err)
+	}
+	// The request is in progress until its body is closed
+	if _, err := c.Get(context.Background(), "/", nil, nil); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Client.Get() error = %v, want %v", err, ErrBulkheadFull)
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	r, _ = http.NewRequest(http.MethodGet, s.URL, nil)
+	if resp, err = c.Do(r); err != nil {
+		t.Fatalf("Client.Do() after close error = %v", err)
+	}
+	resp.Body.Close()
+	bulkhead.mu.Lock()
+	defer bulkhead.mu.Unlock()
+	if p := bulkhead.partition(r.URL.Host); p.inflight != 0 {
+		t.Errorf("requests in progress = %v, want 0", p.inflight)
+	}
+}
+
+func TestBulkhead_sweep(t *testing.T) {
+	b := &Bulkhead{}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	idle := b.partition("idle")
+	idle.lastUsed = time.Now().Add(-2 * bulkheadIdleTimeout)
+	b.partition("busy").inflight = 1
+	b.partition("recent").lastUsed = time.Now()
+
+	// The partitions are swept when a partition is created after the idle timeout
+	b.lastSweep = time.Now().Add(-bulkheadIdleTimeout)
+	b.partition("new")
+	for key, want := range map[string]bool{"idle": false, "busy": true, "recent": true, "new": true} {
+		if _, ok := b.partitions[key]; ok != want {
+			t.Errorf("partition %s kept = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestBulkhead_QueueTimeout(t *testing.T) {
+	b := &Bulkhead{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond}
+	if err := b.acquire(context.Background(), "host"); err != nil {
+		t.Fatalf("Bulkhead.acquire() error = %v", err)
+	}
+	if err := b.acquire(context.Background(), "host"); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Bulkhead.acquire() error = %v, want %v", err, ErrBulkheadFull)
+	}
+}
+
+func TestBulkhead_Adaptive(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm LimitAlgorithm
+		latencies []time.Duration
+		dropped   bool
+		want      func(limit int) bool
+	}{
+		{
+			name:      "ok case - AIMD increase",
+			algorithm: AIMDLimit,
+			latencies: repeatLatency(10*time.Millisecond, 100),
+			want:      func(limit int) bool { return limit > 10 },
+		},
+		{
+			name:      "ok case - AIMD decrease",
+			algorithm: AIMDLimit,
+			latencies: repeatLatency(10*time.Millisecond, 10),
+			dropped:   true,
+			want:      func(limit int) bool { return limit < 10 },
+		},
+		{
+			name:      "ok case - gradient increase with stable latency",
+			algorithm: GradientLimit,
+			latencies: repeatLatency(10*time.Millisecond, 20),
+			want:      func(limit int) bool { return limit > 10 },
+		},
+		{
+			name:      "ok case - gradient decrease with growing latency",
+			algorithm: GradientLimit,
+			latencies: append(repeatLatency(10*time.Millisecond, 20), repeatLatency(time.Second, 3)...),
+			want:      func(limit int) bool { return limit < 10 },
+		},
+		{
+			name:      "ok case - fixed",
+			algorithm: FixedLimit,
+			latencies: repeatLatency(10*time.Millisecond, 20),
+			dropped:   true,
+			want:      func(limit int) bool { return limit == 10 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bulkhead{Algorithm: tt.algorithm, MaxLimit: 50}
+			for _, latency := range tt.latencies {
+				// The partition is used to increase the limit
+				acquired := b.Limit("host")
+				for i := 0; i < acquired; i++ {
+					if err := b.acquire(context.Background(), "host"); err != nil {
+						t.Fatalf("Bulkhead.acquire() error = %v", err)
+					}
+				}
+				for i := 0; i < acquired; i++ {
+					b.release("host", latency, tt.dropped)
+				}
+			}
+			if got := b.Limit("host"); !tt.want(got) {
+				t.Errorf("Bulkhead.Limit() = %v", got)
+			}
+		})
+	}
+}
+
+func repeatLatency(latency time.Duration, n int) []time.Duration {
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		latencies[i] = latency
+	}
+	return latencies
+}
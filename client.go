@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Client struct is used to create a httpclient with client-level settings,
@@ -26,6 +27,18 @@ type Client struct {
 
 	// User agent for any http request
 	userAgent string
+
+	// Session of the cookies and of the default headers
+	session *Session
+
+	// Default timeout of the requests
+	timeout time.Duration
+
+	// Idempotency-Key generated for the non-idempotent requests
+	idempotencyKeys bool
+
+	// Compression of the request bodies by host
+	compressionHosts map[string]BodyCompression
 }
 
 func NewClient(baseURL string, opts ...ClientsOption) (*Client, error) {
@@ -40,16 +53,44 @@ func NewClient(baseURL string, opts ...ClientsOption) (*Client, error) {
 	for _, o := range opts {
 		o(options)
 	}
+	if options.err != nil {
+		return nil, options.err
+	}
+	if err := options.applyTLS(); err != nil {
+		return nil, err
+	}
+	if err := options.applyTimeouts(); err != nil {
+		return nil, err
+	}
+	if err := options.applySSRF(); err != nil {
+		return nil, err
+	}
+	if err := options.applyBalancer(baseURL); err != nil {
+		return nil, err
+	}
+	options.applyAttemptTimeouts()
 
 	// Provide the http.client
 	httpclient := &http.Client{
 		Transport: options.Transport,
 	}
+	if options.redirect != nil {
+		httpclient.CheckRedirect = options.redirect.checkRedirect
+	}
+	if options.session != nil {
+		httpclient.Jar = options.session
+	}
 
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: httpclient,
-		decorators: options.Decorators,
+		baseURL:          baseURL,
+		httpClient:       httpclient,
+		decorators:       options.Decorators,
+		limitSize:        options.LimitSize,
+		userAgent:        options.UserAgent,
+		session:          options.session,
+		timeout:          options.timeout,
+		idempotencyKeys:  options.idempotencyKeys,
+		compressionHosts: options.compressionHosts,
 	}, nil
 }
 
@@ -147,9 +188,18 @@ func (c *Client) Head(
 
 // Do method returns the http.Request if your need to send your own request.
 func (c *Client) Do(r *http.Request) (*http.Response, error) {
+	r, cancel := c.withTimeout(r)
+	r = c.withJar(r)
 	// Apply all Decorators pattern
 	do := chain(c.httpClient, c.decorators...)
-	return do.Do(r)
+	resp, err := do.Do(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout includes the read of the body
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
 }
 
 // createAndDo create the http.request and Do the request
@@ -158,7 +208,7 @@ func (c *Client) createAndDo(
 	path string,
 	method string,
 	body any,
-	response any,
+	result any,
 	resultError any,
 	opts ...RequestOption) (Response, error) {
 
@@ -173,32 +223,58 @@ func (c *Client) createAndDo(
 		return Response{}, err
 	}
 
+	if config.soap != nil {
+		return c.doSOAP(r, result)
+	}
+
+	response, _, err := c.do(r, result, resultError)
+	// clean the request config
+	config = nil
+	return response, err
+}
+
+// do sends the request with all Decorators and decodes the response,
+// the raw body of the response is returned too
+func (c *Client) do(r *http.Request, result any, resultError any) (Response, []byte, error) {
+	httpresponse, rawBody, err := c.send(r)
+	if err != nil {
+		return Response{Request: r, RawResponse: httpresponse, Redirects: redirectsOf(httpresponse)}, nil, err
+	}
+
+	// Check the Content-Type here
+	if err := parseResponse(result, resultError, httpresponse, rawBody); err != nil {
+		return Response{Request: r, RawResponse: httpresponse, Redirects: redirectsOf(httpresponse)}, rawBody, err
+	}
+
+	return Response{
+		Request:     r,
+		RawResponse: httpresponse,
+		Redirects:   redirectsOf(httpresponse),
+	}, rawBody, nil
+}
+
+// send sends the request with all Decorators and reads the body of the response
+func (c *Client) send(r *http.Request) (*http.Response, []byte, error) {
+	r, cancel := c.withTimeout(r)
+	defer cancel()
+	r = c.withJar(r)
+
 	// Apply all Decorators pattern
 	do := chain(c.httpClient, c.decorators...)
 	httpresponse, err := do.Do(r)
 	if err != nil {
-		return Response{Request: r}, err
+		return nil, nil, err
 	}
 
 	// Decode the body here
 	rawBody, err := readAllWithLimit(httpresponse.Body, c.limitSize)
 	if err != nil {
-		return Response{Request: r, RawResponse: httpresponse}, err
+		_ = httpresponse.Body.Close()
+		return httpresponse, nil, err
 	}
 	_ = httpresponse.Body.Close()
 
 	// remplace the response body by nop.closer
 	httpresponse.Body = io.NopCloser(bytes.NewBuffer(rawBody))
-
-	// Check the Content-Type here
-	if err := parseResponse(response, resultError, httpresponse, rawBody); err != nil {
-		return Response{Request: r, RawResponse: httpresponse}, err
-	}
-
-	// clean the request config
-	config = nil
-	return Response{
-		Request:     r,
-		RawResponse: httpresponse,
-	}, nil
+	return httpresponse, rawBody, nil
 }
@@ -79,6 +79,45 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNewClient_UserAgent(t *testing.T) {
+	var userAgent string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get(userAgentHeaderKey)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name string
+		opts []ClientsOption
+		want string
+	}{
+		{
+			name: "ok case - default user agent",
+			want: "httpclient, v1.0.0, go ",
+		},
+		{
+			name: "ok case - user agent",
+			opts: []ClientsOption{WithUserAgent("test")},
+			want: "test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(s.URL, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, err := c.Get(context.Background(), "/", nil, nil); err != nil {
+				t.Fatalf("Client.Get() error = %v", err)
+			}
+			if !strings.HasPrefix(userAgent, tt.want) {
+				t.Errorf("User-Agent = %v, want %v", userAgent, tt.want)
+			}
+		})
+	}
+}
+
 func TestClient_Get(t *testing.T) {
 
 	path := "/result"
@@ -0,0 +1,164 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Coalescer deduplicates the identical requests in progress: one request is
+// sent to the server and its response is shared by all the callers
+type Coalescer struct {
+	// Headers of the key of the requests in addition to the method, the URL,
+	// Authorization and Cookie, like Accept or Accept-Language. The cookies of the
+	// cookie jar of the Client, like the ones of a Session, are part of the key too.
+	Headers []string
+	// Methods coalesced, GET and HEAD by default. Only idempotent methods must be coalesced.
+	Methods []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is a request in progress shared by the callers
+type coalescedCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// key returns the key of the request, false if it can't be coalesced
+func (c *Coalescer) key(r *http.Request) (string, bool) {
+	if r.Body != nil && r.Body != http.NoBody {
+		return "", false
+	}
+	methods := c.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	coalesced := false
+	for _, method := range methods {
+		coalesced = coalesced || r.Method == method
+	}
+	if !coalesced {
+		return "", false
+	}
+
+	var key strings.Builder
+	key.WriteString(r.Method + " " + r.URL.String())
+	for _, name := range append([]string{authorizationHeaderKey, "Cookie"}, c.Headers...) {
+		key.WriteString("\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(r.Header.Values(name), ", "))
+	}
+	// The cookies of the jar are added to the request after the Decorators
+	if jar, ok := r.Context().Value(jarContextKey{}).(http.CookieJar); ok {
+		for _, cookie := range jar.Cookies(r.URL) {
+			key.WriteString("\nJar-Cookie: " + cookie.Name + "=" + cookie.Value)
+		}
+	}
+	return key.String(), true
+}
+
+// response returns a copy of the shared response with its own body
+func (call *coalescedCall) response(r *http.Request) *http.Response {
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Trailer = call.resp.Trailer.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.Request = r
+	return &resp
+}
+
+// WithCoalescing is a Decorator sharing the response of a request with the
+// identical requests sent while it's in progress. The request to the server
+// is canceled only when all the callers are canceled.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://catalog.internal",
+//		httpclient.WithDecorator(httpclient.WithCoalescing(&httpclient.Coalescer{
+//			Headers: []string{"Accept-Language"},
+//		})))
+func WithCoalescing(c *Coalescer) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			key, ok := c.key(r)
+			if !ok {
+				return d.Do(r)
+			}
+
+			c.mu.Lock()
+			if c.calls == nil {
+				c.calls = map[string]*coalescedCall{}
+			}
+			call, shared := c.calls[key]
+			if !shared {
+				// The request isn't canceled by the caller which sends it
+				ctx, cancel := context.WithCancel(detachedContext{parent: r.Context()})
+				call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+				c.calls[key] = call
+				go c.send(d, r.WithContext(ctx), key, call)
+			}
+			call.waiters++
+			c.mu.Unlock()
+
+			select {
+			case <-call.done:
+				if call.err != nil {
+					return nil, call.err
+				}
+				return call.response(r), nil
+			case <-r.Context().Done():
+				c.mu.Lock()
+				call.waiters--
+				if call.waiters == 0 {
+					call.cancel()
+					if c.calls[key] == call {
+						delete(c.calls, key)
+					}
+				}
+				c.mu.Unlock()
+				return nil, r.Context().Err()
+			}
+		})
+	}
+}
+
+// send sends the request of the call and reads the whole response
+func (c *Coalescer) send(d Doer, r *http.Request, key string, call *coalescedCall) {
+	defer call.cancel()
+	resp, err := d.Do(r)
+	if err == nil {
+		call.body, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		call.resp = resp
+	}
+	call.err = err
+
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// detachedContext keeps the values of its parent without its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key any) any { return c.parent.Value(key) }
@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithCoalescing(t *testing.T) {
+	var (
+		calls    int32
+		canceled int32
+	)
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			atomic.AddInt32(&canceled, 1)
+			return
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = w.Write([]byte(`{"lang": "` + r.Header.Get("Accept-Language") + `"}`))
+	}))
+	defer s.Close()
+
+	coalescer := &Coalescer{Headers: []string{"Accept-Language"}}
+	c, err := NewClient(s.URL, WithDecorator(WithCoalescing(coalescer)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	// waiters waits the number of callers of the requests in progress
+	waiters := func(want int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			coalescer.mu.Lock()
+			got := 0
+			for _, call := range coalescer.calls {
+				got += call.waiters
+			}
+			coalescer.mu.Unlock()
+			if got == want {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("the callers are not %d", want)
+	}
+	get := func(ctx context.Context, lang string) (map[string]string, error) {
+		var got map[string]string
+		headers := http.Header{}
+		headers.Set("Accept-Language", lang)
+		_, err := c.Get(ctx, "/products/1", &got, nil, WithHeaders(headers))
+		return got, err
+	}
+
+	// The identical requests share one request, a canceled caller leaves the others
+	var wg sync.WaitGroup
+	results := make([]map[string]string, 6)
+	errs := make([]error, 6)
+	for i := range results {
+		lang := "en"
+		if i == 5 {
+			lang = "fr"
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = get(context.Background(), lang)
+		}(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	canceledErr := make(chan error)
+	go func() {
+		_, err := get(ctx, "en")
+		canceledErr <- err
+	}()
+	waiters(7)
+	cancel()
+	if err := <-canceledErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Client.Get() canceled error = %v", err)
+	}
+	close(release)
+	wg.Wait()
+	for i, got := range results {
+		want := "en"
+		if i == 5 {
+			want = "fr"
+		}
+		if errs[i] != nil || got["lang"] != want {
+			t.Errorf("Client.Get() %d = %v, %v, want %v", i, got, errs[i], want)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("calls = %v, want 2", got)
+	}
+	if atomic.LoadInt32(&canceled) != 0 {
+		t.Error("the shared request is canceled")
+	}
+
+	// The request is canceled when all the callers are canceled
+	release = make(chan struct{})
+	defer close(release)
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := get(ctx, "de")
+		canceledErr <- err
+	}()
+	waiters(1)
+	cancel()
+	<-canceledErr
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&canceled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&canceled) != 1 {
+		t.Error("the request is not canceled")
+	}
+}
+
+func TestClient_WithCoalescing_Sessions(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		cookie, _ := r.Cookie("user")
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = w.Write([]byte(`{"user": "` + cookie.Value + `"}`))
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	coalescer := &Coalescer{}
+	c, err := NewClient(s.URL, WithDecorator(WithCoalescing(coalescer)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	users := []string{"alice", "bob"}
+	clients := make([]*Client, len(users))
+	for i, user := range users {
+		session, err := NewSession(SessionOptions{})
+		if err != nil {
+			t.Fatalf("NewSession() error = %v", err)
+		}
+		session.SetCookies(u, []*http.Cookie{{Name: "user", Value: user}})
+		clients[i] = c.ForSession(session)
+	}
+
+	// The requests of the sessions are not shared
+	var wg sync.WaitGroup
+	results := make([]map[string]string, len(users))
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = clients[i].Get(context.Background(), "/me", &results[i], nil)
+		}(i)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	for i, user := range users {
+		if results[i]["user"] != user {
+			t.Errorf("Client.Get() %s = %v", user, results[i])
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("calls = %v, want 2", got)
+	}
+}
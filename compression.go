@@ -0,0 +1,137 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var ErrUnsupportedEncoding = errors.New("httpclient: unsupported content encoding")
+
+// defaultCompressionThreshold is the size of the smallest body compressed
+const defaultCompressionThreshold = 1024
+
+// BodyCompression is the compression of the request bodies
+type BodyCompression struct {
+	// Encoding of the bodies, gzip or zstd. The bodies are sent as they are when empty.
+	Encoding string
+	// Threshold is the size of the smallest body compressed, 1024 bytes when zero or negative
+	Threshold int
+}
+
+// validate returns an error if the encoding is unknown
+func (bc BodyCompression) validate() error {
+	switch strings.ToLower(bc.Encoding) {
+	case "", "gzip", "zstd":
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, bc.Encoding)
+}
+
+// WithBodyCompression is to compress the body of the request if it's larger than the
+// threshold and if the compressed one is smaller. The Content-Encoding header is set
+// and the compressed body is sent again by the retries. It overrides the compression
+// of the host set by WithCompressionHosts, an empty Encoding disables it.
+//
+// For Example:
+//
+//	resp, err := client.Post(ctx, "/events", events, nil, nil,
+//		httpclient.WithBodyCompression(httpclient.BodyCompression{Encoding: "zstd"}))
+func WithBodyCompression(compression BodyCompression) RequestOption {
+	return func(rc *requestConfig) {
+		if err := compression.validate(); err != nil && rc.err == nil {
+			rc.err = err
+		}
+		rc.compression = &compression
+	}
+}
+
+// WithCompressionHosts is to compress the bodies of the requests sent to the hosts
+// known to accept an encoding, by host or by host and port.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithCompressionHosts(map[string]httpclient.BodyCompression{
+//			"api.example.com":  {Encoding: "zstd"},
+//			"logs.example.com": {Encoding: "gzip", Threshold: 4096},
+//		}))
+func WithCompressionHosts(hosts map[string]BodyCompression) ClientsOption {
+	return func(cc *clientConfig) {
+		for host, compression := range hosts {
+			if err := compression.validate(); err != nil {
+				if cc.err == nil {
+					cc.err = fmt.Errorf("%w, host %s", err, host)
+				}
+				return
+			}
+		}
+		cc.compressionHosts = make(map[string]BodyCompression, len(hosts))
+		for host, compression := range hosts {
+			cc.compressionHosts[strings.ToLower(host)] = compression
+		}
+	}
+}
+
+// compressBody replaces the body of the request by the compressed one
+func (c *Client) compressBody(r *http.Request, compression *BodyCompression) error {
+	if compression == nil {
+		host, ok := c.compressionHosts[strings.ToLower(r.URL.Host)]
+		if !ok {
+			host, ok = c.compressionHosts[strings.ToLower(r.URL.Hostname())]
+		}
+		if !ok {
+			return nil
+		}
+		compression = &host
+	}
+	threshold := compression.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	// Only the bodies which can be read again are compressed
+	if compression.Encoding == "" || r.GetBody == nil || r.ContentLength < int64(threshold) ||
+		r.Header.Get(contentEncodingHeaderKey) != "" {
+		return nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	payload, err := io.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return err
+	}
+	var compressed []byte
+	switch strings.ToLower(compression.Encoding) {
+	case "gzip":
+		var buffer bytes.Buffer
+		w := gzip.NewWriter(&buffer)
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		compressed = buffer.Bytes()
+	case "zstd":
+		compressed = zstdEncode(payload)
+	}
+	if len(compressed) >= len(payload) {
+		return nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(compressed))
+	r.ContentLength = int64(len(compressed))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	r.Header.Set(contentEncodingHeaderKey, strings.ToLower(compression.Encoding))
+	return nil
+}
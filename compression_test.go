@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_WithBodyCompression(t *testing.T) {
+	items := testItems()
+	type attempt struct {
+		encoding string
+		length   int64
+		body     []byte
+	}
+	var (
+		mu       sync.Mutex
+		attempts []attempt
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := attempt{encoding: r.Header.Get(contentEncodingHeaderKey), length: r.ContentLength}
+		var reader io.Reader = r.Body
+		switch a.encoding {
+		case "gzip":
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reader = gr
+		case "zstd":
+			reader = newZstdReader(r.Body)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a.body = body
+		mu.Lock()
+		attempts = append(attempts, a)
+		n := len(attempts)
+		mu.Unlock()
+		if r.URL.Query().Get("fail") != "" && n < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+	retry := WithDecorator(WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}))
+
+	tests := []struct {
+		name         string
+		opts         []ClientsOption
+		path         string
+		body         any
+		reqOpts      []RequestOption
+		wantAttempts int
+		wantEncoding string
+		wantErr      error
+	}{
+		{
+			name:         "ok case - gzip body",
+			body:         items,
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{Encoding: "gzip"})},
+			wantAttempts: 1,
+			wantEncoding: "gzip",
+		},
+		{
+			name:         "ok case - zstd body",
+			body:         items,
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{Encoding: "zstd"})},
+			wantAttempts: 1,
+			wantEncoding: "zstd",
+		},
+		{
+			name:         "ok case - compressed body sent again by the retry",
+			opts:         []ClientsOption{retry},
+			path:         "?fail=1",
+			body:         items,
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{Encoding: "zstd"})},
+			wantAttempts: 2,
+			wantEncoding: "zstd",
+		},
+		{
+			name:         "ok case - body below the threshold",
+			body:         items,
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{Encoding: "gzip", Threshold: len(items) + 1})},
+			wantAttempts: 1,
+		},
+		{
+			name:         "ok case - compression of the host",
+			opts:         []ClientsOption{WithCompressionHosts(map[string]BodyCompression{u.Hostname(): {Encoding: "zstd"}})},
+			body:         items,
+			wantAttempts: 1,
+			wantEncoding: "zstd",
+		},
+		{
+			name:         "ok case - compression of the host and port",
+			opts:         []ClientsOption{WithCompressionHosts(map[string]BodyCompression{u.Host: {Encoding: "gzip"}, u.Hostname(): {Encoding: "zstd"}})},
+			body:         items,
+			wantAttempts: 1,
+			wantEncoding: "gzip",
+		},
+		{
+			name:         "ok case - unknown host",
+			opts:         []ClientsOption{WithCompressionHosts(map[string]BodyCompression{"api.example.com": {Encoding: "zstd"}})},
+			body:         items,
+			wantAttempts: 1,
+		},
+		{
+			name:         "ok case - compression of the host disabled by the request",
+			opts:         []ClientsOption{WithCompressionHosts(map[string]BodyCompression{u.Hostname(): {Encoding: "zstd"}})},
+			body:         items,
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{})},
+			wantAttempts: 1,
+		},
+		{
+			name:         "ok case - incompressible body",
+			body:         strings.Repeat("x", 10),
+			reqOpts:      []RequestOption{WithBodyCompression(BodyCompression{Encoding: "gzip", Threshold: 1})},
+			wantAttempts: 1,
+		},
+		{
+			name:    "nok case - unknown encoding of the request",
+			body:    items,
+			reqOpts: []RequestOption{WithBodyCompression(BodyCompression{Encoding: "lz4"})},
+			wantErr: ErrUnsupportedEncoding,
+		},
+		{
+			name:    "nok case - unknown encoding of a host",
+			opts:    []ClientsOption{WithCompressionHosts(map[string]BodyCompression{u.Hostname(): {Encoding: "br"}})},
+			body:    items,
+			wantErr: ErrUnsupportedEncoding,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = nil
+			c, err := NewClient(s.URL, tt.opts...)
+			if err == nil {
+				_, err = c.Put(context.Background(), "/items"+tt.path, tt.body, nil, nil, tt.reqOpts...)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Client.Put() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(attempts) != tt.wantAttempts {
+				t.Fatalf("attempts = %v, want %v", len(attempts), tt.wantAttempts)
+			}
+			want := []byte(nil)
+			switch body := tt.body.(type) {
+			case []byte:
+				want = body
+			case string:
+				want = []byte(body)
+			}
+			for _, a := range attempts {
+				if a.encoding != tt.wantEncoding {
+					t.Errorf("Content-Encoding = %v, want %v", a.encoding, tt.wantEncoding)
+				}
+				if !bytes.Equal(a.body, want) {
+					t.Errorf("body = %d bytes, want %d", len(a.body), len(want))
+				}
+				if tt.wantEncoding != "" && a.length >= int64(len(want)) {
+					t.Errorf("Content-Length = %v, want less than %v", a.length, len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestZstdEncode(t *testing.T) {
+	random := make([]byte, 1<<18)
+	seed := uint32(7)
+	for i := range random {
+		seed = seed*1664525 + 1013904223
+		random[i] = byte(seed >> 24)
+	}
+	// The repetition of the random bytes is farther than the window
+	window := make([]byte, 0, 1<<zstdWindowLog+2*len(random))
+	window = append(window, random...)
+	window = append(window, make([]byte, 1<<zstdWindowLog)...)
+	window = append(window, random...)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "ok case - empty", data: nil},
+		{name: "ok case - small", data: []byte("hello world")},
+		{name: "ok case - one symbol", data: bytes.Repeat([]byte{'a'}, 300000)},
+		{name: "ok case - json", data: testItems()},
+		{name: "ok case - several blocks", data: bytes.Repeat(testItems(), 3)},
+		{name: "ok case - random", data: random},
+		{name: "ok case - larger than the window", data: window},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := zstdEncode(tt.data)
+			if len(tt.data) > 1<<zstdWindowLog && frame[4]&0x20 != 0 {
+				t.Errorf("zstdEncode() single segment frame of %d bytes", len(tt.data))
+			}
+			got, err := io.ReadAll(newZstdReader(bytes.NewReader(frame)))
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("ReadAll() = %d bytes, want %d", len(got), len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrMissingCredential = errors.New("httpclient: missing credential")
+
+// CredentialType is the way the credential is sent
+type CredentialType int
+
+const (
+	// CredentialBearer sends the token in the Authorization header
+	CredentialBearer CredentialType = iota
+	// CredentialAPIKey sends the key in the header of the credential, X-API-Key by default
+	CredentialAPIKey
+	// CredentialBasic sends the username and the password in the Authorization header
+	CredentialBasic
+)
+
+const defaultAPIKeyHeaderKey = "X-API-Key"
+
+// Credential is a credential added to the requests
+type Credential struct {
+	Type CredentialType
+	// Header of the API key, X-API-Key by default
+	Header string
+	// Value is the token or the API key
+	Value string
+	// Username and Password of the basic credential
+	Username string
+	Password string
+	// Expiry of the credential, the TTL of the cache is used when zero
+	Expiry time.Time
+}
+
+// apply adds the credential to the request
+func (c Credential) apply(r *http.Request) {
+	switch c.Type {
+	case CredentialAPIKey:
+		r.Header.Set(defaultString(c.Header, defaultAPIKeyHeaderKey), c.Value)
+	case CredentialBasic:
+		r.SetBasicAuth(c.Username, c.Password)
+	default:
+		r.Header.Set(authorizationHeaderKey, "Bearer "+c.Value)
+	}
+}
+
+// CredentialProvider provides the credential of the requests
+type CredentialProvider interface {
+	Credential(ctx context.Context) (Credential, error)
+}
+
+// CredentialWatcher is implemented by the providers detecting the rotation of the
+// credential, the cache refreshes the credential in background as soon as it changed
+type CredentialWatcher interface {
+	Changed() bool
+}
+
+// CredentialProviderFunc is an adapter to use a callback as a CredentialProvider
+type CredentialProviderFunc func(ctx context.Context) (Credential, error)
+
+// Credential calls the callback
+func (f CredentialProviderFunc) Credential(ctx context.Context) (Credential, error) {
+	return f(ctx)
+}
+
+// EnvCredentialProvider provides the credential from environment variables
+type EnvCredentialProvider struct {
+	Type CredentialType
+	// Header of the API key, X-API-Key by default
+	Header string
+	// Variable of the token, of the API key or of the password
+	Variable string
+	// UsernameVariable is the variable of the username of the basic credential
+	UsernameVariable string
+}
+
+// Credential returns the credential of the environment
+func (p EnvCredentialProvider) Credential(context.Context) (Credential, error) {
+	credential := Credential{Type: p.Type, Header: p.Header}
+	value := os.Getenv(p.Variable)
+	if value == "" {
+		return Credential{}, fmt.Errorf("%w: empty variable %s", ErrMissingCredential, p.Variable)
+	}
+	if p.Type == CredentialBasic {
+		credential.Username, credential.Password = os.Getenv(p.UsernameVariable), value
+	} else {
+		credential.Value = value
+	}
+	return credential, nil
+}
+
+// FileCredentialProvider provides the credential from files like the secrets
+// mounted by Kubernetes. The files are watched to detect the rotation of the secret.
+type FileCredentialProvider struct {
+	Type CredentialType
+	// Header of the API key, X-API-Key by default
+	Header string
+	// Path of the file of the token, of the API key or of the password
+	Path string
+	// UsernamePath is the file of the username of the basic credential
+	UsernamePath string
+	// PollInterval is the minimum interval between two checks of the files, 10 seconds by default
+	PollInterval time.Duration
+
+	mu        sync.Mutex
+	versions  map[string]fileVersion
+	lastCheck time.Time
+}
+
+// fileVersion identifies the content of a file
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func (p *FileCredentialProvider) paths() []string {
+	if p.Type == CredentialBasic && p.UsernamePath != "" {
+		return []string{p.Path, p.UsernamePath}
+	}
+	return []string{p.Path}
+}
+
+// Credential reads the credential from the files
+func (p *FileCredentialProvider) Credential(context.Context) (Credential, error) {
+	versions := map[string]fileVersion{}
+	contents := map[string]string{}
+	for _, path := range p.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Credential{}, fmt.Errorf("%w: %v", ErrMissingCredential, err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return Credential{}, fmt.Errorf("%w: %v", ErrMissingCredential, err)
+		}
+		versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+		contents[path] = string(bytes.TrimSpace(content))
+	}
+	if contents[p.Path] == "" {
+		return Credential{}, fmt.Errorf("%w: empty file %s", ErrMissingCredential, p.Path)
+	}
+
+	p.mu.Lock()
+	p.versions = versions
+	p.lastCheck = time.Now()
+	p.mu.Unlock()
+
+	credential := Credential{Type: p.Type, Header: p.Header}
+	if p.Type == CredentialBasic {
+		credential.Username, credential.Password = contents[p.UsernamePath], contents[p.Path]
+	} else {
+		credential.Value = contents[p.Path]
+	}
+	return credential, nil
+}
+
+// Changed returns true if a file changed since the last read,
+// the files are checked at most once per PollInterval
+func (p *FileCredentialProvider) Changed() bool {
+	interval := p.PollInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.versions == nil || time.Since(p.lastCheck) < interval {
+		return false
+	}
+	p.lastCheck = time.Now()
+	for path, version := range p.versions {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(version.modTime) || info.Size() != version.size {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialCache caches the credential of a provider. The credential is refreshed
+// in background shortly before its expiry or when the provider detects a rotation,
+// the requests use the current credential during the refresh.
+type CredentialCache struct {
+	provider CredentialProvider
+	ttl      time.Duration
+	window   time.Duration
+
+	mu         sync.Mutex
+	credential *Credential
+	expiry     time.Time
+	flight     *credentialFlight
+	// retryAt delays the next background refresh after a failure
+	retryAt time.Time
+}
+
+// credentialFlight is a call in progress to the provider
+type credentialFlight struct {
+	done       chan struct{}
+	credential *Credential
+	err        error
+}
+
+// NewCredentialCache returns a cache of the provider, the credential without
+// expiry is kept for the TTL, 5 minutes by default
+func NewCredentialCache(provider CredentialProvider, ttl time.Duration) *CredentialCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &CredentialCache{
+		provider: provider,
+		ttl:      ttl,
+		// The refresh starts in the last fifth of the TTL
+		window: ttl / 5,
+	}
+}
+
+// Credential returns the cached credential or fetches a new one
+func (c *CredentialCache) Credential(ctx context.Context) (Credential, error) {
+	c.mu.Lock()
+	now := time.Now()
+	if c.credential != nil && now.Before(c.expiry) {
+		credential := *c.credential
+		if c.flight == nil && !now.Before(c.retryAt) && (!now.Before(c.expiry.Add(-c.window)) || c.changed()) {
+			c.startFlight()
+		}
+		c.mu.Unlock()
+		return credential, nil
+	}
+	flight := c.flight
+	if flight == nil {
+		flight = c.startFlight()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		if flight.err != nil {
+			return Credential{}, flight.err
+		}
+		return *flight.credential, nil
+	case <-ctx.Done():
+		return Credential{}, ctx.Err()
+	}
+}
+
+// Invalidate removes the credential from the cache if it's still the cached one
+func (c *CredentialCache) Invalidate(credential Credential) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.credential != nil && *c.credential == credential {
+		c.credential = nil
+	}
+}
+
+func (c *CredentialCache) changed() bool {
+	watcher, ok := c.provider.(CredentialWatcher)
+	return ok && watcher.Changed()
+}
+
+// startFlight starts a call to the provider, the lock must be held
+func (c *CredentialCache) startFlight() *credentialFlight {
+	flight := &credentialFlight{done: make(chan struct{})}
+	c.flight = flight
+	// The call is not canceled with the context of the first caller
+	go c.fetch(flight)
+	return flight
+}
+
+// fetch calls the provider and ends the flight, the current credential
+// is kept when the refresh fails
+func (c *CredentialCache) fetch(flight *credentialFlight) {
+	credential, err := c.provider.Credential(context.Background())
+
+	c.mu.Lock()
+	if err == nil {
+		flight.credential = &credential
+		c.credential = flight.credential
+		c.expiry = credential.Expiry
+		if c.expiry.IsZero() {
+			c.expiry = time.Now().Add(c.ttl)
+		}
+	} else {
+		c.retryAt = time.Now().Add(time.Second)
+	}
+	flight.err = err
+	c.flight = nil
+	c.mu.Unlock()
+	close(flight.done)
+}
+
+// WithCredentials is a Decorator adding the credential of the provider to the requests.
+// With a CredentialCache, the request is retried once with a new credential after a 401.
+//
+// For Example:
+//
+//	provider := &httpclient.FileCredentialProvider{
+//		Type: httpclient.CredentialAPIKey,
+//		Path: "/var/run/secrets/partner/api-key",
+//	}
+//	client, err := httpclient.NewClient("https://api.partner.com",
+//		httpclient.WithDecorator(httpclient.WithCredentials(
+//			httpclient.NewCredentialCache(provider, time.Minute))))
+func WithCredentials(provider CredentialProvider) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			credential, err := provider.Credential(r.Context())
+			if err != nil {
+				return nil, err
+			}
+			authorized := r.Clone(r.Context())
+			credential.apply(authorized)
+			resp, err := d.Do(authorized)
+			cache, ok := provider.(*CredentialCache)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized || !ok {
+				return resp, err
+			}
+			if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+				return resp, nil
+			}
+
+			// Retry once if the credential was rotated
+			cache.Invalidate(credential)
+			rotated, err := cache.Credential(r.Context())
+			if err != nil || rotated == credential {
+				return resp, nil
+			}
+			retry, err := rewindRequest(r)
+			if err != nil {
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			rotated.apply(retry)
+			return d.Do(retry)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCredential_apply(t *testing.T) {
+	tests := []struct {
+		name       string
+		credential Credential
+		header     string
+		want       string
+	}{
+		{
+			name:       "bearer",
+			credential: Credential{Value: "token"},
+			header:     authorizationHeaderKey,
+			want:       "Bearer token",
+		},
+		{
+			name:       "api key",
+			credential: Credential{Type: CredentialAPIKey, Value: "key"},
+			header:     defaultAPIKeyHeaderKey,
+			want:       "key",
+		},
+		{
+			name:       "api key with header",
+			credential: Credential{Type: CredentialAPIKey, Header: "X-Token", Value: "key"},
+			header:     "X-Token",
+			want:       "key",
+		},
+		{
+			name:       "basic",
+			credential: Credential{Type: CredentialBasic, Username: "user", Password: "pass"},
+			header:     authorizationHeaderKey,
+			want:       "Basic dXNlcjpwYXNz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			tt.credential.apply(r)
+			if got := r.Header.Get(tt.header); got != tt.want {
+				t.Errorf("Credential.apply() %s = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvCredentialProvider_Credential(t *testing.T) {
+	t.Setenv("TEST_API_USER", "user")
+	t.Setenv("TEST_API_PASSWORD", "pass")
+	got, err := EnvCredentialProvider{Type: CredentialBasic, Variable: "TEST_API_PASSWORD", UsernameVariable: "TEST_API_USER"}.
+		Credential(context.Background())
+	if err != nil || got.Username != "user" || got.Password != "pass" {
+		t.Errorf("EnvCredentialProvider.Credential() = %+v, %v", got, err)
+	}
+	if _, err := (EnvCredentialProvider{Variable: "TEST_API_MISSING"}).Credential(context.Background()); !errors.Is(err, ErrMissingCredential) {
+		t.Errorf("EnvCredentialProvider.Credential() error = %v, want %v", err, ErrMissingCredential)
+	}
+}
+
+func TestCredentialCache_Credential(t *testing.T) {
+	t.Run("ok case - background refresh before expiry", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		provider := CredentialProviderFunc(func(context.Context) (Credential, error) {
+			n := atomic.AddInt32(&calls, 1)
+			if n > 1 {
+				<-release
+			}
+			return Credential{Value: string(rune('0' + n)), Expiry: time.Now().Add(100 * time.Millisecond)}, nil
+		})
+		cache := NewCredentialCache(provider, time.Second)
+		cache.window = 80 * time.Millisecond
+		ctx := context.Background()
+
+		if got, err := cache.Credential(ctx); err != nil || got.Value != "1" {
+			t.Fatalf("CredentialCache.Credential() = %v, %v", got, err)
+		}
+		time.Sleep(30 * time.Millisecond)
+		// The refresh is blocked but the current credential is still returned
+		for i := 0; i < 3; i++ {
+			if got, err := cache.Credential(ctx); err != nil || got.Value != "1" {
+				t.Fatalf("CredentialCache.Credential() = %v, %v", got, err)
+			}
+		}
+		close(release)
+		time.Sleep(20 * time.Millisecond)
+		if got, _ := cache.Credential(ctx); got.Value != "2" || atomic.LoadInt32(&calls) != 2 {
+			t.Errorf("CredentialCache.Credential() = %v, calls %v", got.Value, calls)
+		}
+	})
+
+	t.Run("ok case - file rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cache := NewCredentialCache(&FileCredentialProvider{Path: path, PollInterval: time.Nanosecond}, time.Hour)
+		ctx := context.Background()
+		if got, err := cache.Credential(ctx); err != nil || got.Value != "first" {
+			t.Fatalf("CredentialCache.Credential() = %v, %v", got, err)
+		}
+		if err := os.WriteFile(path, []byte("rotated\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for {
+			got, err := cache.Credential(ctx)
+			if err == nil && got.Value == "rotated" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("CredentialCache.Credential() = %v, %v, want rotated", got, err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("nok case - provider error", func(t *testing.T) {
+		cache := NewCredentialCache(CredentialProviderFunc(func(context.Context) (Credential, error) {
+			return Credential{}, ErrMissingCredential
+		}), 0)
+		if _, err := cache.Credential(context.Background()); !errors.Is(err, ErrMissingCredential) {
+			t.Errorf("CredentialCache.Credential() error = %v, want %v", err, ErrMissingCredential)
+		}
+	})
+}
+
+func TestWithCredentials(t *testing.T) {
+	var current atomic.Value
+	current.Store("old")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(defaultAPIKeyHeaderKey) != current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer s.Close()
+
+	var calls int32
+	key := "old"
+	cache := NewCredentialCache(CredentialProviderFunc(func(context.Context) (Credential, error) {
+		atomic.AddInt32(&calls, 1)
+		return Credential{Type: CredentialAPIKey, Value: key}, nil
+	}), time.Hour)
+	doer := WithCredentials(cache)(&http.Client{})
+
+	r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	if resp, err := doer.Do(r); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Do() = %v, %v", resp, err)
+	}
+	// The key is rotated on the server and in the provider
+	current.Store("new")
+	key = "new"
+	if resp, err := doer.Do(r); err != nil || resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("Do() = %v, %v, calls %v", resp, err, calls)
+	}
+}
@@ -0,0 +1,207 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	acceptEncodingHeaderKey  = "Accept-Encoding"
+	contentEncodingHeaderKey = "Content-Encoding"
+)
+
+// Decompressor is the configuration of WithDecompression
+type Decompressor struct {
+	// MaxSize of the decoded body, unlimited when zero. The body read
+	// by the methods of the Client is limited by WithSizeLimit too.
+	MaxSize int64
+	// Decoders of other encodings by their name, they are advertised
+	// in Accept-Encoding and replace the provided ones of the same name
+	Decoders map[string]func(r io.Reader) (io.ReadCloser, error)
+}
+
+// WithDecompression is a Decorator decoding the gzip, deflate, br and zstd responses,
+// and the ones of the Decoders. The encodings are advertised in the Accept-Encoding
+// header of the requests without one. The stacked encodings are decoded in the
+// reverse order of Content-Encoding, the response keeps its encoding if one of them
+// is unknown. The Content-Encoding and Content-Length headers of a decoded response
+// are removed.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithSizeLimit(10<<20),
+//		httpclient.WithDecorator(httpclient.WithDecompression(&httpclient.Decompressor{})))
+func WithDecompression(d *Decompressor) Decorator {
+	if d == nil {
+		d = &Decompressor{}
+	}
+	accept := d.acceptEncoding()
+	return func(next Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get(acceptEncodingHeaderKey) == "" {
+				r = r.Clone(r.Context())
+				r.Header.Set(acceptEncodingHeaderKey, accept)
+			}
+			resp, err := next.Do(r)
+			if err != nil {
+				return resp, err
+			}
+			if err := d.decode(resp); err != nil {
+				_ = resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		})
+	}
+}
+
+// acceptEncoding returns the value of the Accept-Encoding header
+func (d *Decompressor) acceptEncoding() string {
+	encodings := []string{"gzip", "deflate", "br", "zstd"}
+	var others []string
+	for name := range d.Decoders {
+		name = strings.ToLower(name)
+		if _, ok := builtinDecoders[name]; !ok {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return strings.Join(append(encodings, others...), ", ")
+}
+
+// builtinDecoders are the decoders of the encodings provided by the package
+var builtinDecoders = map[string]func(r io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"x-gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": newDeflateReader,
+	"br": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(newBrotliReader(r)), nil
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(newZstdReader(r)), nil
+	},
+}
+
+// newDeflateReader reads the zlib format of RFC 9110, or the raw deflate
+// format sent by some servers
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if header[0]&0x0F == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decoder returns the decoder of an encoding
+func (d *Decompressor) decoder(encoding string) func(r io.Reader) (io.ReadCloser, error) {
+	for name, decoder := range d.Decoders {
+		if strings.EqualFold(name, encoding) {
+			return decoder
+		}
+	}
+	return builtinDecoders[encoding]
+}
+
+// decode replaces the body of the response by the decoded one
+func (d *Decompressor) decode(resp *http.Response) error {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.Request != nil && resp.Request.Method == http.MethodHead ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	var encodings []string
+	for _, value := range resp.Header.Values(contentEncodingHeaderKey) {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+	decoders := make([]func(r io.Reader) (io.ReadCloser, error), len(encodings))
+	for i, encoding := range encodings {
+		if decoders[i] = d.decoder(encoding); decoders[i] == nil {
+			return nil
+		}
+	}
+
+	// The last encoding applied is the first decoded
+	body := &decodedBody{closers: []io.Closer{resp.Body}, limit: d.MaxSize}
+	var reader io.Reader = resp.Body
+	for i := len(decoders) - 1; i >= 0; i-- {
+		decoded, err := decoders[i](reader)
+		if err != nil {
+			_ = body.Close()
+			return err
+		}
+		body.closers = append(body.closers, decoded)
+		reader = decoded
+	}
+	body.Reader = reader
+	resp.Body = body
+
+	resp.Header.Del(contentEncodingHeaderKey)
+	resp.Header.Del(contentLengthHeaderKey)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody is the decoded body of a response limited to a size
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+	// limit of the size, unlimited when zero
+	limit int64
+	read  int64
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.Reader.Read(p)
+	}
+	if b.read > b.limit {
+		return 0, ErrResponseBodyTooLarge
+	}
+	// One more byte is read to detect a body larger than the limit
+	if max := b.limit - b.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := b.Reader.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), ErrResponseBodyTooLarge
+	}
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
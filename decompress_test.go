@@ -0,0 +1,246 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// testItems returns a JSON array of items compressed in the testdata
+func testItems() []byte {
+	tags := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	seed := uint32(1)
+	b := []byte("[")
+	for i := 0; i < 1500; i++ {
+		seed = seed*1664525 + 1013904223
+		b = append(b, fmt.Sprintf(`{"id": %d, "name": "Item %d", "tag": "%s", "score": %d},`+"\n",
+			i, seed%1000, tags[seed>>29], seed>>20)...)
+	}
+	return append(b, `{"id": -1}]`...)
+}
+
+// compress encodes the data with a writer of compress
+func compress(t *testing.T, data []byte, newWriter func(w io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+func zlibWriter(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+func flateWriter(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.BestCompression)
+	return fw
+}
+
+func TestClient_WithDecompression(t *testing.T) {
+	items := testItems()
+	zstdItems, err := os.ReadFile("testdata/items.json.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	brItems, err := os.ReadFile("testdata/items.json.br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := map[string][]byte{
+		"gzip":          compress(t, items, gzipWriter),
+		"deflate":       compress(t, items, zlibWriter),
+		"deflate-raw":   compress(t, items, flateWriter),
+		"br":            brItems,
+		"zstd":          zstdItems,
+		"zstd, gzip":    compress(t, zstdItems, gzipWriter),
+		"gzip, deflate": compress(t, compress(t, items, gzipWriter), zlibWriter),
+		"compress":      items,
+		"zstd-trunc":    zstdItems[:len(zstdItems)/2],
+	}
+	encodings := map[string]string{"deflate-raw": "deflate", "zstd-trunc": "zstd"}
+
+	var accepted string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get(acceptEncodingHeaderKey)
+		name := r.URL.Query().Get("encoding")
+		encoding := name
+		if e, ok := encodings[name]; ok {
+			encoding = e
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		w.Header().Set(contentEncodingHeaderKey, encoding)
+		w.Header().Set(contentLengthHeaderKey, strconv.Itoa(len(bodies[name])))
+		_, _ = w.Write(bodies[name])
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name         string
+		encoding     string
+		opts         []ClientsOption
+		wantEncoding string
+		wantErr      error
+	}{
+		{name: "ok case - gzip", encoding: "gzip"},
+		{name: "ok case - deflate", encoding: "deflate"},
+		{name: "ok case - raw deflate", encoding: "deflate-raw"},
+		{name: "ok case - br", encoding: "br"},
+		{name: "ok case - zstd", encoding: "zstd"},
+		{name: "ok case - stacked zstd and gzip", encoding: "zstd, gzip"},
+		{name: "ok case - stacked gzip and deflate", encoding: "gzip, deflate"},
+		{name: "ok case - unknown encoding kept", encoding: "compress", wantEncoding: "compress"},
+		{
+			name:     "nok case - decoded body larger than the limit",
+			encoding: "zstd",
+			opts:     []ClientsOption{WithSizeLimit(len(zstdItems) * 2)},
+			wantErr:  ErrResponseBodyTooLarge,
+		},
+		{name: "nok case - truncated zstd", encoding: "zstd-trunc", wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ClientsOption{WithDecorator(WithDecompression(nil))}, tt.opts...)
+			c, err := NewClient(s.URL, opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var result []struct {
+				ID int `json:"id"`
+			}
+			resp, err := c.Get(context.Background(), "/items?encoding="+url.QueryEscape(tt.encoding), &result, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Client.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if accepted != "gzip, deflate, br, zstd" {
+				t.Errorf("Accept-Encoding = %v", accepted)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(result) != 1501 || result[1500].ID != -1 {
+				t.Errorf("Client.Get() items = %v", len(result))
+			}
+			if got := resp.RawResponse.Header.Get(contentEncodingHeaderKey); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %v, want %v", got, tt.wantEncoding)
+			}
+			if tt.wantEncoding == "" && (resp.RawResponse.Header.Get(contentLengthHeaderKey) != "" ||
+				resp.RawResponse.ContentLength != -1 || !resp.RawResponse.Uncompressed) {
+				t.Errorf("Content-Length = %v %v", resp.RawResponse.Header.Get(contentLengthHeaderKey), resp.RawResponse.ContentLength)
+			}
+		})
+	}
+
+	// The limit of the Decompressor applies on the raw responses
+	c, err := NewClient(s.URL, WithDecorator(WithDecompression(&Decompressor{
+		MaxSize: 1000,
+		Decoders: map[string]func(r io.Reader) (io.ReadCloser, error){"lz4": func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		}},
+	})))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, s.URL+"/items?encoding=gzip", nil)
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatalf("Client.Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); !errors.Is(err, ErrResponseBodyTooLarge) || len(body) != 1000 {
+		t.Errorf("ReadAll() = %v bytes, error = %v", len(body), err)
+	}
+	if accepted != "gzip, deflate, br, zstd, lz4" {
+		t.Errorf("Accept-Encoding = %v", accepted)
+	}
+}
+
+func TestZstdReader(t *testing.T) {
+	items := testItems()
+	frame, err := os.ReadFile("testdata/items.json.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Raw block, RLE block and a skippable frame between the frames
+	raw := []byte{0x28, 0xB5, 0x2F, 0xFD, 0x20, 0x05, 0x29, 0x00, 0x00, 'h', 'e', 'l', 'l', 'o'}
+	rle := []byte{0x28, 0xB5, 0x2F, 0xFD, 0x20, 0x03, 0x1B, 0x00, 0x00, '!'}
+	skippable := []byte{0x50, 0x2A, 0x4D, 0x18, 0x02, 0x00, 0x00, 0x00, 0xFF, 0xFF}
+	corrupted := append([]byte{}, frame...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "ok case - compressed frame", data: frame, want: items},
+		{
+			name: "ok case - concatenated frames",
+			data: bytes.Join([][]byte{raw, skippable, rle, frame}, nil),
+			want: append([]byte("hello!!!"), items...),
+		},
+		{name: "nok case - invalid checksum", data: corrupted, wantErr: true},
+		{name: "nok case - invalid magic", data: []byte("hello world"), wantErr: true},
+		{name: "nok case - empty", data: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(newZstdReader(bytes.NewReader(tt.data)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadAll() = %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestBrotliReader(t *testing.T) {
+	items := testItems()
+	stream, err := os.ReadFile("testdata/items.json.br")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "ok case - compressed stream", data: stream, want: items},
+		// Empty last meta-block
+		{name: "ok case - empty stream", data: []byte{0x06}, want: []byte{}},
+		// Uncompressed meta-block of "hello" then empty last meta-block
+		{name: "ok case - uncompressed", data: []byte{0x40, 0x00, 0x10, 'h', 'e', 'l', 'l', 'o', 0x03}, want: []byte("hello")},
+		{name: "nok case - truncated", data: stream[:len(stream)/2], wantErr: true},
+		{name: "nok case - empty", data: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(newBrotliReader(bytes.NewReader(tt.data)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadAll() = %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
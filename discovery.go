@@ -0,0 +1,250 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrIPEndpointScheme = errors.New("httpclient: https endpoints need the SRV records of the DNSResolver")
+
+// Interval before the next resolution when a resolution fails
+const discoveryRetryInterval = 5 * time.Second
+
+// Resolver discovers the endpoints of a service
+type Resolver interface {
+	// Resolve returns the endpoints and the duration before the next resolution
+	Resolve(ctx context.Context) ([]Endpoint, time.Duration, error)
+}
+
+// ResolverFunc is a function used as Resolver
+type ResolverFunc func(ctx context.Context) ([]Endpoint, time.Duration, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	return f(ctx)
+}
+
+// resolve updates the endpoints and returns the duration before the next resolution,
+// the endpoints are kept when the resolution fails or is empty
+func (lb *LoadBalancer) resolve(ctx context.Context) (time.Duration, error) {
+	endpoints, ttl, err := lb.config.Resolver.Resolve(ctx)
+	if err == nil && len(endpoints) == 0 {
+		err = ErrNoEndpoint
+	}
+	if err == nil {
+		err = lb.setEndpoints(endpoints)
+	}
+	if err != nil {
+		return discoveryRetryInterval, fmt.Errorf("httpclient: resolve endpoints: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return ttl, nil
+}
+
+// discover resolves the endpoints until the context is canceled
+func (lb *LoadBalancer) discover(ctx context.Context, next time.Duration) {
+	timer := time.NewTimer(next)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		next, _ = lb.resolve(ctx)
+		timer.Reset(next)
+	}
+}
+
+// DNSLookup is the lookups of a DNSResolver, implemented by *net.Resolver
+type DNSLookup interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSResolver discovers the endpoints from the SRV records of a service,
+// or from the A and AAAA records of a name
+type DNSResolver struct {
+	// Name of the service like orders.internal
+	Name string
+	// Service of the SRV records like http for _http._tcp.orders.internal,
+	// the A and AAAA records of Name are resolved when it's empty
+	Service string
+	// Proto of the SRV records, tcp by default
+	Proto string
+	// Port of the endpoints resolved from A and AAAA records, 80 by default
+	Port int
+	// Scheme of the endpoints, http by default. The endpoints of the A and AAAA records
+	// are IP addresses which can't be verified with the certificate of Name, https
+	// needs the host names of the SRV records.
+	Scheme string
+	// TTL between two resolutions, 30 seconds by default.
+	// The resolver of Go doesn't return the TTL of the records.
+	TTL time.Duration
+	// Lookup of the records, net.DefaultResolver by default
+	Lookup DNSLookup
+}
+
+// Resolve returns the endpoints of the records, only the targets of the
+// SRV records with the lowest priority are used as in RFC 2782
+func (d *DNSResolver) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	var lookup DNSLookup = net.DefaultResolver
+	if d.Lookup != nil {
+		lookup = d.Lookup
+	}
+	scheme := defaultString(d.Scheme, "http")
+
+	var endpoints []Endpoint
+	if d.Service != "" {
+		_, records, err := lookup.LookupSRV(ctx, d.Service, defaultString(d.Proto, "tcp"), d.Name)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range records {
+			if record.Priority != records[0].Priority {
+				continue
+			}
+			endpoints = append(endpoints, Endpoint{
+				URL:    scheme + "://" + net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))),
+				Weight: int(record.Weight),
+			})
+		}
+	} else {
+		if strings.EqualFold(scheme, "https") {
+			return nil, 0, ErrIPEndpointScheme
+		}
+		port := d.Port
+		if port == 0 {
+			port = 80
+		}
+		addrs, err := lookup.LookupHost(ctx, d.Name)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, addr := range addrs {
+			endpoints = append(endpoints, Endpoint{URL: scheme + "://" + net.JoinHostPort(addr, strconv.Itoa(port))})
+		}
+	}
+	return endpoints, d.TTL, nil
+}
+
+// FileResolver discovers the endpoints from a JSON or YAML file, the file is a list
+// of endpoints or an object with the list in "endpoints". A YAML file is limited
+// to this list, with an URL or "url" and "weight" keys by endpoint:
+//
+//	endpoints:
+//	  - url: http://10.0.0.1:8080
+//	    weight: 2
+//	  - http://10.0.0.2:8080
+type FileResolver struct {
+	Path string
+	// PollInterval between two reads of the file, 5 seconds by default
+	PollInterval time.Duration
+}
+
+// Resolve reads the endpoints of the file
+func (f *FileResolver) Resolve(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	interval := f.PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var endpoints []Endpoint
+	switch strings.ToLower(filepath.Ext(f.Path)) {
+	case ".yaml", ".yml":
+		endpoints, err = parseYAMLEndpoints(content)
+	default:
+		endpoints, err = parseJSONEndpoints(content)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("httpclient: parse %s: %w", f.Path, err)
+	}
+	return endpoints, interval, nil
+}
+
+func parseJSONEndpoints(content []byte) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &endpoints)
+		return endpoints, err
+	}
+	var file struct {
+		Endpoints []Endpoint `json:"endpoints"`
+	}
+	err := json.Unmarshal(content, &file)
+	return file.Endpoints, err
+}
+
+// yamlKeyRegex matches a key of a YAML mapping, the value is empty for a nested key
+var yamlKeyRegex = regexp.MustCompile(`^([\w-]+):(?:\s+(.*))?$`)
+
+func parseYAMLEndpoints(content []byte) ([]Endpoint, error) {
+	var (
+		endpoints []Endpoint
+		current   *Endpoint
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+
+		item := strings.HasPrefix(line, "- ") || line == "-"
+		if item {
+			endpoints = append(endpoints, Endpoint{})
+			current = &endpoints[len(endpoints)-1]
+			line = strings.TrimSpace(strings.TrimPrefix(line, "-"))
+			if line == "" {
+				continue
+			}
+		}
+		match := yamlKeyRegex.FindStringSubmatch(line)
+		switch {
+		case match == nil && item:
+			current.URL = unquoteYAML(line)
+		case match == nil:
+			return nil, fmt.Errorf("line %d: invalid %q", n, line)
+		case match[1] == "endpoints" && match[2] == "" && current == nil:
+		case current == nil:
+			return nil, fmt.Errorf("line %d: key %q outside of an endpoint", n, match[1])
+		case match[1] == "url":
+			current.URL = unquoteYAML(match[2])
+		case match[1] == "weight":
+			weight, err := strconv.Atoi(unquoteYAML(match[2]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid weight %q", n, match[2])
+			}
+			current.Weight = weight
+		}
+	}
+	return endpoints, scanner.Err()
+}
+
+// unquoteYAML removes the quotes of a YAML scalar
+func unquoteYAML(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
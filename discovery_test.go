@@ -0,0 +1,241 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDNS is an in-process DNS stand-in
+type fakeDNS struct {
+	mu    sync.Mutex
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (d *fakeDNS) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cname := "_" + service + "._" + proto + "." + name
+	records, ok := d.srv[cname]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+	}
+	return cname, records, nil
+}
+
+func (d *fakeDNS) LookupHost(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	addrs, ok := d.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestDNSResolver_Resolve(t *testing.T) {
+	dns := &fakeDNS{
+		srv: map[string][]*net.SRV{
+			"_http._tcp.orders.internal": {
+				{Target: "a.orders.internal.", Port: 8080, Priority: 10, Weight: 3},
+				{Target: "b.orders.internal.", Port: 8081, Priority: 10, Weight: 1},
+				{Target: "backup.orders.internal.", Port: 8080, Priority: 20, Weight: 1},
+			},
+		},
+		hosts: map[string][]string{"orders.internal": {"10.0.0.1", "fd00::1"}},
+	}
+	tests := []struct {
+		name     string
+		resolver *DNSResolver
+		want     []Endpoint
+		wantErr  bool
+	}{
+		{
+			name:     "ok case - SRV records with the lowest priority",
+			resolver: &DNSResolver{Name: "orders.internal", Service: "http", Lookup: dns},
+			want: []Endpoint{
+				{URL: "http://a.orders.internal:8080", Weight: 3},
+				{URL: "http://b.orders.internal:8081", Weight: 1},
+			},
+		},
+		{
+			name:     "ok case - A and AAAA records",
+			resolver: &DNSResolver{Name: "orders.internal", Lookup: dns},
+			want: []Endpoint{
+				{URL: "http://10.0.0.1:80"},
+				{URL: "http://[fd00::1]:80"},
+			},
+		},
+		{
+			name:     "ok case - SRV records with https",
+			resolver: &DNSResolver{Name: "orders.internal", Service: "http", Scheme: "https", Lookup: dns},
+			want: []Endpoint{
+				{URL: "https://a.orders.internal:8080", Weight: 3},
+				{URL: "https://b.orders.internal:8081", Weight: 1},
+			},
+		},
+		{
+			name:     "nok case - A and AAAA records with https",
+			resolver: &DNSResolver{Name: "orders.internal", Scheme: "https", Lookup: dns},
+			wantErr:  true,
+		},
+		{
+			name:     "nok case - unknown name",
+			resolver: &DNSResolver{Name: "unknown.internal", Service: "http", Lookup: dns},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := tt.resolver.Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DNSResolver.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DNSResolver.Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileResolver_Resolve(t *testing.T) {
+	want := []Endpoint{{URL: "http://10.0.0.1:8080", Weight: 2}, {URL: "http://10.0.0.2:8080"}}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []Endpoint
+		wantErr bool
+	}{
+		{
+			name:    "ok case - JSON list",
+			file:    "endpoints.json",
+			content: `[{"url": "http://10.0.0.1:8080", "weight": 2}, {"url": "http://10.0.0.2:8080"}]`,
+			want:    want,
+		},
+		{
+			name:    "ok case - JSON object",
+			file:    "endpoints.json",
+			content: `{"endpoints": [{"url": "http://10.0.0.1:8080", "weight": 2}, {"url": "http://10.0.0.2:8080"}]}`,
+			want:    want,
+		},
+		{
+			name: "ok case - YAML",
+			file: "endpoints.yaml",
+			content: `# orders replicas
+endpoints:
+  - url: "http://10.0.0.1:8080"
+    weight: 2 # bigger instance
+  - http://10.0.0.2:8080
+`,
+			want: want,
+		},
+		{
+			name:    "nok case - invalid weight",
+			file:    "endpoints.yml",
+			content: "- url: http://10.0.0.1:8080\n  weight: two\n",
+			wantErr: true,
+		},
+		{
+			name:    "nok case - missing file",
+			file:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing.json")
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _, err := (&FileResolver{Path: path}).Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileResolver.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FileResolver.Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBalancer_Resolver(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Replica", "first")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Replica", "second")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer second.Close()
+
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	write := func(url string) {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(`[{"url": "`+url+`"}]`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(first.URL)
+
+	lb, err := NewLoadBalancer(nil, LoadBalancerConfig{
+		Resolver: &FileResolver{Path: path, PollInterval: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewLoadBalancer() error = %v", err)
+	}
+	defer lb.Close()
+	c, err := NewClient("http://orders", WithLoadBalancer(lb))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	replica := func() string {
+		resp, err := c.Get(context.Background(), "/", nil, nil)
+		if err != nil {
+			t.Fatalf("Client.Get() error = %v", err)
+		}
+		return resp.RawResponse.Header.Get("X-Replica")
+	}
+	if got := replica(); got != "first" {
+		t.Errorf("replica = %v, want first", got)
+	}
+
+	// The new endpoints are used without a new client
+	write(second.URL)
+	deadline := time.Now().Add(2 * time.Second)
+	for replica() != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("the endpoints are not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The endpoints are kept when the file is invalid
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := replica(); got != "second" {
+		t.Errorf("replica = %v, want second", got)
+	}
+
+	if _, err := NewLoadBalancer(nil, LoadBalancerConfig{Resolver: &FileResolver{Path: path}}); err == nil {
+		t.Errorf("NewLoadBalancer() with invalid file error = %v", err)
+	}
+}
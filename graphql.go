@@ -0,0 +1,395 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error codes of the Automatic Persisted Queries
+const (
+	persistedQueryNotFound     = "PersistedQueryNotFound"
+	persistedQueryNotSupported = "PersistedQueryNotSupported"
+)
+
+// GraphQLRequest is the payload of a GraphQL operation
+type GraphQLRequest struct {
+	Query         string         `json:"query,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is the location of an error in the query
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is an error of the errors array of a GraphQL response
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+func (e GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return "graphql: " + e.Message
+	}
+	path := make([]string, 0, len(e.Path))
+	for _, p := range e.Path {
+		path = append(path, fmt.Sprint(p))
+	}
+	return fmt.Sprintf("graphql: %s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// GraphQLErrors is the errors array of a GraphQL response, the data may be
+// partially decoded when this error is returned
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "graphql: no error"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+// hasCode returns true if one of the errors has the message or the code in the extensions
+func (e GraphQLErrors) hasCode(code string) bool {
+	for _, err := range e {
+		if err.Message == code {
+			return true
+		}
+		if c, ok := err.Extensions["code"].(string); ok &&
+			strings.EqualFold(strings.ReplaceAll(c, "_", ""), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// GraphQLUpload is a file to upload in the variables of an operation
+// following the GraphQL multipart request specification
+type GraphQLUpload struct {
+	FileName    string
+	ContentType string
+	io.Reader
+}
+
+// graphQLResponse is the payload of a GraphQL response
+type graphQLResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     GraphQLErrors   `json:"errors"`
+	Extensions map[string]any  `json:"extensions"`
+}
+
+// GraphQLOption is to configure a GraphQLClient
+type GraphQLOption func(*GraphQLClient)
+
+// WithPersistedQueries is to send the SHA-256 hash of the query first and the
+// query only if the server does not know it (Automatic Persisted Queries)
+func WithPersistedQueries() GraphQLOption {
+	return func(g *GraphQLClient) {
+		g.persisted = true
+	}
+}
+
+// GraphQLClient sends GraphQL operations to an endpoint with the Client
+type GraphQLClient struct {
+	client *Client
+	path   string
+
+	// Automatic Persisted Queries
+	mu        sync.Mutex
+	persisted bool
+}
+
+// NewGraphQLClient returns a GraphQLClient sending the operations on the path
+//
+// For Example:
+//
+//	gql := httpclient.NewGraphQLClient(client, "/graphql")
+//	var result struct {
+//		User struct {
+//			Name string `json:"name"`
+//		} `json:"user"`
+//	}
+//	_, err := gql.Query(ctx, `query($id: ID!) { user(id: $id) { name } }`,
+//		map[string]any{"id": "1"}, &result)
+func NewGraphQLClient(c *Client, path string, opts ...GraphQLOption) *GraphQLClient {
+	g := &GraphQLClient{
+		client: c,
+		path:   path,
+	}
+	for _, o := range opts {
+		o(g)
+	}
+	return g
+}
+
+// Query sends a query and decodes the data in the result
+func (g *GraphQLClient) Query(
+	ctx context.Context,
+	query string,
+	variables map[string]any,
+	result any,
+	opts ...RequestOption,
+) (Response, error) {
+	return g.Do(ctx, GraphQLRequest{Query: query, Variables: variables}, result, opts...)
+}
+
+// Mutate sends a mutation and decodes the data in the result
+func (g *GraphQLClient) Mutate(
+	ctx context.Context,
+	mutation string,
+	variables map[string]any,
+	result any,
+	opts ...RequestOption,
+) (Response, error) {
+	return g.Do(ctx, GraphQLRequest{Query: mutation, Variables: variables}, result, opts...)
+}
+
+// Do sends the operation and decodes the data in the result.
+// The errors array of the response is returned as GraphQLErrors and
+// the variables of type GraphQLUpload or *GraphQLUpload are sent as files, in the
+// maps, slices and arrays of the variables too.
+func (g *GraphQLClient) Do(
+	ctx context.Context,
+	request GraphQLRequest,
+	result any,
+	opts ...RequestOption,
+) (Response, error) {
+	if hasGraphQLUpload(request.Variables) {
+		body, err := newGraphQLMultipartBody(request)
+		if err != nil {
+			return Response{}, err
+		}
+		return g.send(ctx, body, result, opts)
+	}
+
+	g.mu.Lock()
+	persisted := g.persisted
+	g.mu.Unlock()
+	if !persisted || request.Query == "" {
+		return g.send(ctx, request, result, opts)
+	}
+
+	// Send the hash of the query first
+	hash := sha256.Sum256([]byte(request.Query))
+	extensions := make(map[string]any, len(request.Extensions)+1)
+	for key, value := range request.Extensions {
+		extensions[key] = value
+	}
+	extensions["persistedQuery"] = map[string]any{
+		"version":    1,
+		"sha256Hash": hex.EncodeToString(hash[:]),
+	}
+	hashed := request
+	hashed.Query = ""
+	hashed.Extensions = extensions
+
+	response, err := g.send(ctx, hashed, result, opts)
+	var errs GraphQLErrors
+	if !errors.As(err, &errs) {
+		return response, err
+	}
+	switch {
+	case errs.hasCode(persistedQueryNotSupported):
+		// Stop to use the persisted queries with this server
+		g.mu.Lock()
+		g.persisted = false
+		g.mu.Unlock()
+		return g.send(ctx, request, result, opts)
+	case errs.hasCode(persistedQueryNotFound):
+		// Register the query with its hash
+		hashed.Query = request.Query
+		return g.send(ctx, hashed, result, opts)
+	}
+	return response, err
+}
+
+// send posts the body and decodes the GraphQL response
+func (g *GraphQLClient) send(ctx context.Context, body any, result any, opts []RequestOption) (Response, error) {
+	var payload graphQLResponse
+	opts = append([]RequestOption{WithIsJson()}, opts...)
+	response, err := g.client.Post(ctx, g.path, body, &payload, &payload, opts...)
+	if err != nil {
+		return response, err
+	}
+	if len(payload.Data) > 0 && !bytes.Equal(payload.Data, []byte("null")) && result != nil {
+		if err := json.Unmarshal(payload.Data, result); err != nil {
+			return response, err
+		}
+	}
+	if len(payload.Errors) > 0 {
+		return response, payload.Errors
+	}
+	if response.RawResponse != nil && response.RawResponse.StatusCode >= http.StatusBadRequest {
+		return response, &StatusError{
+			StatusCode: response.RawResponse.StatusCode,
+			Status:     response.RawResponse.Status,
+		}
+	}
+	return response, nil
+}
+
+var graphQLUploadType = reflect.TypeOf(GraphQLUpload{})
+
+// hasGraphQLUpload returns true if one of the variables is a file, in the maps,
+// slices, arrays and pointers of any type
+func hasGraphQLUpload(value any) bool {
+	return hasGraphQLUploadValue(reflect.ValueOf(value))
+}
+
+func hasGraphQLUploadValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if v.Type() == graphQLUploadType || v.Type() == reflect.PointerTo(graphQLUploadType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return !v.IsNil() && hasGraphQLUploadValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if !mayHoldGraphQLUpload(v.Type().Elem()) {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if hasGraphQLUploadValue(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || !mayHoldGraphQLUpload(v.Type().Elem()) {
+			return false
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasGraphQLUploadValue(iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mayHoldGraphQLUpload returns false for the types which can't hold a file,
+// like the bytes of a []byte
+func mayHoldGraphQLUpload(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return t == graphQLUploadType
+}
+
+// newGraphQLMultipartBody creates the body of the GraphQL multipart request specification,
+// the files are replaced by null in the operations and referenced in the map.
+//
+//	operations: { "query": "...", "variables": { "file": null } }
+//	map: { "0": ["variables.file"] }
+//	0: the file
+func newGraphQLMultipartBody(request GraphQLRequest) (*MultipartBody, error) {
+	var files []GraphQLUpload
+	paths := map[string][]string{}
+	variables := extractGraphQLUploads(request.Variables, "variables", func(path string, file GraphQLUpload) {
+		key := strconv.Itoa(len(files))
+		files = append(files, file)
+		paths[key] = append(paths[key], path)
+	}).(map[string]any)
+	request.Variables = variables
+
+	operations, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	fileMap, err := json.Marshal(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	body := NewMultipartBody()
+	body.SetMultipartFields(
+		MultipartField{Param: "operations", Reader: bytes.NewReader(operations)},
+		MultipartField{Param: "map", Reader: bytes.NewReader(fileMap)},
+	)
+	for i, file := range files {
+		fileName := file.FileName
+		if fileName == "" {
+			fileName = "blob"
+		}
+		body.SetMultipartFields(MultipartField{
+			Param:       strconv.Itoa(i),
+			FileName:    fileName,
+			ContentType: file.ContentType,
+			Reader:      file.Reader,
+		})
+	}
+	return body, nil
+}
+
+// extractGraphQLUploads returns a copy of the value where the files are replaced by nil,
+// the values without files are kept as they are
+func extractGraphQLUploads(value any, path string, add func(string, GraphQLUpload)) any {
+	return extractGraphQLUploadsValue(reflect.ValueOf(value), path, add)
+}
+
+func extractGraphQLUploadsValue(v reflect.Value, path string, add func(string, GraphQLUpload)) any {
+	if !hasGraphQLUploadValue(v) {
+		if !v.IsValid() {
+			return nil
+		}
+		return v.Interface()
+	}
+	switch v.Type() {
+	case graphQLUploadType:
+		add(path, v.Interface().(GraphQLUpload))
+		return nil
+	case reflect.PointerTo(graphQLUploadType):
+		if !v.IsNil() {
+			add(path, *v.Interface().(*GraphQLUpload))
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return extractGraphQLUploadsValue(v.Elem(), path, add)
+	case reflect.Slice, reflect.Array:
+		copied := make([]any, v.Len())
+		for i := range copied {
+			copied[i] = extractGraphQLUploadsValue(v.Index(i), path+"."+strconv.Itoa(i), add)
+		}
+		return copied
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		// Keep the order of the files stable
+		sort.Strings(keys)
+		copied := make(map[string]any, len(keys))
+		for _, key := range keys {
+			value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+			copied[key] = extractGraphQLUploadsValue(value, path+"."+key, add)
+		}
+		return copied
+	}
+	return v.Interface()
+}
@@ -0,0 +1,224 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type User struct {
+	Name string `json:"name"`
+}
+
+func TestGraphQLClient_Do(t *testing.T) {
+	known := map[string]string{}
+	var uploaded string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, "application/graphql-response+json")
+
+		var request GraphQLRequest
+		if strings.HasPrefix(r.Header.Get(contentTypeHeaderKey), "multipart/form-data") {
+			// GraphQL multipart request
+			_ = json.Unmarshal([]byte(r.FormValue("operations")), &request)
+			var fileMap map[string][]string
+			_ = json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+			file, header, err := r.FormFile("0")
+			if err != nil || !reflect.DeepEqual(fileMap["0"], []string{"variables.input.avatar"}) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":[{"message":"invalid upload"}]}`))
+				return
+			}
+			content, _ := io.ReadAll(file)
+			uploaded = header.Filename + ":" + string(content)
+			_, _ = w.Write([]byte(`{"data":{"user":{"name":"uploaded"}}}`))
+			return
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		// Automatic Persisted Queries
+		if pq, ok := request.Extensions["persistedQuery"].(map[string]any); ok {
+			hash := pq["sha256Hash"].(string)
+			if request.Query == "" {
+				if request.Query = known[hash]; request.Query == "" {
+					_, _ = w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+					return
+				}
+			}
+			known[hash] = request.Query
+		}
+		switch {
+		case strings.Contains(request.Query, "broken"):
+			_, _ = w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"boom","locations":[{"line":1,"column":3}],"path":["user",0],"extensions":{"code":"INTERNAL"}}]}`))
+		case strings.Contains(request.Query, "user"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{"user": map[string]any{"name": request.Variables["id"]}},
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"unknown query"}]}`))
+		}
+	}))
+	defer s.Close()
+
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+	}
+
+	type result struct {
+		User User `json:"user"`
+	}
+	t.Run("ok case - query", func(t *testing.T) {
+		var got result
+		_, err := NewGraphQLClient(c, "/graphql").Query(context.Background(),
+			`query($id: ID!) { user(id: $id) { name } }`, map[string]any{"id": "john"}, &got)
+		if err != nil {
+			t.Fatalf("GraphQLClient.Query() error = %v", err)
+		}
+		if got.User.Name != "john" {
+			t.Errorf("GraphQLClient.Query() = %v, want john", got.User.Name)
+		}
+	})
+
+	t.Run("nok case - errors array", func(t *testing.T) {
+		var got result
+		_, err := NewGraphQLClient(c, "/graphql").Query(context.Background(), `{ broken }`, nil, &got)
+		var errs GraphQLErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Fatalf("GraphQLClient.Query() error = %v, want GraphQLErrors", err)
+		}
+		want := GraphQLError{
+			Message:    "boom",
+			Locations:  []GraphQLLocation{{Line: 1, Column: 3}},
+			Path:       []any{"user", float64(0)},
+			Extensions: map[string]any{"code": "INTERNAL"},
+		}
+		if !reflect.DeepEqual(errs[0], want) {
+			t.Errorf("GraphQLClient.Query() error = %#v, want %#v", errs[0], want)
+		}
+		if err.Error() != "graphql: boom (path: user.0)" {
+			t.Errorf("GraphQLErrors.Error() = %v", err.Error())
+		}
+	})
+
+	t.Run("nok case - bad request", func(t *testing.T) {
+		_, err := NewGraphQLClient(c, "/graphql").Query(context.Background(), `{ unknown }`, nil, nil)
+		var errs GraphQLErrors
+		if !errors.As(err, &errs) || errs[0].Message != "unknown query" {
+			t.Errorf("GraphQLClient.Query() error = %v, want unknown query", err)
+		}
+	})
+
+	t.Run("ok case - persisted queries", func(t *testing.T) {
+		gql := NewGraphQLClient(c, "/graphql", WithPersistedQueries())
+		for i := 0; i < 2; i++ {
+			var got result
+			_, err := gql.Query(context.Background(), `query($id: ID!) { user(id: $id) { name } }`,
+				map[string]any{"id": "apq"}, &got)
+			if err != nil {
+				t.Fatalf("GraphQLClient.Query() error = %v", err)
+			}
+			if got.User.Name != "apq" {
+				t.Errorf("GraphQLClient.Query() = %v, want apq", got.User.Name)
+			}
+		}
+		if len(known) != 1 {
+			t.Errorf("persisted queries = %v, want 1", len(known))
+		}
+	})
+
+	t.Run("ok case - upload", func(t *testing.T) {
+		var got result
+		_, err := NewGraphQLClient(c, "/graphql").Mutate(context.Background(),
+			`mutation($input: UserInput!) { updateUser(input: $input) { name } }`,
+			map[string]any{"input": map[string]any{
+				"name":   "john",
+				"avatar": GraphQLUpload{FileName: "a.png", ContentType: "image/png", Reader: strings.NewReader("png")},
+			}}, &got)
+		if err != nil {
+			t.Fatalf("GraphQLClient.Mutate() error = %v", err)
+		}
+		if uploaded != "a.png:png" || got.User.Name != "uploaded" {
+			t.Errorf("GraphQLClient.Mutate() uploaded = %v, result = %v", uploaded, got.User.Name)
+		}
+	})
+}
+
+func TestGraphQLClient_Do_Files(t *testing.T) {
+	var (
+		operations GraphQLRequest
+		fileMap    map[string][]string
+		files      []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.Unmarshal([]byte(r.FormValue("operations")), &operations)
+		_ = json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+		for i := 0; ; i++ {
+			file, header, err := r.FormFile(strconv.Itoa(i))
+			if err != nil {
+				break
+			}
+			content, _ := io.ReadAll(file)
+			files = append(files, header.Filename+":"+string(content))
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/graphql-response+json")
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+	defer s.Close()
+
+	c, err := NewClient(s.URL)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	tests := []struct {
+		name      string
+		variables map[string]any
+		wantMap   map[string][]string
+		wantFiles []string
+	}{
+		{
+			name: "ok case - slice of files",
+			variables: map[string]any{"files": []GraphQLUpload{
+				{FileName: "a.txt", Reader: strings.NewReader("a")},
+				{FileName: "b.txt", Reader: strings.NewReader("b")},
+			}},
+			wantMap:   map[string][]string{"0": {"variables.files.0"}, "1": {"variables.files.1"}},
+			wantFiles: []string{"a.txt:a", "b.txt:b"},
+		},
+		{
+			name: "ok case - slice of pointers and map of files",
+			variables: map[string]any{
+				"files": []*GraphQLUpload{{FileName: "a.txt", Reader: strings.NewReader("a")}},
+				"input": map[string]GraphQLUpload{"avatar": {FileName: "b.png", Reader: strings.NewReader("b")}},
+			},
+			wantMap:   map[string][]string{"0": {"variables.files.0"}, "1": {"variables.input.avatar"}},
+			wantFiles: []string{"a.txt:a", "b.png:b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operations, fileMap, files = GraphQLRequest{}, nil, nil
+			_, err := NewGraphQLClient(c, "/graphql").Mutate(context.Background(),
+				`mutation($files: [Upload!]!) { upload(files: $files) }`, tt.variables, nil)
+			if err != nil {
+				t.Fatalf("GraphQLClient.Mutate() error = %v", err)
+			}
+			if !reflect.DeepEqual(fileMap, tt.wantMap) {
+				t.Errorf("map = %v, want %v", fileMap, tt.wantMap)
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", files, tt.wantFiles)
+			}
+			if len(operations.Variables["files"].([]any)) == 0 || operations.Variables["files"].([]any)[0] != nil {
+				t.Errorf("operations variables = %v, want null files", operations.Variables)
+			}
+		})
+	}
+}
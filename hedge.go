@@ -0,0 +1,285 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of latencies kept to compute the delay of the hedged requests
+	hedgeWindow = 1000
+	// Number of latencies observed before hedging with a percentile
+	hedgeMinSamples = 20
+	// Maximum number of hedged requests saved in the budget
+	hedgeMaxTokens = 10
+)
+
+// Hedger sends hedged requests: when the response is slow, a duplicate of the
+// request is sent and the first successful response is returned
+type Hedger struct {
+	// Delay before a hedged request. With a Percentile, it's the delay
+	// until enough latencies are observed and no request is hedged before if it's zero.
+	Delay time.Duration
+	// Percentile of the observed latencies used as delay like 0.95,
+	// the default when Delay is zero
+	Percentile float64
+	// MaxHedges is the number of hedged requests in addition to the request, 1 by default
+	MaxHedges int
+	// BudgetPercent is the maximum percentage of the requests hedged, 10 by default
+	BudgetPercent float64
+	// Methods hedged, GET and HEAD by default. Only idempotent methods must be hedged.
+	Methods []string
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	observed  int
+	delay     time.Duration
+	tokens    float64
+	stats     HedgeStats
+}
+
+// HedgeStats are the statistics of a Hedger
+type HedgeStats struct {
+	// Requests which could be hedged
+	Requests uint64
+	// Hedges is the number of hedged requests sent
+	Hedges uint64
+	// Wins is the number of requests answered by a hedged request
+	Wins uint64
+	// Throttled is the number of hedged requests not sent because of the budget
+	Throttled uint64
+}
+
+// Stats returns the statistics of the hedged requests
+func (h *Hedger) Stats() HedgeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// hedgeable returns true if the method of the request can be hedged
+func (h *Hedger) hedgeable(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	methods := h.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// begin counts a request and returns the delay before a hedged request,
+// zero when the request isn't hedged
+func (h *Hedger) begin() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.Requests++
+	budget := h.BudgetPercent
+	if budget == 0 {
+		budget = 10
+	}
+	h.tokens += budget / 100
+	if h.tokens > hedgeMaxTokens {
+		h.tokens = hedgeMaxTokens
+	}
+
+	if h.Delay > 0 && h.Percentile == 0 {
+		return h.Delay
+	}
+	if h.observed < hedgeMinSamples {
+		return h.Delay
+	}
+	return h.delay
+}
+
+// take returns true if the budget allows another hedged request
+func (h *Hedger) take() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		h.stats.Throttled++
+		return false
+	}
+	h.tokens--
+	h.stats.Hedges++
+	return true
+}
+
+// observe records the latency of a successful response
+func (h *Hedger) observe(latency time.Duration, hedged bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hedged {
+		h.stats.Wins++
+	}
+	if h.latencies == nil {
+		h.latencies = make([]time.Duration, 0, hedgeWindow)
+	}
+	if len(h.latencies) < hedgeWindow {
+		h.latencies = append(h.latencies, latency)
+	} else {
+		h.latencies[h.next] = latency
+		h.next = (h.next + 1) % hedgeWindow
+	}
+	h.observed++
+
+	// The percentile is computed again every few latencies
+	if h.observed >= hedgeMinSamples && h.observed%(hedgeMinSamples/2) == 0 {
+		percentile := h.Percentile
+		if percentile <= 0 || percentile >= 1 {
+			percentile = 0.95
+		}
+		sorted := append([]time.Duration(nil), h.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		h.delay = sorted[int(percentile*float64(len(sorted)-1))]
+	}
+}
+
+// hedgeResult is the result of an attempt
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+	latency time.Duration
+}
+
+// success returns true if the result answers the request
+func (r hedgeResult) success() bool {
+	return r.err == nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+// discard closes the body of the result
+func (r hedgeResult) discard() {
+	if r.resp != nil {
+		_, _ = io.Copy(io.Discard, r.resp.Body)
+		_ = r.resp.Body.Close()
+	}
+}
+
+// WithHedging is a Decorator sending hedged requests, the losers are canceled.
+// A 5xx response or an error sends the next hedged request immediately.
+//
+// For Example:
+//
+//	hedger := &httpclient.Hedger{Percentile: 0.95, BudgetPercent: 5}
+//	client, err := httpclient.NewClient("https://search.internal",
+//		httpclient.WithDecorator(httpclient.WithHedging(hedger)))
+//	stats := hedger.Stats()
+func WithHedging(h *Hedger) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if !h.hedgeable(r) {
+				return d.Do(r)
+			}
+			delay := h.begin()
+			maxHedges := h.MaxHedges
+			if maxHedges <= 0 {
+				maxHedges = 1
+			}
+
+			results := make(chan hedgeResult, maxHedges+1)
+			cancels := make([]context.CancelFunc, 0, maxHedges+1)
+			send := func() bool {
+				attempt := len(cancels)
+				req := r
+				if attempt > 0 {
+					var err error
+					if req, err = rewindRequest(r); err != nil {
+						return false
+					}
+				}
+				ctx, cancel := context.WithCancel(r.Context())
+				cancels = append(cancels, cancel)
+				req = req.WithContext(ctx)
+				go func() {
+					start := time.Now()
+					resp, err := d.Do(req)
+					results <- hedgeResult{attempt: attempt, resp: resp, err: err, latency: time.Since(start)}
+				}()
+				return true
+			}
+			// cancelLosers cancels the other attempts and discards their responses
+			cancelLosers := func(winner, pending int) {
+				for i, cancel := range cancels {
+					if i != winner {
+						cancel()
+					}
+				}
+				go func() {
+					for ; pending > 0; pending-- {
+						(<-results).discard()
+					}
+				}()
+			}
+
+			send()
+			pending := 1
+			var timer <-chan time.Time
+			if delay > 0 {
+				t := time.NewTimer(delay)
+				defer t.Stop()
+				timer = t.C
+			}
+			var last hedgeResult
+			for {
+				select {
+				case result := <-results:
+					pending--
+					if result.success() {
+						h.observe(result.latency, result.attempt > 0)
+						cancelLosers(result.attempt, pending)
+						// The context of the winner is canceled with its body
+						result.resp.Body = &cancelBody{ReadCloser: result.resp.Body, cancel: cancels[result.attempt]}
+						return result.resp, nil
+					}
+					last.discard()
+					last = result
+					if len(cancels) <= maxHedges && h.take() && send() {
+						pending++
+					}
+					if pending == 0 {
+						cancelLosers(last.attempt, 0)
+						if last.resp != nil {
+							last.resp.Body = &cancelBody{ReadCloser: last.resp.Body, cancel: cancels[last.attempt]}
+						}
+						return last.resp, last.err
+					}
+				case <-timer:
+					if len(cancels) <= maxHedges && h.take() && send() {
+						pending++
+						timer = time.After(delay)
+					} else {
+						timer = nil
+					}
+				case <-r.Context().Done():
+					last.discard()
+					cancelLosers(-1, pending)
+					return nil, r.Context().Err()
+				}
+			}
+		})
+	}
+}
+
+// cancelBody cancels the context of the request when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
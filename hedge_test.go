@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_WithHedging(t *testing.T) {
+	tests := []struct {
+		name       string
+		hedger     *Hedger
+		method     string
+		slow       []bool
+		wantStatus int
+		wantCalls  int32
+		wantStats  HedgeStats
+		wantCancel bool
+	}{
+		{
+			name:       "ok case - slow request hedged and canceled",
+			hedger:     &Hedger{Delay: 20 * time.Millisecond, BudgetPercent: 100},
+			method:     http.MethodGet,
+			slow:       []bool{true, false},
+			wantStatus: http.StatusNoContent,
+			wantCalls:  2,
+			wantStats:  HedgeStats{Requests: 1, Hedges: 1, Wins: 1},
+			wantCancel: true,
+		},
+		{
+			name:       "ok case - fast request not hedged",
+			hedger:     &Hedger{Delay: 200 * time.Millisecond, BudgetPercent: 100},
+			method:     http.MethodGet,
+			slow:       []bool{false},
+			wantStatus: http.StatusNoContent,
+			wantCalls:  1,
+			wantStats:  HedgeStats{Requests: 1},
+		},
+		{
+			name:       "ok case - hedge throttled by the budget",
+			hedger:     &Hedger{Delay: 20 * time.Millisecond, BudgetPercent: 50},
+			method:     http.MethodGet,
+			slow:       []bool{true},
+			wantStatus: http.StatusNoContent,
+			wantCalls:  1,
+			wantStats:  HedgeStats{Requests: 1, Throttled: 1},
+		},
+		{
+			name:       "ok case - POST not hedged",
+			hedger:     &Hedger{Delay: 20 * time.Millisecond, BudgetPercent: 100},
+			method:     http.MethodPost,
+			slow:       []bool{true},
+			wantStatus: http.StatusNoContent,
+			wantCalls:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls    int32
+				canceled int32
+			)
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := atomic.AddInt32(&calls, 1) - 1
+				if int(call) < len(tt.slow) && tt.slow[call] {
+					select {
+					case <-r.Context().Done():
+						atomic.StoreInt32(&canceled, 1)
+						return
+					case <-time.After(300 * time.Millisecond):
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer s.Close()
+
+			c, err := NewClient(s.URL, WithDecorator(WithHedging(tt.hedger)))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var resp Response
+			if tt.method == http.MethodPost {
+				resp, err = c.Post(context.Background(), "/search", "query", nil, nil)
+			} else {
+				resp, err = c.Get(context.Background(), "/search", nil, nil)
+			}
+			if err != nil {
+				t.Fatalf("Client.Do() error = %v", err)
+			}
+			if resp.RawResponse.StatusCode != tt.wantStatus {
+				t.Errorf("Client.Do() status = %v, want %v", resp.RawResponse.StatusCode, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+			if got := tt.hedger.Stats(); got != tt.wantStats {
+				t.Errorf("Hedger.Stats() = %+v, want %+v", got, tt.wantStats)
+			}
+			if tt.wantCancel {
+				deadline := time.Now().Add(time.Second)
+				for atomic.LoadInt32(&canceled) == 0 && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+				if atomic.LoadInt32(&canceled) == 0 {
+					t.Error("the slow request is not canceled")
+				}
+			}
+		})
+	}
+}
+
+func TestHedger_Percentile(t *testing.T) {
+	h := &Hedger{Percentile: 0.9}
+	if delay := h.begin(); delay != 0 {
+		t.Errorf("Hedger.begin() before the samples = %v, want 0", delay)
+	}
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i)*time.Millisecond, false)
+	}
+	if delay := h.begin(); delay != 90*time.Millisecond {
+		t.Errorf("Hedger.begin() = %v, want 90ms", delay)
+	}
+}
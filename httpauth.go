@@ -0,0 +1,323 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	wwwAuthenticateHeaderKey    = "WWW-Authenticate"
+	proxyAuthenticateHeaderKey  = "Proxy-Authenticate"
+	proxyAuthorizationHeaderKey = "Proxy-Authorization"
+)
+
+// Digest algorithms of RFC7616 ordered by preference
+var digestAlgorithms = []string{"SHA-256", "SHA-256-SESS", "SHA-512-256", "SHA-512-256-SESS", "MD5", "MD5-SESS"}
+
+// HTTPAuthenticator answers to the Basic and Digest challenges of the servers or of the proxies
+type HTTPAuthenticator struct {
+	Username string
+	Password string
+	// Proxy answers to the Proxy-Authenticate challenges of the 407 responses
+	// instead of the WWW-Authenticate challenges of the 401 responses.
+	// The requests tunneled with CONNECT are authenticated by the transport.
+	Proxy bool
+	// CNonce generates the client nonce of Digest, 16 random bytes by default
+	CNonce func() string
+
+	mu        sync.Mutex
+	challenge *authChallenge
+	nc        uint32
+}
+
+// authChallenge is a challenge of the WWW-Authenticate or Proxy-Authenticate headers
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+func (a *HTTPAuthenticator) statusCode() int {
+	if a.Proxy {
+		return http.StatusProxyAuthRequired
+	}
+	return http.StatusUnauthorized
+}
+
+func (a *HTTPAuthenticator) challengeHeaderKey() string {
+	if a.Proxy {
+		return proxyAuthenticateHeaderKey
+	}
+	return wwwAuthenticateHeaderKey
+}
+
+func (a *HTTPAuthenticator) authorizationHeaderKey() string {
+	if a.Proxy {
+		return proxyAuthorizationHeaderKey
+	}
+	return authorizationHeaderKey
+}
+
+func (a *HTTPAuthenticator) cnonce() string {
+	if a.CNonce != nil {
+		return a.CNonce()
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setChallenge keeps the challenge for the next requests and resets the nonce count
+func (a *HTTPAuthenticator) setChallenge(challenge *authChallenge) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.challenge = challenge
+	a.nc = 0
+}
+
+// authorize adds the credentials of the last challenge to the request,
+// the nonce of Digest is reused with the next nonce count
+func (a *HTTPAuthenticator) authorize(r *http.Request) (bool, error) {
+	a.mu.Lock()
+	challenge := a.challenge
+	if challenge == nil {
+		a.mu.Unlock()
+		return false, nil
+	}
+	a.nc++
+	nc := a.nc
+	a.mu.Unlock()
+
+	if challenge.scheme == "basic" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+		r.Header.Set(a.authorizationHeaderKey(), "Basic "+credentials)
+		return true, nil
+	}
+	authorization, err := a.digestAuthorization(r, challenge.params, nc)
+	if err != nil {
+		return false, err
+	}
+	r.Header.Set(a.authorizationHeaderKey(), authorization)
+	return true, nil
+}
+
+// digestAuthorization computes the credentials of the section 3.4 of RFC7616
+func (a *HTTPAuthenticator) digestAuthorization(r *http.Request, params map[string]string, nc uint32) (string, error) {
+	algorithm := strings.ToUpper(defaultString(params["algorithm"], "MD5"))
+	h := digestHashFunc(strings.TrimSuffix(algorithm, "-SESS"))
+	if h == nil {
+		return "", fmt.Errorf("httpclient: unsupported digest algorithm %s", algorithm)
+	}
+	H := func(s string) string {
+		hh := h()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	realm, nonce := params["realm"], params["nonce"]
+	cnonce := a.cnonce()
+	ha1 := H(a.Username + ":" + realm + ":" + a.Password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = H(ha1 + ":" + nonce + ":" + cnonce)
+	}
+
+	// auth is preferred, auth-int only when it is the only quality of protection
+	qop := ""
+	for _, offered := range strings.Split(params["qop"], ",") {
+		switch strings.TrimSpace(offered) {
+		case "auth":
+			qop = "auth"
+		case "auth-int":
+			if qop == "" {
+				qop = "auth-int"
+			}
+		}
+	}
+
+	uri := r.URL.RequestURI()
+	ha2 := H(r.Method + ":" + uri)
+	if qop == "auth-int" {
+		body, err := requestBody(r)
+		if err != nil {
+			return "", err
+		}
+		ha2 = H(r.Method + ":" + uri + ":" + H(string(body)))
+	}
+
+	ncValue := fmt.Sprintf("%08x", nc)
+	response := H(ha1 + ":" + nonce + ":" + ha2)
+	if qop != "" {
+		response = H(ha1 + ":" + nonce + ":" + ncValue + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	username := a.Username
+	if strings.EqualFold(params["userhash"], "true") {
+		username = H(a.Username + ":" + realm)
+	}
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		escapeQuotes(username), escapeQuotes(realm), escapeQuotes(nonce), escapeQuotes(uri), algorithm, response)
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, ncValue, escapeQuotes(cnonce))
+	}
+	if opaque, ok := params["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, escapeQuotes(opaque))
+	}
+	if strings.EqualFold(params["userhash"], "true") {
+		authorization += ", userhash=true"
+	}
+	return authorization, nil
+}
+
+func digestHashFunc(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	default:
+		return nil
+	}
+}
+
+// selectChallenge returns the strongest supported challenge,
+// Digest with the best algorithm or else Basic
+func selectChallenge(challenges []authChallenge) (*authChallenge, bool) {
+	var basic *authChallenge
+	best := len(digestAlgorithms)
+	var digest *authChallenge
+	for i := range challenges {
+		challenge := &challenges[i]
+		switch challenge.scheme {
+		case "basic":
+			if basic == nil {
+				basic = challenge
+			}
+		case "digest":
+			algorithm := strings.ToUpper(defaultString(challenge.params["algorithm"], "MD5"))
+			for rank, supported := range digestAlgorithms {
+				if algorithm == supported && rank < best {
+					best, digest = rank, challenge
+				}
+			}
+		}
+	}
+	if digest != nil {
+		return digest, true
+	}
+	return basic, basic != nil
+}
+
+// parseAuthChallenges parses the challenges of the section 11.6.1 of RFC9110,
+// a header can have several challenges separated by commas
+func parseAuthChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, value := range values {
+		i := 0
+		for i < len(value) {
+			// Skip the separators
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t' || value[i] == ',') {
+				i++
+			}
+			start := i
+			for i < len(value) && value[i] != ' ' && value[i] != '\t' && value[i] != ',' && value[i] != '=' {
+				i++
+			}
+			token := value[start:i]
+			if token == "" {
+				i++
+				continue
+			}
+			j := i
+			for j < len(value) && (value[j] == ' ' || value[j] == '\t') {
+				j++
+			}
+			if j >= len(value) || value[j] != '=' || len(challenges) == 0 {
+				challenges = append(challenges, authChallenge{scheme: strings.ToLower(token), params: map[string]string{}})
+				continue
+			}
+
+			// Parameter of the current challenge
+			i = j + 1
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+				i++
+			}
+			var param strings.Builder
+			if i < len(value) && value[i] == '"' {
+				for i++; i < len(value) && value[i] != '"'; i++ {
+					if value[i] == '\\' && i+1 < len(value) {
+						i++
+					}
+					param.WriteByte(value[i])
+				}
+				i++
+			} else {
+				for ; i < len(value) && value[i] != ',' && value[i] != ' ' && value[i] != '\t'; i++ {
+					param.WriteByte(value[i])
+				}
+			}
+			challenges[len(challenges)-1].params[strings.ToLower(token)] = param.String()
+		}
+	}
+	return challenges
+}
+
+// WithHTTPAuth is a Decorator answering to the Basic and Digest challenges of RFC7617
+// and RFC7616. The request is replayed after the challenge when its body can be rewound
+// and the challenge is kept to authenticate the next requests without a round trip.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("http://appliance.local",
+//		httpclient.WithDecorator(httpclient.WithHTTPAuth(&httpclient.HTTPAuthenticator{
+//			Username: "admin",
+//			Password: "secret",
+//		})))
+func WithHTTPAuth(auth *HTTPAuthenticator) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			req := r.Clone(r.Context())
+			authorized, err := auth.authorize(req)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := d.Do(req)
+
+			// Answer to a new challenge or to a stale nonce
+			for retry := 0; retry < 2; retry++ {
+				if err != nil || resp.StatusCode != auth.statusCode() {
+					return resp, err
+				}
+				challenge, ok := selectChallenge(parseAuthChallenges(resp.Header.Values(auth.challengeHeaderKey())))
+				if !ok || (authorized && !strings.EqualFold(challenge.params["stale"], "true")) {
+					return resp, nil
+				}
+				if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+					return resp, nil
+				}
+				auth.setChallenge(challenge)
+				req, err = rewindRequest(r)
+				if err != nil {
+					return resp, nil
+				}
+				if authorized, err = auth.authorize(req); err != nil {
+					return resp, nil
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				resp, err = d.Do(req)
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPAuthenticator_digestAuthorization(t *testing.T) {
+	// Examples of the section 3.9.1 of RFC7616
+	params := map[string]string{
+		"realm":  "http-auth@example.org",
+		"qop":    "auth, auth-int",
+		"nonce":  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		"opaque": "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+	}
+	tests := []struct {
+		name      string
+		algorithm string
+		want      string
+	}{
+		{
+			name:      "MD5",
+			algorithm: "MD5",
+			want:      `response="8ca523f5e9506fed4657c9700eebdbec"`,
+		},
+		{
+			name:      "SHA-256",
+			algorithm: "SHA-256",
+			want:      `response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &HTTPAuthenticator{
+				Username: "Mufasa",
+				Password: "Circle of Life",
+				CNonce:   func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" },
+			}
+			r, _ := http.NewRequest(http.MethodGet, "http://www.example.org/dir/index.html", nil)
+			challenge := map[string]string{"algorithm": tt.algorithm}
+			for k, v := range params {
+				challenge[k] = v
+			}
+			got, err := a.digestAuthorization(r, challenge, 1)
+			if err != nil {
+				t.Fatalf("HTTPAuthenticator.digestAuthorization() error = %v", err)
+			}
+			if !strings.Contains(got, tt.want) || !strings.Contains(got, `qop=auth, nc=00000001`) ||
+				!strings.Contains(got, `opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`) {
+				t.Errorf("HTTPAuthenticator.digestAuthorization() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuthChallenges(t *testing.T) {
+	got := parseAuthChallenges([]string{
+		`Basic realm="simple", Digest realm="x, y", qop="auth,auth-int", algorithm=MD5, nonce="abc"`,
+		`Digest realm="x", algorithm=SHA-256, nonce="def", stale=true`,
+	})
+	if len(got) != 3 || got[0].scheme != "basic" || got[1].params["realm"] != "x, y" ||
+		got[1].params["qop"] != "auth,auth-int" || got[2].params["stale"] != "true" {
+		t.Fatalf("parseAuthChallenges() = %+v", got)
+	}
+	if challenge, ok := selectChallenge(got); !ok || challenge.params["nonce"] != "def" {
+		t.Errorf("selectChallenge() = %+v, %v", challenge, ok)
+	}
+}
+
+// digestServer is a server with Digest MD5 auth-int and a nonce stale after three requests
+func digestServer(challenges *int32) *httptest.Server {
+	var nonce int32
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		current := "nonce" + string(rune('0'+atomic.LoadInt32(&nonce)))
+		params := map[string]string{}
+		if challenge := parseAuthChallenges(r.Header.Values(authorizationHeaderKey)); len(challenge) == 1 {
+			params = challenge[0].params
+		}
+		ha1 := h("admin:appliance:secret")
+		ha2 := h(r.Method + ":" + r.URL.RequestURI() + ":" + h(string(body)))
+		want := h(ha1 + ":" + params["nonce"] + ":" + params["nc"] + ":" + params["cnonce"] + ":auth-int:" + ha2)
+		switch {
+		case params["response"] == want && params["nonce"] == current && params["nc"] < "00000003":
+			_, _ = w.Write([]byte("ok"))
+		case params["response"] == want && params["nc"] == "00000003":
+			atomic.AddInt32(&nonce, 1)
+			current = "nonce" + string(rune('0'+atomic.LoadInt32(&nonce)))
+			atomic.AddInt32(challenges, 1)
+			w.Header().Set(wwwAuthenticateHeaderKey, `Digest realm="appliance", qop="auth-int", nonce="`+current+`", stale=true`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			atomic.AddInt32(challenges, 1)
+			w.Header().Add(wwwAuthenticateHeaderKey, `Basic realm="appliance"`)
+			w.Header().Add(wwwAuthenticateHeaderKey, `Digest realm="appliance", qop="auth-int", nonce="`+current+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+}
+
+func TestWithHTTPAuth(t *testing.T) {
+	t.Run("ok case - digest with nonce reuse and stale nonce", func(t *testing.T) {
+		var challenges int32
+		s := digestServer(&challenges)
+		defer s.Close()
+		doer := WithHTTPAuth(&HTTPAuthenticator{Username: "admin", Password: "secret"})(&http.Client{})
+		for i := 0; i < 4; i++ {
+			r, _ := http.NewRequest(http.MethodPost, s.URL+"/config", strings.NewReader("body"))
+			resp, err := doer.Do(r)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("Do() request %d = %v, %v", i, resp, err)
+			}
+			resp.Body.Close()
+		}
+		// One challenge for the first request and one for the stale nonce
+		if challenges != 2 {
+			t.Errorf("Do() challenges = %v, want 2", challenges)
+		}
+	})
+
+	t.Run("nok case - wrong password", func(t *testing.T) {
+		var challenges int32
+		s := digestServer(&challenges)
+		defer s.Close()
+		doer := WithHTTPAuth(&HTTPAuthenticator{Username: "admin", Password: "wrong"})(&http.Client{})
+		r, _ := http.NewRequest(http.MethodGet, s.URL+"/config", nil)
+		resp, err := doer.Do(r)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || challenges != 2 {
+			t.Errorf("Do() = %v, %v, challenges %v", resp, err, challenges)
+		}
+	})
+
+	t.Run("ok case - basic proxy", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, password, ok := parseBasicAuth(r.Header.Get(proxyAuthorizationHeaderKey)); !ok || user != "proxy" || password != "pass" {
+				w.Header().Set(proxyAuthenticateHeaderKey, `Basic realm="proxy"`)
+				w.WriteHeader(http.StatusProxyAuthRequired)
+			}
+		}))
+		defer s.Close()
+		doer := WithHTTPAuth(&HTTPAuthenticator{Username: "proxy", Password: "pass", Proxy: true})(&http.Client{})
+		r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		resp, err := doer.Do(r)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("Do() = %v, %v", resp, err)
+		}
+	})
+}
+
+func parseBasicAuth(authorization string) (string, string, bool) {
+	r := &http.Request{Header: http.Header{authorizationHeaderKey: {authorization}}}
+	return r.BasicAuth()
+}
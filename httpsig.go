@@ -0,0 +1,881 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPSignatureAlgorithm is an algorithm of the HTTP Message Signatures of RFC9421
+type HTTPSignatureAlgorithm string
+
+const (
+	// SignatureHMACSHA256 is HMAC using SHA-256
+	SignatureHMACSHA256 HTTPSignatureAlgorithm = "hmac-sha256"
+	// SignatureEd25519 is EdDSA using curve Ed25519
+	SignatureEd25519 HTTPSignatureAlgorithm = "ed25519"
+	// SignatureECDSAP256SHA256 is ECDSA using curve P-256 and SHA-256
+	SignatureECDSAP256SHA256 HTTPSignatureAlgorithm = "ecdsa-p256-sha256"
+	// SignatureRSAPSSSHA512 is RSASSA-PSS using SHA-512
+	SignatureRSAPSSSHA512 HTTPSignatureAlgorithm = "rsa-pss-sha512"
+)
+
+// Digest algorithms of the Content-Digest header of RFC9530
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+)
+
+const (
+	signatureHeaderKey      = "Signature"
+	signatureInputHeaderKey = "Signature-Input"
+	contentDigestHeaderKey  = "Content-Digest"
+
+	defaultSignatureLabel = "sig1"
+	// defaultSignatureMaxBodySize is the largest body read to verify a response
+	defaultSignatureMaxBodySize = 10 << 20
+)
+
+var (
+	ErrHTTPSignatureMissing  = errors.New("httpclient: missing HTTP message signature")
+	ErrHTTPSignatureInvalid  = errors.New("httpclient: invalid HTTP message signature")
+	ErrHTTPSignatureExpired  = errors.New("httpclient: expired HTTP message signature")
+	ErrContentDigestMismatch = errors.New("httpclient: content digest mismatch")
+)
+
+// HTTPSigner signs the requests with the HTTP Message Signatures of RFC9421
+type HTTPSigner struct {
+	// Label of the signature, sig1 by default
+	Label string
+	// KeyID sent in the keyid parameter
+	KeyID string
+	// Algorithm of the signature, deduced from the key when empty.
+	// The alg parameter is sent only when it is set
+	Algorithm HTTPSignatureAlgorithm
+	// Secret of the hmac-sha256 algorithm
+	Secret []byte
+	// Key of the ed25519, ecdsa-p256-sha256 and rsa-pss-sha512 algorithms
+	Key crypto.Signer
+	// Components covered by the signature, by default @method, @target-uri
+	// and content-digest when the request has a body
+	Components []string
+	// DigestAlgorithm of the Content-Digest header added to the requests
+	// with a body, sha-256 by default
+	DigestAlgorithm string
+	// Expires is the validity of the signature, no expiration when zero
+	Expires time.Duration
+	// Nonce generates the nonce parameter, no nonce when nil
+	Nonce func() string
+	// Tag is the application specific tag parameter
+	Tag string
+	// Now is the clock of the signer, time.Now by default
+	Now func() time.Time
+}
+
+func (s *HTTPSigner) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *HTTPSigner) algorithm() (HTTPSignatureAlgorithm, error) {
+	if s.Algorithm != "" {
+		return s.Algorithm, nil
+	}
+	if s.Key == nil {
+		if len(s.Secret) == 0 {
+			return "", errors.New("httpclient: missing key to sign the request")
+		}
+		return SignatureHMACSHA256, nil
+	}
+	switch pub := s.Key.Public().(type) {
+	case ed25519.PublicKey:
+		return SignatureEd25519, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", fmt.Errorf("httpclient: unsupported curve %s", pub.Curve.Params().Name)
+		}
+		return SignatureECDSAP256SHA256, nil
+	case *rsa.PublicKey:
+		return SignatureRSAPSSSHA512, nil
+	default:
+		return "", fmt.Errorf("httpclient: unsupported key %T", pub)
+	}
+}
+
+// Sign adds the Content-Digest, Signature-Input and Signature headers to the request,
+// the body is read to be digested and replaced if the request can't be rewound
+func (s *HTTPSigner) Sign(r *http.Request) error {
+	alg, err := s.algorithm()
+	if err != nil {
+		return err
+	}
+	hasBody := r.Body != nil && r.Body != http.NoBody
+	components := s.Components
+	if len(components) == 0 {
+		components = []string{"@method", "@target-uri"}
+		if hasBody {
+			components = append(components, "content-digest")
+		}
+	}
+
+	if r.Header.Get(contentDigestHeaderKey) == "" && (hasBody || containsFold(components, "content-digest")) {
+		body, err := requestBody(r)
+		if err != nil {
+			return err
+		}
+		digest, err := contentDigest(defaultString(s.DigestAlgorithm, DigestSHA256), body)
+		if err != nil {
+			return err
+		}
+		r.Header.Set(contentDigestHeaderKey, digest)
+	}
+
+	// Serialize the signature parameters
+	var params strings.Builder
+	params.WriteByte('(')
+	for i, component := range components {
+		if i > 0 {
+			params.WriteByte(' ')
+		}
+		params.WriteString(sfString(strings.ToLower(component)))
+	}
+	params.WriteByte(')')
+	created := s.now()
+	params.WriteString(";created=" + strconv.FormatInt(created.Unix(), 10))
+	if s.Expires > 0 {
+		params.WriteString(";expires=" + strconv.FormatInt(created.Add(s.Expires).Unix(), 10))
+	}
+	if s.KeyID != "" {
+		params.WriteString(";keyid=" + sfString(s.KeyID))
+	}
+	if s.Algorithm != "" {
+		params.WriteString(";alg=" + sfString(string(s.Algorithm)))
+	}
+	if s.Nonce != nil {
+		params.WriteString(";nonce=" + sfString(s.Nonce()))
+	}
+	if s.Tag != "" {
+		params.WriteString(";tag=" + sfString(s.Tag))
+	}
+
+	message := signatureMessage{request: r}
+	identifiers := make([]string, 0, len(components))
+	for _, component := range components {
+		identifiers = append(identifiers, sfString(strings.ToLower(component)))
+	}
+	base, err := message.signatureBase(identifiers, params.String())
+	if err != nil {
+		return err
+	}
+
+	var signature []byte
+	switch alg {
+	case SignatureHMACSHA256:
+		signature = hmacSHA256(s.Secret, base)
+	case SignatureEd25519, SignatureECDSAP256SHA256, SignatureRSAPSSSHA512:
+		if s.Key == nil {
+			return errors.New("httpclient: missing private key to sign the request")
+		}
+		signature, err = signHTTPSignature(s.Key, alg, []byte(base))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("httpclient: unsupported signature algorithm %s", alg)
+	}
+
+	label := defaultString(s.Label, defaultSignatureLabel)
+	r.Header.Set(signatureInputHeaderKey, label+"="+params.String())
+	r.Header.Set(signatureHeaderKey, label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// WithHTTPSignature is a Decorator signing the requests with the HTTP Message Signatures
+// of RFC9421. It must be the last Decorator to sign the headers added by the other ones.
+//
+// For Example:
+//
+//	signer := &httpclient.HTTPSigner{
+//		KeyID:      "my-key",
+//		Key:        privateKey,
+//		Components: []string{"@method", "@target-uri", "content-type", "content-digest"},
+//	}
+//	client, err := httpclient.NewClient("https://api.partner.com",
+//		httpclient.WithDecorator(httpclient.WithHTTPSignature(signer)))
+func WithHTTPSignature(signer *HTTPSigner) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			signed := r.Clone(r.Context())
+			if err := signer.Sign(signed); err != nil {
+				return nil, err
+			}
+			return d.Do(signed)
+		})
+	}
+}
+
+// HTTPVerifier verifies the HTTP Message Signatures of RFC9421
+type HTTPVerifier struct {
+	// Label of the signature to verify, the first signature by default
+	Label string
+	// Key returns the key of the keyid parameter, a []byte for hmac-sha256
+	// or a crypto.PublicKey. The algorithm is empty when the alg parameter is missing
+	Key func(keyID string, alg HTTPSignatureAlgorithm) (any, error)
+	// Components which must be covered by the signature
+	Components []string
+	// MaxAge of the signature from its creation, no limit when zero
+	MaxAge time.Duration
+	// Now is the clock of the verifier, time.Now by default
+	Now func() time.Time
+	// MaxBodySize of the responses read by WithHTTPSignatureVerification to verify them,
+	// 10 MiB when zero or negative
+	MaxBodySize int64
+}
+
+func (v *HTTPVerifier) maxBodySize() int64 {
+	if v.MaxBodySize > 0 {
+		return v.MaxBodySize
+	}
+	return defaultSignatureMaxBodySize
+}
+
+func (v *HTTPVerifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// VerifyResponse verifies the signature and the Content-Digest of the response
+// with its body already read
+func (v *HTTPVerifier) VerifyResponse(resp *http.Response, body []byte) error {
+	return v.verify(signatureMessage{request: resp.Request, response: resp}, resp.Header, body)
+}
+
+// VerifyRequest verifies the signature and the Content-Digest of the request
+// with its body already read
+func (v *HTTPVerifier) VerifyRequest(r *http.Request, body []byte) error {
+	return v.verify(signatureMessage{request: r}, r.Header, body)
+}
+
+func (v *HTTPVerifier) verify(message signatureMessage, header http.Header, body []byte) error {
+	inputs, err := parseSFDictionary(strings.Join(header.Values(signatureInputHeaderKey), ", "))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHTTPSignatureInvalid, err)
+	}
+	signatures, err := parseSFDictionary(strings.Join(header.Values(signatureHeaderKey), ", "))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHTTPSignatureInvalid, err)
+	}
+	if len(inputs) == 0 {
+		return ErrHTTPSignatureMissing
+	}
+	label := v.Label
+	if label == "" {
+		label = inputs[0].key
+	}
+	input, ok := findSFMember(inputs, label)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHTTPSignatureMissing, label)
+	}
+	signature, ok := findSFMember(signatures, label)
+	if !ok || !signature.bytes {
+		return fmt.Errorf("%w: missing signature %s", ErrHTTPSignatureInvalid, label)
+	}
+	if input.inner == nil {
+		return fmt.Errorf("%w: signature input %s is not an inner list", ErrHTTPSignatureInvalid, label)
+	}
+
+	// Check the covered components and the parameters
+	identifiers := make([]string, 0, len(input.inner))
+	covered := make([]string, 0, len(input.inner))
+	for _, item := range input.inner {
+		identifiers = append(identifiers, item.raw)
+		covered = append(covered, item.value)
+	}
+	for _, component := range v.Components {
+		if !containsFold(covered, component) {
+			return fmt.Errorf("%w: component %s is not covered", ErrHTTPSignatureInvalid, component)
+		}
+	}
+	now := v.now()
+	if expires, ok := input.params["expires"]; ok {
+		t, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expires %s", ErrHTTPSignatureInvalid, expires)
+		}
+		if now.Unix() > t {
+			return ErrHTTPSignatureExpired
+		}
+	}
+	if v.MaxAge > 0 {
+		t, err := strconv.ParseInt(input.params["created"], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid created %s", ErrHTTPSignatureInvalid, input.params["created"])
+		}
+		if now.Sub(time.Unix(t, 0)) > v.MaxAge {
+			return ErrHTTPSignatureExpired
+		}
+	}
+
+	if v.Key == nil {
+		return errors.New("httpclient: missing key function to verify the signature")
+	}
+	alg := HTTPSignatureAlgorithm(input.params["alg"])
+	key, err := v.Key(input.params["keyid"], alg)
+	if err != nil {
+		return err
+	}
+	base, err := message.signatureBase(identifiers, input.raw)
+	if err != nil {
+		return err
+	}
+	if err := verifyHTTPSignature(key, alg, []byte(base), []byte(signature.value)); err != nil {
+		return err
+	}
+
+	if header.Get(contentDigestHeaderKey) != "" {
+		return verifyContentDigest(header.Get(contentDigestHeaderKey), body)
+	}
+	return nil
+}
+
+// WithHTTPSignatureVerification is a Decorator verifying the HTTP Message Signatures
+// and the Content-Digest of the responses. The body is read to be verified, a body
+// larger than the MaxBodySize of the verifier returns ErrResponseBodyTooLarge.
+//
+// For Example:
+//
+//	verifier := &httpclient.HTTPVerifier{
+//		Key: func(keyID string, alg httpclient.HTTPSignatureAlgorithm) (any, error) {
+//			return partnerPublicKey, nil
+//		},
+//		Components: []string{"@status", "content-digest"},
+//	}
+//	client, err := httpclient.NewClient("https://api.partner.com",
+//		httpclient.WithDecorator(httpclient.WithHTTPSignatureVerification(verifier)))
+func WithHTTPSignatureVerification(verifier *HTTPVerifier) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := d.Do(r)
+			if err != nil {
+				return resp, err
+			}
+			maxSize := verifier.maxBodySize()
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+			_ = resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(body)) > maxSize {
+				return nil, ErrResponseBodyTooLarge
+			}
+			if resp.Request == nil {
+				resp.Request = r
+			}
+			if err := verifier.VerifyResponse(resp, body); err != nil {
+				return nil, err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			return resp, nil
+		})
+	}
+}
+
+// signatureMessage is the request or the response to sign
+type signatureMessage struct {
+	request  *http.Request
+	response *http.Response
+}
+
+// signatureBase builds the signature base of the section 2.5 of RFC9421
+func (m signatureMessage) signatureBase(identifiers []string, params string) (string, error) {
+	var base strings.Builder
+	for _, identifier := range identifiers {
+		value, err := m.componentValue(identifier)
+		if err != nil {
+			return "", err
+		}
+		base.WriteString(identifier + ": " + value + "\n")
+	}
+	base.WriteString(`"@signature-params": ` + params)
+	return base.String(), nil
+}
+
+// componentValue returns the value of a serialized component identifier
+func (m signatureMessage) componentValue(identifier string) (string, error) {
+	items, err := parseSFInnerList("(" + identifier + ")")
+	if err != nil || len(items) != 1 {
+		return "", fmt.Errorf("%w: invalid component %s", ErrHTTPSignatureInvalid, identifier)
+	}
+	name := items[0].value
+	r, resp := m.request, m.response
+	for param := range items[0].params {
+		if param != "req" {
+			return "", fmt.Errorf("%w: unsupported component parameter %s", ErrHTTPSignatureInvalid, param)
+		}
+		if resp == nil {
+			return "", fmt.Errorf("%w: component %s of a request", ErrHTTPSignatureInvalid, identifier)
+		}
+		resp = nil
+	}
+	if r == nil {
+		return "", fmt.Errorf("%w: missing request of the component %s", ErrHTTPSignatureInvalid, identifier)
+	}
+
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return requestScheme(r) + "://" + requestAuthority(r) + r.URL.RequestURI(), nil
+	case "@authority":
+		return requestAuthority(r), nil
+	case "@scheme":
+		return requestScheme(r), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		return defaultString(r.URL.EscapedPath(), "/"), nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	case "@status":
+		if resp == nil {
+			return "", fmt.Errorf("%w: component @status of a request", ErrHTTPSignatureInvalid)
+		}
+		return strconv.Itoa(resp.StatusCode), nil
+	}
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("%w: unsupported component %s", ErrHTTPSignatureInvalid, name)
+	}
+
+	header, contentLength := r.Header, r.ContentLength
+	if resp != nil {
+		header, contentLength = resp.Header, resp.ContentLength
+	}
+	values := header.Values(name)
+	switch {
+	case len(values) > 0:
+	case name == "host" && resp == nil:
+		values = []string{requestAuthority(r)}
+	case name == "content-length" && contentLength >= 0:
+		values = []string{strconv.FormatInt(contentLength, 10)}
+	default:
+		return "", fmt.Errorf("%w: missing header %s", ErrHTTPSignatureInvalid, name)
+	}
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		trimmed = append(trimmed, strings.TrimSpace(value))
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestAuthority returns the host of the request without the default port
+func requestAuthority(r *http.Request) string {
+	host := strings.ToLower(defaultString(r.Host, r.URL.Host))
+	switch scheme := requestScheme(r); {
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+func signHTTPSignature(key crypto.Signer, alg HTTPSignatureAlgorithm, base []byte) ([]byte, error) {
+	switch alg {
+	case SignatureEd25519:
+		return key.Sign(rand.Reader, base, crypto.Hash(0))
+	case SignatureECDSAP256SHA256:
+		digest := sha256.Sum256(base)
+		der, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		// The signature is the concatenation of r and s instead of ASN.1
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &sig); err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		sig.R.FillBytes(signature[:32])
+		sig.S.FillBytes(signature[32:])
+		return signature, nil
+	default:
+		digest := sha512.Sum512(base)
+		return key.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512})
+	}
+}
+
+func verifyHTTPSignature(key any, alg HTTPSignatureAlgorithm, base, signature []byte) error {
+	var valid bool
+	switch k := key.(type) {
+	case []byte:
+		if alg != "" && alg != SignatureHMACSHA256 {
+			return fmt.Errorf("%w: algorithm %s with a secret", ErrHTTPSignatureInvalid, alg)
+		}
+		valid = hmac.Equal(hmacSHA256(k, string(base)), signature)
+	case ed25519.PublicKey:
+		if alg != "" && alg != SignatureEd25519 {
+			return fmt.Errorf("%w: algorithm %s with an ed25519 key", ErrHTTPSignatureInvalid, alg)
+		}
+		valid = ed25519.Verify(k, base, signature)
+	case *ecdsa.PublicKey:
+		if (alg != "" && alg != SignatureECDSAP256SHA256) || k.Curve != elliptic.P256() {
+			return fmt.Errorf("%w: algorithm %s with an ecdsa key", ErrHTTPSignatureInvalid, alg)
+		}
+		if len(signature) != 64 {
+			return ErrHTTPSignatureInvalid
+		}
+		digest := sha256.Sum256(base)
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(k, digest[:], r, s)
+	case *rsa.PublicKey:
+		if alg != "" && alg != SignatureRSAPSSSHA512 {
+			return fmt.Errorf("%w: algorithm %s with a rsa key", ErrHTTPSignatureInvalid, alg)
+		}
+		digest := sha512.Sum512(base)
+		valid = rsa.VerifyPSS(k, crypto.SHA512, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+	default:
+		return fmt.Errorf("httpclient: unsupported key %T", key)
+	}
+	if !valid {
+		return ErrHTTPSignatureInvalid
+	}
+	return nil
+}
+
+func digestHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("httpclient: unsupported digest algorithm %s", alg)
+	}
+}
+
+// contentDigest returns the value of the Content-Digest header of RFC9530
+func contentDigest(alg string, body []byte) (string, error) {
+	h, err := digestHash(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(body)
+	return alg + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
+
+// verifyContentDigest checks the supported digests of the Content-Digest header
+func verifyContentDigest(value string, body []byte) error {
+	digests, err := parseSFDictionary(value)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrContentDigestMismatch, err)
+	}
+	verified := false
+	for _, digest := range digests {
+		h, err := digestHash(digest.key)
+		if err != nil {
+			continue
+		}
+		h.Write(body)
+		if !digest.bytes || !hmac.Equal(h.Sum(nil), []byte(digest.value)) {
+			return ErrContentDigestMismatch
+		}
+		verified = true
+	}
+	if !verified {
+		return fmt.Errorf("%w: no supported digest algorithm", ErrContentDigestMismatch)
+	}
+	return nil
+}
+
+// requestBody returns the body of the request, the body is replaced
+// if it can't be read again with GetBody
+func requestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+	return payload, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sfString serializes a string of the Structured Field Values of RFC8941
+func sfString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sfItem is an item or an inner list of the Structured Field Values of RFC8941
+type sfItem struct {
+	key string
+	// value of a string, a token, a number or a decoded byte sequence
+	value string
+	// bytes is true when the value is a byte sequence
+	bytes bool
+	// inner are the items of an inner list
+	inner  []sfItem
+	params map[string]string
+	// raw is the serialization of the item with its parameters
+	raw string
+}
+
+func findSFMember(members []sfItem, key string) (sfItem, bool) {
+	for i := len(members) - 1; i >= 0; i-- {
+		if members[i].key == key {
+			return members[i], true
+		}
+	}
+	return sfItem{}, false
+}
+
+// sfParser is a minimal parser of the dictionaries of RFC8941
+type sfParser struct {
+	s string
+	i int
+}
+
+func parseSFDictionary(s string) ([]sfItem, error) {
+	p := &sfParser{s: s}
+	var members []sfItem
+	for {
+		p.skipOWS()
+		if p.i >= len(p.s) {
+			return members, nil
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var item sfItem
+		if p.peek() == '=' {
+			p.i++
+			item, err = p.item()
+		} else {
+			item = sfItem{value: "?1"}
+			item.params, err = p.params()
+		}
+		if err != nil {
+			return nil, err
+		}
+		item.key = key
+		members = append(members, item)
+		p.skipOWS()
+		if p.i >= len(p.s) {
+			return members, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("unexpected %q at %d", p.peek(), p.i)
+		}
+		p.i++
+		p.skipOWS()
+		if p.i >= len(p.s) {
+			return nil, errors.New("trailing comma")
+		}
+	}
+}
+
+func parseSFInnerList(s string) ([]sfItem, error) {
+	p := &sfParser{s: s}
+	item, err := p.item()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(p.s) || item.inner == nil {
+		return nil, errors.New("invalid inner list")
+	}
+	return item.inner, nil
+}
+
+func (p *sfParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *sfParser) skipOWS() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", fmt.Errorf("invalid key at %d", p.i)
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) item() (sfItem, error) {
+	start := p.i
+	var (
+		item sfItem
+		err  error
+	)
+	if p.peek() == '(' {
+		p.i++
+		item.inner = []sfItem{}
+		for {
+			for p.peek() == ' ' {
+				p.i++
+			}
+			if p.peek() == ')' {
+				p.i++
+				break
+			}
+			inner, err := p.item()
+			if err != nil {
+				return sfItem{}, err
+			}
+			item.inner = append(item.inner, inner)
+			if c := p.peek(); c != ' ' && c != ')' {
+				return sfItem{}, fmt.Errorf("invalid inner list at %d", p.i)
+			}
+		}
+	} else {
+		item.bytes = p.peek() == ':'
+		if item.value, err = p.bareItem(); err != nil {
+			return sfItem{}, err
+		}
+	}
+	if item.params, err = p.params(); err != nil {
+		return sfItem{}, err
+	}
+	item.raw = p.s[start:p.i]
+	return item, nil
+}
+
+func (p *sfParser) bareItem() (string, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		var value strings.Builder
+		for p.i++; p.i < len(p.s); p.i++ {
+			switch p.s[p.i] {
+			case '\\':
+				p.i++
+				if p.i >= len(p.s) {
+					return "", errors.New("invalid string escape")
+				}
+				value.WriteByte(p.s[p.i])
+			case '"':
+				p.i++
+				return value.String(), nil
+			default:
+				value.WriteByte(p.s[p.i])
+			}
+		}
+		return "", errors.New("unterminated string")
+	case c == ':':
+		end := strings.IndexByte(p.s[p.i+1:], ':')
+		if end < 0 {
+			return "", errors.New("unterminated byte sequence")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(p.s[p.i+1 : p.i+1+end])
+		if err != nil {
+			return "", err
+		}
+		p.i += end + 2
+		return string(decoded), nil
+	case c == '?':
+		if p.i+1 >= len(p.s) || (p.s[p.i+1] != '0' && p.s[p.i+1] != '1') {
+			return "", fmt.Errorf("invalid boolean at %d", p.i)
+		}
+		p.i += 2
+		return p.s[p.i-2 : p.i], nil
+	case c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*':
+		start := p.i
+		for p.i++; p.i < len(p.s); p.i++ {
+			if c := p.s[p.i]; c == ' ' || c == ',' || c == ';' || c == '(' || c == ')' || c == '=' || c == '"' {
+				break
+			}
+		}
+		return p.s[start:p.i], nil
+	default:
+		return "", fmt.Errorf("unexpected %q at %d", c, p.i)
+	}
+}
+
+func (p *sfParser) params() (map[string]string, error) {
+	params := map[string]string{}
+	for p.peek() == ';' {
+		p.i++
+		for p.peek() == ' ' {
+			p.i++
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		value := "?1"
+		if p.peek() == '=' {
+			p.i++
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params[key] = value
+	}
+	return params, nil
+}
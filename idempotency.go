@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const idempotencyKeyHeaderKey = "Idempotency-Key"
+
+// WithIdempotencyKeys is to add a generated Idempotency-Key header to the POST and
+// PATCH requests without one, the key is the same for all the retries of a request
+// as in draft-ietf-httpapi-idempotency-key-header.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://payments.example.com",
+//		httpclient.WithIdempotencyKeys(),
+//		httpclient.WithDecorator(httpclient.WithRetry(httpclient.RetryPolicy{MaxAttempts: 3})))
+func WithIdempotencyKeys() ClientsOption {
+	return func(cc *clientConfig) {
+		cc.idempotencyKeys = true
+	}
+}
+
+// WithIdempotencyKey is to send the Idempotency-Key of the caller with the request,
+// like the ID of the order to create it only once
+func WithIdempotencyKey(key string) RequestOption {
+	return func(rc *requestConfig) {
+		rc.idempotencyKey = key
+	}
+}
+
+// setIdempotencyKey adds the Idempotency-Key header to the request
+func (c *Client) setIdempotencyKey(r *http.Request, key string) error {
+	if key != "" {
+		r.Header.Set(idempotencyKeyHeaderKey, sfString(key))
+		return nil
+	}
+	if !c.idempotencyKeys || isIdempotentMethod(r.Method) || r.Header.Get(idempotencyKeyHeaderKey) != "" {
+		return nil
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	r.Header.Set(idempotencyKeyHeaderKey, sfString(key))
+	return nil
+}
+
+// newIdempotencyKey returns a random UUID as key
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// Version 4 and variant of RFC 4122
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// isIdempotentMethod returns true if the method is idempotent as in RFC 9110
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_WithIdempotencyKeys(t *testing.T) {
+	type attempt struct {
+		key  string
+		body string
+	}
+	var (
+		mu       sync.Mutex
+		attempts []attempt
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		attempts = append(attempts, attempt{key: r.Header.Get(idempotencyKeyHeaderKey), body: string(body)})
+		n := len(attempts)
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+	uuid := regexp.MustCompile(`^"[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}"$`)
+	retry := WithDecorator(WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+
+	tests := []struct {
+		name         string
+		opts         []ClientsOption
+		method       string
+		reqOpts      []RequestOption
+		wantAttempts int
+		wantStatus   int
+		wantKey      func(key string) bool
+	}{
+		{
+			name:         "ok case - POST with generated key retried",
+			opts:         []ClientsOption{WithIdempotencyKeys(), retry},
+			method:       http.MethodPost,
+			wantAttempts: 3,
+			wantStatus:   http.StatusCreated,
+			wantKey:      uuid.MatchString,
+		},
+		{
+			name:         "ok case - POST with the key of the caller retried",
+			opts:         []ClientsOption{retry},
+			method:       http.MethodPost,
+			reqOpts:      []RequestOption{WithIdempotencyKey("order-42")},
+			wantAttempts: 3,
+			wantStatus:   http.StatusCreated,
+			wantKey:      func(key string) bool { return key == `"order-42"` },
+		},
+		{
+			name:         "ok case - POST without key not retried",
+			opts:         []ClientsOption{retry},
+			method:       http.MethodPost,
+			wantAttempts: 1,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantKey:      func(key string) bool { return key == "" },
+		},
+		{
+			name:         "ok case - PUT retried without key",
+			opts:         []ClientsOption{WithIdempotencyKeys(), retry},
+			method:       http.MethodPut,
+			wantAttempts: 3,
+			wantStatus:   http.StatusCreated,
+			wantKey:      func(key string) bool { return key == "" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			attempts = nil
+			mu.Unlock()
+			c, err := NewClient(s.URL, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var resp Response
+			if tt.method == http.MethodPut {
+				resp, _ = c.Put(context.Background(), "/orders", "payload", nil, nil, tt.reqOpts...)
+			} else {
+				resp, _ = c.Post(context.Background(), "/orders", "payload", nil, nil, tt.reqOpts...)
+			}
+			if resp.RawResponse == nil || resp.RawResponse.StatusCode != tt.wantStatus {
+				t.Fatalf("Client.Do() response = %+v, want %v", resp.RawResponse, tt.wantStatus)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(attempts) != tt.wantAttempts {
+				t.Fatalf("attempts = %v, want %v", len(attempts), tt.wantAttempts)
+			}
+			for _, a := range attempts {
+				if a.key != attempts[0].key || !tt.wantKey(a.key) {
+					t.Errorf("Idempotency-Key = %v", a.key)
+				}
+				if a.body != "payload" {
+					t.Errorf("body = %v, want payload", a.body)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_WithRetry(t *testing.T) {
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		after      string
+		timeout    time.Duration
+		wantStatus int
+		wantCalls  int
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{
+			name:       "ok case - Retry-After exceeds the deadline of the request",
+			after:      "1",
+			timeout:    200 * time.Millisecond,
+			wantStatus: http.StatusTooManyRequests,
+			wantCalls:  1,
+			wantMax:    150 * time.Millisecond,
+		},
+		{
+			name:       "ok case - Retry-After not shortened by MaxBackoff",
+			policy:     RetryPolicy{MaxBackoff: 10 * time.Millisecond},
+			after:      "1",
+			wantStatus: http.StatusNoContent,
+			wantCalls:  2,
+			wantMin:    time.Second,
+			wantMax:    3 * time.Second,
+		},
+		{
+			name:       "ok case - Retry-After exceeds MaxRetryAfter",
+			policy:     RetryPolicy{MaxRetryAfter: time.Second},
+			after:      "120",
+			wantStatus: http.StatusTooManyRequests,
+			wantCalls:  1,
+			wantMax:    150 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			c, err := NewClient(s.URL, WithDecorator(WithRetry(tt.policy)))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var opts []RequestOption
+			if tt.timeout > 0 {
+				opts = append(opts, WithTimeout(tt.timeout))
+			}
+			start := time.Now()
+			resp, _ := c.Get(context.Background(), "/?after="+tt.after, nil, nil, opts...)
+			elapsed := time.Since(start)
+			if resp.RawResponse == nil || resp.RawResponse.StatusCode != tt.wantStatus {
+				t.Errorf("Client.Get() response = %+v, want %v", resp.RawResponse, tt.wantStatus)
+			}
+			if calls != tt.wantCalls || elapsed < tt.wantMin || elapsed > tt.wantMax {
+				t.Errorf("calls = %v in %v, want %v in [%v, %v]", calls, elapsed, tt.wantCalls, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "ok case - seconds", value: "3", wantMin: 3 * time.Second, wantMax: 3 * time.Second},
+		{name: "ok case - HTTP-date", value: time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat), wantMin: 3 * time.Second, wantMax: 5 * time.Second},
+		{name: "ok case - past HTTP-date", value: "Sun, 06 Nov 1994 08:49:37 GMT"},
+		{name: "nok case - negative seconds", value: "-1"},
+		{name: "nok case - invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Retry-After": {tt.value}}}
+			if got := retryAfter(resp); got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("retryAfter() = %v, want in [%v, %v]", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
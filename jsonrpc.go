@@ -0,0 +1,237 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+const jsonRPCVersion = "2.0"
+
+// Error codes defined by the JSON-RPC 2.0 specification
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+)
+
+var ErrRPCMissingResponse = errors.New("httpclient: missing JSON-RPC response")
+
+// RPCError is the error object of a JSON-RPC response
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (code %d)", e.Message, e.Code)
+}
+
+// DecodeData decodes the data of the error in v
+func (e *RPCError) DecodeData(v any) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// RPCCall is one call of a batch, the Result is decoded and the Error
+// is set with the response of this call
+type RPCCall struct {
+	Method string
+	Params any
+	// Result is decoded from the result of the response
+	Result any
+	// Notification is to not expect a response for this call
+	Notification bool
+	// Error is the error of this call, a *RPCError if the server returns an error object
+	Error error
+}
+
+// jsonRPCRequest is the request object
+type jsonRPCRequest struct {
+	JSONRPC string  `json:"jsonrpc"`
+	Method  string  `json:"method"`
+	Params  any     `json:"params,omitempty"`
+	ID      *uint64 `json:"id,omitempty"`
+}
+
+// jsonRPCResponse is the response object
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JSONRPCClient sends JSON-RPC 2.0 calls on an endpoint with the Client
+type JSONRPCClient struct {
+	client *Client
+	path   string
+	nextID atomic.Uint64
+}
+
+// NewJSONRPCClient returns a JSONRPCClient sending the calls on the path
+//
+// For Example:
+//
+//	rpc := httpclient.NewJSONRPCClient(client, "/rpc")
+//	var sum int
+//	err := rpc.Call(ctx, "add", []int{1, 2}, &sum)
+func NewJSONRPCClient(c *Client, path string) *JSONRPCClient {
+	return &JSONRPCClient{
+		client: c,
+		path:   path,
+	}
+}
+
+// Call calls the method with the params and decodes the result,
+// the error object of the response is returned as *RPCError
+func (j *JSONRPCClient) Call(ctx context.Context, method string, params any, result any, opts ...RequestOption) error {
+	id := j.nextID.Add(1)
+	var response jsonRPCResponse
+	if err := j.post(ctx, jsonRPCRequest{
+		JSONRPC: jsonRPCVersion,
+		Method:  method,
+		Params:  params,
+		ID:      &id,
+	}, &response, opts); err != nil {
+		return err
+	}
+	if response.ID != nil && string(response.ID) != strconv.FormatUint(id, 10) &&
+		string(response.ID) != "null" {
+		return fmt.Errorf("httpclient: unexpected JSON-RPC response id %s, want %d", response.ID, id)
+	}
+	return response.decode(result)
+}
+
+// Notify sends a notification, the server does not respond
+func (j *JSONRPCClient) Notify(ctx context.Context, method string, params any, opts ...RequestOption) error {
+	return j.post(ctx, jsonRPCRequest{
+		JSONRPC: jsonRPCVersion,
+		Method:  method,
+		Params:  params,
+	}, nil, opts)
+}
+
+// Batch sends the calls in one request, the result and the error of each
+// call are set on the call. The returned error is the error of the request.
+func (j *JSONRPCClient) Batch(ctx context.Context, calls []*RPCCall, opts ...RequestOption) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	requests := make([]jsonRPCRequest, 0, len(calls))
+	pending := make(map[string]*RPCCall, len(calls))
+	for _, call := range calls {
+		request := jsonRPCRequest{
+			JSONRPC: jsonRPCVersion,
+			Method:  call.Method,
+			Params:  call.Params,
+		}
+		if !call.Notification {
+			id := j.nextID.Add(1)
+			request.ID = &id
+			pending[strconv.FormatUint(id, 10)] = call
+		}
+		requests = append(requests, request)
+	}
+
+	var responses []jsonRPCResponse
+	var target any
+	if len(pending) > 0 {
+		target = &responses
+	}
+	if err := j.post(ctx, requests, target, opts); err != nil {
+		// The server may return a single error object for an invalid batch
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			for _, call := range pending {
+				call.Error = rpcErr
+			}
+		}
+		return err
+	}
+
+	// Correlate the responses with the calls
+	for _, response := range responses {
+		call, ok := pending[string(response.ID)]
+		if !ok {
+			continue
+		}
+		delete(pending, string(response.ID))
+		call.Error = response.decode(call.Result)
+	}
+	for _, call := range pending {
+		call.Error = ErrRPCMissingResponse
+	}
+	return nil
+}
+
+// post sends the payload and decodes the response in target
+func (j *JSONRPCClient) post(ctx context.Context, payload any, target any, opts []RequestOption) error {
+	config := new(requestConfig)
+	for _, o := range append([]RequestOption{WithIsJson()}, opts...) {
+		o(config)
+	}
+	r, err := j.client.newRequestWithContext(ctx, j.path, http.MethodPost, payload, config)
+	if err != nil {
+		return err
+	}
+	httpresponse, body, err := j.client.send(r)
+	if err != nil {
+		return err
+	}
+	body = bytes.TrimSpace(body)
+
+	// Notifications have no response
+	if target == nil {
+		if httpresponse.StatusCode >= http.StatusBadRequest {
+			return newStatusError(httpresponse, body)
+		}
+		return nil
+	}
+	if len(body) == 0 {
+		if err := newStatusError(httpresponse, body); err != nil {
+			return err
+		}
+		return ErrRPCMissingResponse
+	}
+
+	// A batch can be answered with a single response if the batch is invalid
+	if _, ok := target.(*[]jsonRPCResponse); ok && body[0] == '{' {
+		var response jsonRPCResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return err
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		return ErrRPCMissingResponse
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		if statusErr := newStatusError(httpresponse, body); statusErr != nil {
+			return statusErr
+		}
+		return err
+	}
+	return nil
+}
+
+// decode decodes the result or returns the error object
+func (r *jsonRPCResponse) decode(result any) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
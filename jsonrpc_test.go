@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// handleRPC is a small JSON-RPC server with the methods add and fail
+func handleRPC(request map[string]json.RawMessage) map[string]any {
+	id, ok := request["id"]
+	if !ok {
+		return nil
+	}
+	var method string
+	_ = json.Unmarshal(request["method"], &method)
+	response := map[string]any{"jsonrpc": "2.0", "id": id}
+	switch method {
+	case "add":
+		var params []int
+		if err := json.Unmarshal(request["params"], &params); err != nil {
+			response["error"] = map[string]any{"code": RPCInvalidParams, "message": "Invalid params"}
+			break
+		}
+		sum := 0
+		for _, p := range params {
+			sum += p
+		}
+		response["result"] = sum
+	case "fail":
+		response["error"] = map[string]any{"code": 42, "message": "failure", "data": map[string]any{"reason": "test"}}
+	default:
+		response["error"] = map[string]any{"code": RPCMethodNotFound, "message": "Method not found"}
+	}
+	return response
+}
+
+func TestJSONRPCClient(t *testing.T) {
+	notified := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		if raw[0] == '[' {
+			var requests []map[string]json.RawMessage
+			_ = json.Unmarshal(raw, &requests)
+			var responses []map[string]any
+			// Answer in the reverse order to check the correlation
+			for i := len(requests) - 1; i >= 0; i-- {
+				if response := handleRPC(requests[i]); response != nil {
+					responses = append(responses, response)
+				} else {
+					notified++
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(responses)
+			return
+		}
+		var request map[string]json.RawMessage
+		_ = json.Unmarshal(raw, &request)
+		response := handleRPC(request)
+		if response == nil {
+			notified++
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer s.Close()
+
+	rpc := NewJSONRPCClient(&Client{baseURL: s.URL, httpClient: &http.Client{}}, "/rpc")
+	ctx := context.Background()
+
+	t.Run("ok case - call", func(t *testing.T) {
+		var sum int
+		if err := rpc.Call(ctx, "add", []int{1, 2, 3}, &sum); err != nil {
+			t.Fatalf("JSONRPCClient.Call() error = %v", err)
+		}
+		if sum != 6 {
+			t.Errorf("JSONRPCClient.Call() = %v, want 6", sum)
+		}
+	})
+
+	t.Run("nok case - error object", func(t *testing.T) {
+		err := rpc.Call(ctx, "fail", nil, nil)
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != 42 || rpcErr.Message != "failure" {
+			t.Fatalf("JSONRPCClient.Call() error = %v, want *RPCError", err)
+		}
+		var data struct {
+			Reason string `json:"reason"`
+		}
+		if err := rpcErr.DecodeData(&data); err != nil || data.Reason != "test" {
+			t.Errorf("RPCError.DecodeData() = %v, %v", data, err)
+		}
+	})
+
+	t.Run("ok case - notification", func(t *testing.T) {
+		if err := rpc.Notify(ctx, "add", []int{1}); err != nil {
+			t.Fatalf("JSONRPCClient.Notify() error = %v", err)
+		}
+		if notified != 1 {
+			t.Errorf("JSONRPCClient.Notify() notified = %v, want 1", notified)
+		}
+	})
+
+	t.Run("ok case - batch", func(t *testing.T) {
+		var sum1, sum2 int
+		calls := []*RPCCall{
+			{Method: "add", Params: []int{1, 1}, Result: &sum1},
+			{Method: "add", Params: []int{2, 2}, Result: &sum2},
+			{Method: "unknown"},
+			{Method: "add", Params: []int{5}, Notification: true},
+		}
+		if err := rpc.Batch(ctx, calls); err != nil {
+			t.Fatalf("JSONRPCClient.Batch() error = %v", err)
+		}
+		if sum1 != 2 || sum2 != 4 || calls[0].Error != nil || calls[1].Error != nil {
+			t.Errorf("JSONRPCClient.Batch() = %v, %v, errors %v, %v", sum1, sum2, calls[0].Error, calls[1].Error)
+		}
+		var rpcErr *RPCError
+		if !errors.As(calls[2].Error, &rpcErr) || rpcErr.Code != RPCMethodNotFound {
+			t.Errorf("JSONRPCClient.Batch() error = %v, want method not found", calls[2].Error)
+		}
+		if calls[3].Error != nil || notified != 2 {
+			t.Errorf("JSONRPCClient.Batch() notification error = %v, notified = %v", calls[3].Error, notified)
+		}
+	})
+
+	t.Run("ok case - batch of notifications", func(t *testing.T) {
+		if err := rpc.Batch(ctx, []*RPCCall{{Method: "add", Notification: true}}); err != nil {
+			t.Fatalf("JSONRPCClient.Batch() error = %v", err)
+		}
+	})
+}
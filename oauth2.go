@@ -0,0 +1,484 @@
+package httpclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Grant types and assertion types of OAuth2
+const (
+	oauth2GrantClientCredentials = "client_credentials"
+	oauth2GrantRefreshToken      = "refresh_token"
+	oauth2GrantJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	oauth2ClientAssertionType    = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+const authorizationHeaderKey = "Authorization"
+
+var ErrOAuth2MissingToken = errors.New("httpclient: missing access token in the token response")
+
+// OAuth2Grant is the grant used to obtain an access token
+type OAuth2Grant int
+
+const (
+	// OAuth2ClientCredentials is the client credentials grant of the section 4.4 of RFC6749
+	OAuth2ClientCredentials OAuth2Grant = iota
+	// OAuth2RefreshToken is the refresh token grant of the section 6 of RFC6749
+	OAuth2RefreshToken
+	// OAuth2JWTBearer is the JWT bearer assertion grant of RFC7523
+	OAuth2JWTBearer
+)
+
+// OAuth2AuthStyle is the authentication of the client on the token endpoint
+type OAuth2AuthStyle int
+
+const (
+	// OAuth2ClientSecretBasic sends the client id and secret in the Authorization header
+	OAuth2ClientSecretBasic OAuth2AuthStyle = iota
+	// OAuth2ClientSecretPost sends the client id and secret in the form
+	OAuth2ClientSecretPost
+	// OAuth2PrivateKeyJWT sends a JWT signed with the private key of the client
+	OAuth2PrivateKeyJWT
+)
+
+// OAuth2Config is the configuration of the token endpoint
+type OAuth2Config struct {
+	// TokenURL is the URL of the token endpoint
+	TokenURL string
+	// ClientID and ClientSecret are the credentials of the client
+	ClientID     string
+	ClientSecret string
+	// Scopes requested
+	Scopes []string
+	// Grant is the grant used to obtain the tokens, client credentials by default
+	Grant OAuth2Grant
+	// AuthStyle is the authentication on the token endpoint, client_secret_basic by default
+	AuthStyle OAuth2AuthStyle
+	// RefreshToken is the refresh token of the refresh token grant
+	RefreshToken string
+	// PrivateKey signs the assertions of the JWT bearer grant and of private_key_jwt,
+	// RSA, ECDSA P-256 and Ed25519 keys are supported
+	PrivateKey crypto.Signer
+	// KeyID is the kid of the signed assertions
+	KeyID string
+	// Subject is the sub of the JWT bearer assertion, the client id by default
+	Subject string
+	// Audience is the aud of the assertions, the token URL by default
+	Audience string
+	// EndpointParams are additional params sent to the token endpoint
+	EndpointParams url.Values
+	// ExpiryDelta is the delay before the expiry to refresh the token, 10 seconds by default
+	ExpiryDelta time.Duration
+	// Doer sends the requests to the token endpoint, a http.Client by default
+	Doer Doer
+}
+
+// OAuth2Token is a token returned by the token endpoint
+type OAuth2Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// valid returns true if the token is not expired with the delta
+func (t *OAuth2Token) valid(delta time.Duration) bool {
+	return t != nil && t.AccessToken != "" &&
+		(t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+// authorization returns the value of the Authorization header
+func (t *OAuth2Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// OAuth2Error is the error response of the token endpoint of the section 5.2 of RFC6749
+type OAuth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("oauth2: %s", e.Code)
+	}
+	return fmt.Sprintf("oauth2: %s: %s", e.Code, e.Description)
+}
+
+// OAuth2TokenSource caches the token of the token endpoint and refreshes it
+// shortly before its expiry. Only one request is sent to the token endpoint
+// when several goroutines need a token.
+type OAuth2TokenSource struct {
+	config OAuth2Config
+
+	mu           sync.Mutex
+	token        *OAuth2Token
+	refreshToken string
+	flight       *tokenFlight
+}
+
+// tokenFlight is a request in progress to the token endpoint
+type tokenFlight struct {
+	done  chan struct{}
+	token *OAuth2Token
+	err   error
+}
+
+// NewOAuth2TokenSource returns a token source for the configuration
+func NewOAuth2TokenSource(config OAuth2Config) *OAuth2TokenSource {
+	if config.ExpiryDelta == 0 {
+		config.ExpiryDelta = 10 * time.Second
+	}
+	if config.Doer == nil {
+		config.Doer = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &OAuth2TokenSource{
+		config:       config,
+		refreshToken: config.RefreshToken,
+	}
+}
+
+// Token returns the cached token or requests a new one
+func (s *OAuth2TokenSource) Token(ctx context.Context) (*OAuth2Token, error) {
+	s.mu.Lock()
+	if s.token.valid(s.config.ExpiryDelta) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	flight := s.flight
+	if flight == nil {
+		flight = &tokenFlight{done: make(chan struct{})}
+		s.flight = flight
+		// The request is not canceled with the context of the first caller
+		go s.fetch(flight)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.token, flight.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate removes the token from the cache if it's still the cached one
+func (s *OAuth2TokenSource) Invalidate(token *OAuth2Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = nil
+	}
+}
+
+// fetch requests a token and ends the flight
+func (s *OAuth2TokenSource) fetch(flight *tokenFlight) {
+	s.mu.Lock()
+	refreshToken := s.refreshToken
+	s.mu.Unlock()
+
+	flight.token, flight.err = s.requestToken(refreshToken)
+
+	s.mu.Lock()
+	if flight.err == nil {
+		s.token = flight.token
+		if flight.token.RefreshToken != "" {
+			s.refreshToken = flight.token.RefreshToken
+		}
+	}
+	s.flight = nil
+	s.mu.Unlock()
+	close(flight.done)
+}
+
+// requestToken sends the request to the token endpoint
+func (s *OAuth2TokenSource) requestToken(refreshToken string) (*OAuth2Token, error) {
+	c := s.config
+	form := url.Values{}
+	for key, values := range c.EndpointParams {
+		form[key] = append([]string(nil), values...)
+	}
+	switch c.Grant {
+	case OAuth2RefreshToken:
+		form.Set("grant_type", oauth2GrantRefreshToken)
+		form.Set("refresh_token", refreshToken)
+	case OAuth2JWTBearer:
+		assertion, err := s.assertion(defaultString(c.Subject, c.ClientID))
+		if err != nil {
+			return nil, err
+		}
+		form.Set("grant_type", oauth2GrantJWTBearer)
+		form.Set("assertion", assertion)
+	default:
+		form.Set("grant_type", oauth2GrantClientCredentials)
+	}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	// Authentication of the client
+	header := http.Header{}
+	header.Set(contentTypeHeaderKey, "application/x-www-form-urlencoded")
+	header.Set("Accept", "application/json")
+	switch c.AuthStyle {
+	case OAuth2ClientSecretPost:
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	case OAuth2PrivateKeyJWT:
+		assertion, err := s.assertion(c.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_id", c.ClientID)
+		form.Set("client_assertion_type", oauth2ClientAssertionType)
+		form.Set("client_assertion", assertion)
+	default:
+		if c.ClientID != "" {
+			header.Set(authorizationHeaderKey, "Basic "+base64.StdEncoding.EncodeToString(
+				[]byte(url.QueryEscape(c.ClientID)+":"+url.QueryEscape(c.ClientSecret))))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	r.Header = header
+	resp, err := c.Doer.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := readAllWithLimit(resp.Body, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode > 299 {
+		oauthErr := &OAuth2Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, oauthErr) != nil || oauthErr.Code == "" {
+			return nil, newStatusError(resp, body)
+		}
+		return nil, oauthErr
+	}
+	return parseOAuth2Token(body)
+}
+
+// assertion returns a JWT signed with the private key for the subject
+func (s *OAuth2TokenSource) assertion(subject string) (string, error) {
+	if s.config.PrivateKey == nil {
+		return "", errors.New("httpclient: missing private key to sign the assertion")
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	issuer := defaultString(s.config.ClientID, subject)
+	return signJWT(map[string]any{
+		"iss": issuer,
+		"sub": subject,
+		"aud": defaultString(s.config.Audience, s.config.TokenURL),
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"jti": hex.EncodeToString(jti),
+	}, s.config.PrivateKey, s.config.KeyID)
+}
+
+// parseOAuth2Token parses the token response of the section 5.1 of RFC6749
+func parseOAuth2Token(body []byte) (*OAuth2Token, error) {
+	var payload struct {
+		AccessToken  string          `json:"access_token"`
+		TokenType    string          `json:"token_type"`
+		RefreshToken string          `json:"refresh_token"`
+		ExpiresIn    json.RawMessage `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.AccessToken == "" {
+		return nil, ErrOAuth2MissingToken
+	}
+	token := &OAuth2Token{
+		AccessToken:  payload.AccessToken,
+		TokenType:    payload.TokenType,
+		RefreshToken: payload.RefreshToken,
+	}
+	// Some servers send expires_in as a string
+	if expiresIn := strings.Trim(string(payload.ExpiresIn), `"`); expiresIn != "" && expiresIn != "null" {
+		seconds, err := strconv.ParseInt(expiresIn, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: invalid expires_in %s", payload.ExpiresIn)
+		}
+		if seconds > 0 {
+			token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	return token, nil
+}
+
+// WithOAuth2 is a Decorator adding the access token of the source in the
+// Authorization header. If the server responds 401, the token is refreshed
+// and the request is sent again once if its body can be rewound.
+//
+// For Example:
+//
+//	source := httpclient.NewOAuth2TokenSource(httpclient.OAuth2Config{
+//		TokenURL:     "https://auth.example.com/token",
+//		ClientID:     "id",
+//		ClientSecret: "secret",
+//	})
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithDecorator(httpclient.WithOAuth2(source)))
+func WithOAuth2(source *OAuth2TokenSource) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			token, err := source.Token(r.Context())
+			if err != nil {
+				return nil, err
+			}
+			authorized := r.Clone(r.Context())
+			authorized.Header.Set(authorizationHeaderKey, token.authorization())
+			resp, err := d.Do(authorized)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+				return resp, nil
+			}
+
+			// Retry once with a fresh token
+			source.Invalidate(token)
+			token, err = source.Token(r.Context())
+			if err != nil {
+				return resp, nil
+			}
+			retry, err := rewindRequest(r)
+			if err != nil {
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			retry.Header.Set(authorizationHeaderKey, token.authorization())
+			return d.Do(retry)
+		})
+	}
+}
+
+// rewindRequest returns a copy of the request with a new body from GetBody
+func rewindRequest(r *http.Request) (*http.Request, error) {
+	nr := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		nr.Body = body
+	}
+	return nr, nil
+}
+
+// signJWT signs the claims in a compact JWS, the algorithm depends on the key
+func signJWT(claims map[string]any, key crypto.Signer, kid string) (string, error) {
+	var (
+		alg  string
+		hash crypto.Hash
+	)
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		alg, hash = "RS256", crypto.SHA256
+	case *ecdsa.PublicKey:
+		switch pub.Curve.Params().BitSize {
+		case 256:
+			alg, hash = "ES256", crypto.SHA256
+		case 384:
+			alg, hash = "ES384", crypto.SHA384
+		default:
+			return "", fmt.Errorf("httpclient: unsupported curve %s", pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		alg = "EdDSA"
+	default:
+		return "", fmt.Errorf("httpclient: unsupported key %T", pub)
+	}
+
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." +
+		base64.RawURLEncoding.EncodeToString(encodedClaims)
+
+	var signature []byte
+	switch hash {
+	case 0:
+		signature, err = key.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	case crypto.SHA256:
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = key.Sign(rand.Reader, digest[:], hash)
+	case crypto.SHA384:
+		digest := sha512.Sum384([]byte(signingInput))
+		signature, err = key.Sign(rand.Reader, digest[:], hash)
+	}
+	if err != nil {
+		return "", err
+	}
+	if pub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		// JWS uses the concatenation of r and s instead of ASN.1
+		signature, err = ecdsaRawSignature(signature, (pub.Curve.Params().BitSize+7)/8)
+		if err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature in the concatenation of r and s
+func ecdsaRawSignature(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
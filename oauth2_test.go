@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOAuth2TokenSource(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var issued atomic.Int32
+	var lastForm atomicValue
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		lastForm.Store(r.PostForm.Encode())
+		user, pass, basic := r.BasicAuth()
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		authenticated := (basic && user == "id" && pass == "secret") ||
+			(r.PostForm.Get("client_id") == "id" && r.PostForm.Get("client_secret") == "secret") ||
+			(r.PostForm.Get("client_assertion_type") == oauth2ClientAssertionType &&
+				verifyES256(r.PostForm.Get("client_assertion"), &ecKey.PublicKey))
+		if !authenticated {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		switch r.PostForm.Get("grant_type") {
+		case oauth2GrantRefreshToken:
+			if r.PostForm.Get("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+		case oauth2GrantJWTBearer:
+			if !verifyES256(r.PostForm.Get("assertion"), &ecKey.PublicKey) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+		}
+		n := issued.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":"3600","refresh_token":"refresh-1"}`, n)
+	}))
+	defer tokenServer.Close()
+
+	tests := []struct {
+		name    string
+		config  OAuth2Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "ok case - client credentials with client_secret_basic",
+			config: OAuth2Config{ClientID: "id", ClientSecret: "secret", Scopes: []string{"a", "b"}},
+			want:   "grant_type=client_credentials&scope=a+b",
+		},
+		{
+			name:   "ok case - client credentials with client_secret_post",
+			config: OAuth2Config{ClientID: "id", ClientSecret: "secret", AuthStyle: OAuth2ClientSecretPost},
+			want:   "client_id=id&client_secret=secret&grant_type=client_credentials",
+		},
+		{
+			name:   "ok case - refresh token",
+			config: OAuth2Config{ClientID: "id", ClientSecret: "secret", Grant: OAuth2RefreshToken, RefreshToken: "refresh-1"},
+			want:   "grant_type=refresh_token&refresh_token=refresh-1",
+		},
+		{
+			name: "ok case - jwt bearer with private_key_jwt",
+			config: OAuth2Config{ClientID: "id", Grant: OAuth2JWTBearer, AuthStyle: OAuth2PrivateKeyJWT,
+				PrivateKey: ecKey, KeyID: "k1"},
+			want: "grant_type=" + url.QueryEscape(oauth2GrantJWTBearer),
+		},
+		{
+			name:    "nok case - invalid client",
+			config:  OAuth2Config{ClientID: "id", ClientSecret: "wrong"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.TokenURL = tokenServer.URL
+			source := NewOAuth2TokenSource(tt.config)
+			token, err := source.Token(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OAuth2TokenSource.Token() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var oauthErr *OAuth2Error
+				if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_client" {
+					t.Errorf("OAuth2TokenSource.Token() error = %v, want *OAuth2Error", err)
+				}
+				return
+			}
+			if !strings.Contains(lastForm.Load(), tt.want) {
+				t.Errorf("OAuth2TokenSource.Token() form = %v, want %v", lastForm.Load(), tt.want)
+			}
+			// The token is cached
+			cached, _ := source.Token(context.Background())
+			if token.RefreshToken != "refresh-1" || cached != token {
+				t.Errorf("OAuth2TokenSource.Token() = %+v, cached = %+v", token, cached)
+			}
+		})
+	}
+}
+
+func TestWithOAuth2(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := issued.Add(1)
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// The first token is revoked by the API
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(authorizationHeaderKey) == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	source := NewOAuth2TokenSource(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"})
+	c, err := NewClient(s.URL, WithDecorator(WithOAuth2(source)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Post(context.Background(), "/items", "payload", nil, nil)
+			if err != nil || resp.RawResponse.StatusCode != http.StatusNoContent {
+				t.Errorf("Client.Post() = %v, error = %v", resp.RawResponse, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// One token for the first requests and one after the 401
+	if n := issued.Load(); n != 2 {
+		t.Errorf("token requests = %v, want 2", n)
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for name, signer := range map[string]crypto.Signer{"RS256": rsaKey, "EdDSA": edKey, "ES256": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			jwt, err := signJWT(map[string]any{"sub": "me"}, signer, "")
+			if err != nil {
+				t.Fatalf("signJWT() error = %v", err)
+			}
+			parts := strings.Split(jwt, ".")
+			header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			var h map[string]string
+			_ = json.Unmarshal(header, &h)
+			if len(parts) != 3 || h["alg"] != name {
+				t.Errorf("signJWT() = %v, header %v", jwt, h)
+			}
+			if name == "ES256" && !verifyES256(jwt, &ecKey.PublicKey) {
+				t.Errorf("signJWT() invalid ES256 signature")
+			}
+		})
+	}
+}
+
+// verifyES256 verifies a JWT signed with ES256
+func verifyES256(jwt string, key *ecdsa.PublicKey) bool {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(key, digest[:], r, s)
+}
+
+// atomicValue is a string safe for concurrent use
+type atomicValue struct {
+	mu sync.Mutex
+	v  string
+}
+
+func (a *atomicValue) Store(v string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.v = v
+}
+
+func (a *atomicValue) Load() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.v
+}
@@ -1,9 +1,11 @@
 package httpclient
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"runtime"
+	"time"
 )
 
 // ClientsOption is to create convenient client options like wait custom RoundTripper, custom http.client
@@ -15,6 +17,34 @@ type clientConfig struct {
 	Decorators []Decorator
 	LimitSize  int
 	UserAgent  string
+
+	// Changes of the TLS configuration of the *http.Transport
+	tls []func(*tls.Config)
+
+	// Protection against the SSRF, nil when disabled
+	ssrf *SSRFConfig
+
+	// Redirect policy, the one of http.Client when nil
+	redirect *redirectConfig
+
+	// Cookies and default headers of the requests
+	session *Session
+
+	// Load balancer of the requests between endpoints
+	balancer *LoadBalancer
+
+	// Default timeout of the requests and timeouts of the connections
+	timeout  time.Duration
+	timeouts *Timeouts
+
+	// Idempotency-Key generated for the non-idempotent requests
+	idempotencyKeys bool
+
+	// Compression of the request bodies by host
+	compressionHosts map[string]BodyCompression
+
+	// First error raised by an option
+	err error
 }
 
 func newclientConfig() *clientConfig {
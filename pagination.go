@@ -0,0 +1,594 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Page is one page returned by a [PageIterator]
+type Page struct {
+	// Number of the page, the first page is 1
+	Number int
+	// Result decoded from the body of the page
+	Result any
+	// Raw body of the page
+	Body []byte
+	// The response of the page
+	Response Response
+}
+
+// Paginator is the strategy to move from a page to the next one
+type Paginator interface {
+	// FirstRequest prepares the request of the first page
+	FirstRequest(r *http.Request) (*http.Request, error)
+	// NextRequest returns the request of the page following the page,
+	// a nil request means the page is the last one
+	NextRequest(r *http.Request, page *Page) (*http.Request, error)
+}
+
+// LinkPagination follows the Link header with the relation next of the RFC8288
+//
+//	Link: <https://api.example.com/items?page=2>; rel="next"
+//
+// The Authorization, Proxy-Authorization and Cookie headers are not sent to
+// a link of another origin, like the cross-origin redirects.
+type LinkPagination struct {
+	// SensitiveHeaders are removed on the cross-origin links in addition to
+	// Authorization, Proxy-Authorization and Cookie
+	SensitiveHeaders []string
+}
+
+// FirstRequest returns the request without changes
+func (LinkPagination) FirstRequest(r *http.Request) (*http.Request, error) {
+	return r, nil
+}
+
+// NextRequest returns a GET request on the link with the relation next
+func (p LinkPagination) NextRequest(r *http.Request, page *Page) (*http.Request, error) {
+	if page.Response.RawResponse == nil {
+		return nil, nil
+	}
+	for _, link := range parseLinkHeader(page.Response.RawResponse.Header.Values("Link")) {
+		if !link.hasRel("next") {
+			continue
+		}
+		next, err := r.URL.Parse(link.target)
+		if err != nil {
+			return nil, err
+		}
+		nr, err := http.NewRequestWithContext(r.Context(), http.MethodGet, next.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		nr.Header = r.Header.Clone()
+		nr.Header.Del(contentTypeHeaderKey)
+		// The credentials are not sent to the other origins
+		if !sameOrigin(r.URL, nr.URL) {
+			for _, header := range defaultSensitiveHeaders {
+				nr.Header.Del(header)
+			}
+			for _, header := range p.SensitiveHeaders {
+				nr.Header.Del(header)
+			}
+		}
+		return nr, nil
+	}
+	return nil, nil
+}
+
+// CursorPagination sends the cursor extracted from a page as query in the next request
+type CursorPagination struct {
+	// Param is the query to send the cursor, "cursor" by default
+	Param string
+	// Cursor extracts the cursor of the next page from the page,
+	// an empty cursor means the page is the last one
+	Cursor func(page *Page) (string, error)
+}
+
+// FirstRequest returns the request without changes
+func (p CursorPagination) FirstRequest(r *http.Request) (*http.Request, error) {
+	return r, nil
+}
+
+// NextRequest returns the request with the cursor of the next page
+func (p CursorPagination) NextRequest(r *http.Request, page *Page) (*http.Request, error) {
+	if p.Cursor == nil {
+		return nil, errors.New("httpclient: missing cursor function")
+	}
+	cursor, err := p.Cursor(page)
+	if err != nil || cursor == "" {
+		return nil, err
+	}
+	return withPageQuery(r, map[string]string{defaultString(p.Param, "cursor"): cursor})
+}
+
+// OffsetPagination sends the offset and the limit as queries like ?offset=20&limit=10.
+// The iteration stops with a page with less items than the limit.
+type OffsetPagination struct {
+	// OffsetParam is the query of the offset, "offset" by default
+	OffsetParam string
+	// LimitParam is the query of the limit, "limit" by default
+	LimitParam string
+	// Limit is the number of items by page, the limit is not sent if zero
+	// and the iteration stops with an empty page
+	Limit int
+	// Count returns the number of items of a page, by default the result
+	// must be a slice or a pointer to a slice
+	Count func(page *Page) int
+}
+
+// FirstRequest returns the request with the offset 0
+func (p OffsetPagination) FirstRequest(r *http.Request) (*http.Request, error) {
+	queries := map[string]string{defaultString(p.OffsetParam, "offset"): "0"}
+	if p.Limit > 0 {
+		queries[defaultString(p.LimitParam, "limit")] = strconv.Itoa(p.Limit)
+	}
+	return withPageQuery(r, queries)
+}
+
+// NextRequest returns the request with the offset of the next page
+func (p OffsetPagination) NextRequest(r *http.Request, page *Page) (*http.Request, error) {
+	count := countItems(page, p.Count)
+	if count == 0 || count < p.Limit {
+		return nil, nil
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get(defaultString(p.OffsetParam, "offset")))
+	return withPageQuery(r, map[string]string{
+		defaultString(p.OffsetParam, "offset"): strconv.Itoa(offset + count),
+	})
+}
+
+// PagePagination sends the number of the page and its size as queries like ?page=2&per_page=10.
+// The iteration stops with a page with less items than the size.
+type PagePagination struct {
+	// PageParam is the query of the page, "page" by default
+	PageParam string
+	// SizeParam is the query of the size, the size is not sent if empty
+	SizeParam string
+	// First is the number of the first page, most of the API starts at 1
+	First int
+	// Size is the number of items by page
+	Size int
+	// Count returns the number of items of a page, by default the result
+	// must be a slice or a pointer to a slice
+	Count func(page *Page) int
+}
+
+// FirstRequest returns the request with the first page
+func (p PagePagination) FirstRequest(r *http.Request) (*http.Request, error) {
+	queries := map[string]string{defaultString(p.PageParam, "page"): strconv.Itoa(p.First)}
+	if p.SizeParam != "" {
+		queries[p.SizeParam] = strconv.Itoa(p.Size)
+	}
+	return withPageQuery(r, queries)
+}
+
+// NextRequest returns the request of the next page
+func (p PagePagination) NextRequest(r *http.Request, page *Page) (*http.Request, error) {
+	count := countItems(page, p.Count)
+	if count == 0 || count < p.Size {
+		return nil, nil
+	}
+	return withPageQuery(r, map[string]string{
+		defaultString(p.PageParam, "page"): strconv.Itoa(p.First + page.Number),
+	})
+}
+
+// PaginationOption is to configure a [PageIterator]
+type PaginationOption func(*paginationConfig)
+
+// paginationConfig is to create PageIterator options
+type paginationConfig struct {
+	limit          int
+	hooks          []func(*Page) error
+	prefetch       bool
+	requestOptions []RequestOption
+	newResultError func() any
+}
+
+// WithPageLimit is to stop the iteration after limit pages
+func WithPageLimit(limit int) PaginationOption {
+	return func(pc *paginationConfig) {
+		pc.limit = limit
+	}
+}
+
+// WithPageHook is to call the hook on each page before returning it,
+// an error of the hook stops the iteration
+func WithPageHook(hook func(*Page) error) PaginationOption {
+	return func(pc *paginationConfig) {
+		pc.hooks = append(pc.hooks, hook)
+	}
+}
+
+// WithPrefetch is to fetch the next page in background while the current one is used
+func WithPrefetch() PaginationOption {
+	return func(pc *paginationConfig) {
+		pc.prefetch = true
+	}
+}
+
+// WithPageRequestOptions is to add request options like headers in the request of the first page
+func WithPageRequestOptions(opts ...RequestOption) PaginationOption {
+	return func(pc *paginationConfig) {
+		pc.requestOptions = append(pc.requestOptions, opts...)
+	}
+}
+
+// WithPageResultError is to decode the error payload of a page
+// in a value created by newResultError
+func WithPageResultError(newResultError func() any) PaginationOption {
+	return func(pc *paginationConfig) {
+		pc.newResultError = newResultError
+	}
+}
+
+// PageIterator iterates over the pages of a paginated API.
+//
+// For Example:
+//
+//	it := client.Paginate(ctx, "/items", httpclient.LinkPagination{},
+//		func() any { return &[]Item{} }, httpclient.WithPageLimit(10))
+//	defer it.Close()
+//	for it.Next() {
+//		items := *it.Page().Result.(*[]Item)
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle your error
+//	}
+type PageIterator struct {
+	client    *Client
+	paginator Paginator
+	newResult func() any
+	config    *paginationConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// iterating serializes the calls of Next, mu is not held during the round trips
+	iterating sync.Mutex
+	mu        sync.Mutex
+	next      *http.Request
+	page      *Page
+	err       error
+	count     int
+	done      bool
+	prefetch  chan pageResult
+}
+
+// pageResult is the result of the fetch of a page
+type pageResult struct {
+	page *Page
+	err  error
+}
+
+// Paginate returns an iterator over the pages starting with a GET request on the path.
+// Each page is decoded in a new value created by newResult.
+func (c *Client) Paginate(
+	ctx context.Context,
+	path string,
+	paginator Paginator,
+	newResult func() any,
+	opts ...PaginationOption,
+) *PageIterator {
+	config := new(paginationConfig)
+	for _, o := range opts {
+		o(config)
+	}
+	it := &PageIterator{
+		client:    c,
+		paginator: paginator,
+		newResult: newResult,
+		config:    config,
+	}
+	if ctx == nil {
+		it.err = errors.New("httpclient: nil Context")
+		it.done = true
+		return it
+	}
+	it.ctx, it.cancel = context.WithCancel(ctx)
+
+	requestConfig := new(requestConfig)
+	for _, o := range config.requestOptions {
+		o(requestConfig)
+	}
+	r, err := c.newRequestWithContext(it.ctx, path, http.MethodGet, nil, requestConfig)
+	if err == nil {
+		r, err = paginator.FirstRequest(r)
+	}
+	if err != nil {
+		it.err = err
+		it.done = true
+		return it
+	}
+	it.next = r
+	return it
+}
+
+// Next fetches the next page, it returns false at the end of the
+// iteration or if an error occurs, see [PageIterator.Err]
+func (it *PageIterator) Next() bool {
+	it.iterating.Lock()
+	defer it.iterating.Unlock()
+
+	it.mu.Lock()
+	if it.done {
+		it.mu.Unlock()
+		return false
+	}
+	if it.next == nil || (it.config.limit > 0 && it.count >= it.config.limit) {
+		it.finish(nil)
+		it.mu.Unlock()
+		return false
+	}
+	current, prefetch := it.next, it.prefetch
+	it.prefetch = nil
+	it.mu.Unlock()
+
+	page, next, err := it.fetchPage(current, prefetch)
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	// The iterator may be closed during the fetch
+	if it.done {
+		return false
+	}
+	if err != nil {
+		it.finish(err)
+		return false
+	}
+	it.page = page
+	it.next = next
+
+	// Fetch the next page while the caller uses this one
+	if it.config.prefetch && next != nil && (it.config.limit <= 0 || it.count < it.config.limit) {
+		it.prefetch = make(chan pageResult, 1)
+		go func(ch chan<- pageResult) {
+			ch <- it.fetch(next)
+		}(it.prefetch)
+	}
+	return true
+}
+
+// fetchPage returns the page of the request, or the prefetched one, and the request of the next page
+func (it *PageIterator) fetchPage(r *http.Request, prefetch <-chan pageResult) (*Page, *http.Request, error) {
+	var result pageResult
+	if prefetch != nil {
+		result = <-prefetch
+	} else {
+		result = it.fetch(r)
+	}
+	if result.err != nil {
+		return nil, nil, result.err
+	}
+
+	it.count++
+	page := result.page
+	page.Number = it.count
+	for _, hook := range it.config.hooks {
+		if err := hook(page); err != nil {
+			return nil, nil, err
+		}
+	}
+	next, err := it.paginator.NextRequest(r, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return page, next, nil
+}
+
+// Page returns the current page
+func (it *PageIterator) Page() *Page {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.page
+}
+
+// Err returns the error which stopped the iteration
+func (it *PageIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// All returns a function compatible with iter.Seq2 to range over the pages,
+// the error stopping the iteration is yielded with a nil page.
+//
+//	for page, err := range it.All() {
+//		...
+//	}
+func (it *PageIterator) All() func(yield func(*Page, error) bool) {
+	return func(yield func(*Page, error) bool) {
+		defer it.Close()
+		for it.Next() {
+			if !yield(it.Page(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Close stops the iteration and cancels the prefetch of the next page
+func (it *PageIterator) Close() {
+	// Cancel first to interrupt a fetch in progress
+	if it.cancel != nil {
+		it.cancel()
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.finish(nil)
+}
+
+// finish ends the iteration, must be called with the lock
+func (it *PageIterator) finish(err error) {
+	if err != nil && it.err == nil {
+		it.err = err
+	}
+	it.done = true
+	it.next = nil
+	if it.cancel != nil {
+		it.cancel()
+	}
+}
+
+// fetch sends the request of a page and decodes it
+func (it *PageIterator) fetch(r *http.Request) pageResult {
+	var result, resultError any
+	if it.newResult != nil {
+		result = it.newResult()
+	}
+	if it.config.newResultError != nil {
+		resultError = it.config.newResultError()
+	}
+	response, body, err := it.client.do(r, result, resultError)
+	if err == nil {
+		err = newStatusError(response.RawResponse, body)
+	}
+	if err != nil {
+		return pageResult{err: err}
+	}
+	return pageResult{page: &Page{
+		Result:   result,
+		Body:     body,
+		Response: response,
+	}}
+}
+
+// withPageQuery returns a copy of the request with the queries
+func withPageQuery(r *http.Request, queries map[string]string) (*http.Request, error) {
+	nr := r.Clone(r.Context())
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		nr.Body = body
+	}
+	values := nr.URL.Query()
+	for key, value := range queries {
+		values.Set(key, value)
+	}
+	nr.URL.RawQuery = values.Encode()
+	return nr, nil
+}
+
+// countItems returns the number of items in the page
+func countItems(page *Page, count func(*Page) int) int {
+	if count != nil {
+		return count(page)
+	}
+	v := reflect.ValueOf(page.Result)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return v.Len()
+	}
+	return 0
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// link is a link of the Link header
+type link struct {
+	target string
+	params map[string]string
+}
+
+// hasRel returns true if the relation types of the link contain rel
+func (l link) hasRel(rel string) bool {
+	for _, r := range strings.Fields(l.params["rel"]) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinkHeader parses the Link headers as defined in the section 3 of RFC8288
+func parseLinkHeader(values []string) []link {
+	var links []link
+	for _, value := range values {
+		for len(value) > 0 {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			l := link{target: value[start+1 : start+end], params: map[string]string{}}
+			value = value[start+end+1:]
+
+			// Parse the params until the next link
+			for {
+				value = strings.TrimLeft(value, " \t")
+				if len(value) == 0 || value[0] != ';' {
+					break
+				}
+				value = strings.TrimLeft(value[1:], " \t")
+				i := strings.IndexAny(value, "=;,")
+				if i < 0 {
+					l.params[strings.ToLower(strings.TrimSpace(value))] = ""
+					value = ""
+					break
+				}
+				name := strings.ToLower(strings.TrimSpace(value[:i]))
+				if value[i] != '=' {
+					l.params[name] = ""
+					value = value[i:]
+					continue
+				}
+				var param string
+				param, value = parseLinkParamValue(strings.TrimLeft(value[i+1:], " \t"))
+				// Only the first occurrence of a param is used
+				if _, ok := l.params[name]; !ok {
+					l.params[name] = param
+				}
+			}
+			links = append(links, l)
+			value = strings.TrimLeft(value, " \t,")
+		}
+	}
+	return links
+}
+
+// parseLinkParamValue parses a token or a quoted string and returns the rest
+func parseLinkParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";,")
+		if i < 0 {
+			return strings.TrimSpace(s), ""
+		}
+		return strings.TrimSpace(s[:i]), s[i:]
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
@@ -0,0 +1,334 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type Item struct {
+	ID int `json:"id"`
+}
+
+type CursorItems struct {
+	Items []Item `json:"items"`
+	Next  string `json:"next"`
+}
+
+// newPaginatedServer returns a server with 7 items
+func newPaginatedServer() *httptest.Server {
+	items := []Item{{1}, {2}, {3}, {4}, {5}, {6}, {7}}
+	page := func(offset, limit int) []Item {
+		if offset > len(items) {
+			offset = len(items)
+		}
+		end := offset + limit
+		if end > len(items) {
+			end = len(items)
+		}
+		return items[offset:end]
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		p, _ := strconv.Atoi(r.URL.Query().Get("p"))
+		if p*3+3 < len(items) {
+			w.Header().Add("Link", fmt.Sprintf(`</link?p=%d>; rel="next", </link?p=0>; rel="first"`, p+1))
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(page(p*3, 3))
+	})
+	mux.HandleFunc("/offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			// Default limit of the server
+			limit = 3
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(page(offset, limit))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(page((p-1)*size, size))
+	})
+	mux.HandleFunc("/cursor", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		result := CursorItems{Items: page(offset, 4)}
+		if offset+4 < len(items) {
+			result.Next = strconv.Itoa(offset + 4)
+		}
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(ProblemDetails{Status: http.StatusInternalServerError})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient_Paginate(t *testing.T) {
+	s := newPaginatedServer()
+	defer s.Close()
+
+	newItems := func() any { return &[]Item{} }
+	tests := []struct {
+		name      string
+		path      string
+		paginator Paginator
+		newResult func() any
+		opts      []PaginationOption
+		want      []int
+		wantPages int
+		wantErr   bool
+	}{
+		{
+			name:      "ok case - link header",
+			path:      "/link",
+			paginator: LinkPagination{},
+			newResult: newItems,
+			want:      []int{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 3,
+		},
+		{
+			name:      "ok case - link header with page limit and prefetch",
+			path:      "/link",
+			paginator: LinkPagination{},
+			newResult: newItems,
+			opts:      []PaginationOption{WithPageLimit(2), WithPrefetch()},
+			want:      []int{1, 2, 3, 4, 5, 6},
+			wantPages: 2,
+		},
+		{
+			name:      "ok case - offset",
+			path:      "/offset",
+			paginator: OffsetPagination{Limit: 2},
+			newResult: newItems,
+			opts:      []PaginationOption{WithPrefetch()},
+			want:      []int{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 4,
+		},
+		{
+			name:      "ok case - offset without limit",
+			path:      "/offset",
+			paginator: OffsetPagination{},
+			newResult: newItems,
+			want:      []int{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 4,
+		},
+		{
+			name:      "ok case - page",
+			path:      "/page",
+			paginator: PagePagination{First: 1, Size: 7, SizeParam: "size"},
+			newResult: newItems,
+			want:      []int{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 2,
+		},
+		{
+			name: "ok case - cursor",
+			path: "/cursor",
+			paginator: CursorPagination{
+				Param: "after",
+				Cursor: func(page *Page) (string, error) {
+					return page.Result.(*CursorItems).Next, nil
+				},
+			},
+			newResult: func() any { return &CursorItems{} },
+			want:      []int{1, 2, 3, 4, 5, 6, 7},
+			wantPages: 2,
+		},
+		{
+			name:      "nok case - the hook stops the iteration",
+			path:      "/link",
+			paginator: LinkPagination{},
+			newResult: newItems,
+			opts: []PaginationOption{WithPageHook(func(p *Page) error {
+				if p.Number == 2 {
+					return errors.New("stop")
+				}
+				return nil
+			})},
+			want:      []int{1, 2, 3},
+			wantPages: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "nok case - the server return an error",
+			path:      "/error",
+			paginator: LinkPagination{},
+			newResult: newItems,
+			opts: []PaginationOption{WithPageResultError(func() any {
+				return &ProblemDetails{}
+			})},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{
+				baseURL:    s.URL,
+				httpClient: &http.Client{},
+			}
+			it := c.Paginate(context.Background(), tt.path, tt.paginator, tt.newResult, tt.opts...)
+			defer it.Close()
+
+			var got []int
+			pages := 0
+			for it.Next() {
+				pages++
+				switch result := it.Page().Result.(type) {
+				case *[]Item:
+					for _, item := range *result {
+						got = append(got, item.ID)
+					}
+				case *CursorItems:
+					for _, item := range result.Items {
+						got = append(got, item.ID)
+					}
+				}
+			}
+			if (it.Err() != nil) != tt.wantErr {
+				t.Errorf("PageIterator.Err() error = %v, wantErr %v", it.Err(), tt.wantErr)
+				return
+			}
+			if pages != tt.wantPages {
+				t.Errorf("PageIterator pages = %v, want %v", pages, tt.wantPages)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PageIterator items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkPagination_CrossOrigin(t *testing.T) {
+	var headers http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer other.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+other.URL+`/items?page=2>; rel="next"`)
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer s.Close()
+
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+	}
+	requestHeaders := http.Header{}
+	requestHeaders.Set(authorizationHeaderKey, "Bearer token")
+	requestHeaders.Set("X-Api-Key", "secret")
+	requestHeaders.Set("Accept-Language", "en")
+	it := c.Paginate(context.Background(), "/items", LinkPagination{SensitiveHeaders: []string{"X-Api-Key"}},
+		func() any { return &[]Item{} }, WithPageRequestOptions(WithHeaders(requestHeaders)))
+	defer it.Close()
+	for it.Next() {
+	}
+	if it.Err() != nil {
+		t.Fatalf("PageIterator.Err() error = %v", it.Err())
+	}
+	if headers == nil {
+		t.Fatal("the next link is not requested")
+	}
+	for _, header := range []string{authorizationHeaderKey, "X-Api-Key"} {
+		if got := headers.Get(header); got != "" {
+			t.Errorf("%s = %v, want empty", header, got)
+		}
+	}
+	if got := headers.Get("Accept-Language"); got != "en" {
+		t.Errorf("Accept-Language = %v, want en", got)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := parseLinkHeader([]string{
+		`<https://example.com/?page=2>; rel="next last"; title="a, \"b\"", <https://example.com/?page=1>;rel=prev`,
+	})
+	want := []link{
+		{target: "https://example.com/?page=2", params: map[string]string{"rel": "next last", "title": `a, "b"`}},
+		{target: "https://example.com/?page=1", params: map[string]string{"rel": "prev"}},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("parseLinkHeader() = %v, want %v", links, want)
+	}
+	if !links[0].hasRel("last") || links[1].hasRel("next") {
+		t.Errorf("link.hasRel() returns the wrong relations")
+	}
+}
+
+func TestPageIterator_All(t *testing.T) {
+	s := newPaginatedServer()
+	defer s.Close()
+
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+	}
+	pages := 0
+	it := c.Paginate(context.Background(), "/link", LinkPagination{}, func() any { return &[]Item{} })
+	it.All()(func(p *Page, err error) bool {
+		if err != nil {
+			t.Errorf("PageIterator.All() error = %v", err)
+			return false
+		}
+		pages++
+		return pages < 2
+	})
+	if pages != 2 {
+		t.Errorf("PageIterator.All() pages = %v, want 2", pages)
+	}
+	if it.Next() {
+		t.Errorf("PageIterator.Next() after the break = true, want false")
+	}
+}
+
+func TestPageIterator_NextUnlocked(t *testing.T) {
+	arrived, release := make(chan struct{}, 1), make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer s.Close()
+
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+	}
+	it := c.Paginate(context.Background(), "/", LinkPagination{}, func() any { return &[]Item{} })
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+
+	// The state is readable during the round trip of Next
+	<-arrived
+	read := make(chan struct{})
+	go func() {
+		_, _ = it.Page(), it.Err()
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("PageIterator.Err() blocked by PageIterator.Next()")
+	}
+	close(release)
+	if !<-done {
+		t.Errorf("PageIterator.Next() = false, want true, error = %v", it.Err())
+	}
+}
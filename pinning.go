@@ -0,0 +1,140 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// PinningConfig is the configuration of the SPKI pinning of the servers
+type PinningConfig struct {
+	// Pins are the base64 SHA-256 hashes of the SubjectPublicKeyInfo by host,
+	// with an optional sha256/ prefix. A host like *.example.com pins the subdomains.
+	// The hosts are matched with the TLS server name, IP addresses can't be pinned.
+	// A backup pin of a key not yet deployed should be kept for the rotations.
+	Pins map[string][]string
+	// ReportOnly reports the violations to OnViolation without closing the connection
+	ReportOnly bool
+	// OnViolation is called for every violation, also when they are enforced
+	OnViolation func(*PinningError)
+}
+
+// PinningError is the violation of the pins of a host
+type PinningError struct {
+	Host string
+	// Pins are the SPKI pins of the certificates presented by the server
+	Pins []string
+	// Expected are the pins of the host
+	Expected []string
+}
+
+func (e *PinningError) Error() string {
+	return fmt.Sprintf("httpclient: certificate pinning violation for %s: got %s, want one of %s",
+		e.Host, strings.Join(e.Pins, ", "), strings.Join(e.Expected, ", "))
+}
+
+// SPKIPin returns the base64 SHA-256 hash of the SubjectPublicKeyInfo of the certificate
+func SPKIPin(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinsOf returns the pins of the host, the exact host first then the wildcard
+func (p PinningConfig) pinsOf(host string) []string {
+	host = strings.ToLower(host)
+	if pins, ok := p.Pins[host]; ok {
+		return pins
+	}
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		return p.Pins["*."+parent]
+	}
+	return nil
+}
+
+// verifyConnection checks the verified chains of the server against the pins of the host.
+// The other certificates sent by the server are not verified and are ignored, only the
+// leaf is checked when the verification is skipped.
+func (p PinningConfig) verifyConnection(cs tls.ConnectionState, insecure bool) error {
+	expected := p.pinsOf(cs.ServerName)
+	if len(expected) == 0 {
+		return nil
+	}
+	var certificates []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certificates = append(certificates, chain...)
+	}
+	if len(cs.VerifiedChains) == 0 && insecure && len(cs.PeerCertificates) > 0 {
+		certificates = cs.PeerCertificates[:1]
+	}
+	seen := map[string]bool{}
+	var pins []string
+	for _, certificate := range certificates {
+		pin := SPKIPin(certificate)
+		if seen[pin] {
+			continue
+		}
+		seen[pin] = true
+		pins = append(pins, pin)
+		for _, want := range expected {
+			if strings.TrimPrefix(want, "sha256/") == pin {
+				return nil
+			}
+		}
+	}
+
+	violation := &PinningError{Host: cs.ServerName, Pins: pins, Expected: expected}
+	if p.OnViolation != nil {
+		p.OnViolation(violation)
+	}
+	if p.ReportOnly {
+		return nil
+	}
+	return violation
+}
+
+// WithCertificatePinning is to verify the chains of the servers against SHA-256 SPKI pins
+// after the verification of the certificates. A violation closes the connection with a
+// *PinningError unless the report-only mode is set.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.payment.com",
+//		httpclient.WithCertificatePinning(httpclient.PinningConfig{
+//			Pins: map[string][]string{
+//				"api.payment.com": {
+//					"sha256/r/mIkG3eEpVdm+u/ko/cwxzOMo1bk4TyHIlByibiA5E=", // current key
+//					"sha256/YLh1dUR9y6Kja30RrAn7JKnbQG/uEtLMkBgFF2Fuihg=", // backup key
+//				},
+//			},
+//		}))
+func WithCertificatePinning(config PinningConfig) ClientsOption {
+	return func(cc *clientConfig) {
+		pins := make(map[string][]string, len(config.Pins))
+		for host, hostPins := range config.Pins {
+			for _, pin := range hostPins {
+				hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+				if err != nil || len(hash) != sha256.Size {
+					cc.err = fmt.Errorf("httpclient: invalid SPKI pin %s for %s", pin, host)
+					return
+				}
+			}
+			pins[strings.ToLower(host)] = hostPins
+		}
+		config.Pins = pins
+		cc.withTLS(func(tlsConfig *tls.Config) {
+			// The verification of the transport is kept
+			previous := tlsConfig.VerifyConnection
+			tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				if previous != nil {
+					if err := previous(cs); err != nil {
+						return err
+					}
+				}
+				return config.verifyConnection(cs, tlsConfig.InsecureSkipVerify)
+			}
+		})
+	}
+}
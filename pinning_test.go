@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithCertificatePinning(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(s.Certificate())
+	serverPin := SPKIPin(s.Certificate())
+	otherPin := "sha256/YLh1dUR9y6Kja30RrAn7JKnbQG/uEtLMkBgFF2Fuihg="
+	// The certificate of the server is valid for example.com
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, s.Listener.Addr().String())
+	}
+
+	tests := []struct {
+		name           string
+		config         PinningConfig
+		wantErr        bool
+		wantViolations int
+	}{
+		{
+			name:   "ok case - backup pin",
+			config: PinningConfig{Pins: map[string][]string{"example.com": {otherPin, "sha256/" + serverPin}}},
+		},
+		{
+			name:   "ok case - host without pins",
+			config: PinningConfig{Pins: map[string][]string{"api.payment.com": {otherPin}}},
+		},
+		{
+			name:           "nok case - violation",
+			config:         PinningConfig{Pins: map[string][]string{"example.com": {otherPin}}},
+			wantErr:        true,
+			wantViolations: 1,
+		},
+		{
+			name:           "ok case - report only",
+			config:         PinningConfig{Pins: map[string][]string{"example.com": {otherPin}}, ReportOnly: true},
+			wantViolations: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var violations []*PinningError
+			tt.config.OnViolation = func(e *PinningError) { violations = append(violations, e) }
+			c, err := NewClient("https://example.com", WithTransport(transport), WithRootCAs(rootCAs), WithCertificatePinning(tt.config))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			_, err = c.Get(context.Background(), "/", nil, nil)
+			var pinningErr *PinningError
+			if tt.wantErr != errors.As(err, &pinningErr) {
+				t.Errorf("Client.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(violations) != tt.wantViolations {
+				t.Fatalf("OnViolation() calls = %v, want %v", len(violations), tt.wantViolations)
+			}
+			if len(violations) > 0 && (violations[0].Host != "example.com" || violations[0].Pins[0] != serverPin) {
+				t.Errorf("OnViolation() error = %+v", violations[0])
+			}
+		})
+	}
+
+	// The verification of the transport is called before the pinning
+	verified := 0
+	custom := transport.Clone()
+	custom.TLSClientConfig = &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		verified++
+		return nil
+	}}
+	c, err := NewClient("https://example.com", WithTransport(custom), WithRootCAs(rootCAs),
+		WithCertificatePinning(PinningConfig{Pins: map[string][]string{"example.com": {serverPin}}}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	resp, err := c.Do(r)
+	if err != nil || verified != 1 {
+		t.Fatalf("Client.Do() error = %v, VerifyConnection calls = %v", err, verified)
+	}
+	resp.Body.Close()
+
+	if _, err := NewClient(s.URL, WithCertificatePinning(PinningConfig{Pins: map[string][]string{"a.com": {"invalid"}}})); err == nil {
+		t.Errorf("NewClient() with invalid pin error = nil")
+	}
+}
+
+func TestPinningConfig_verifyConnection(t *testing.T) {
+	_, _, root, rootKey := testCertificate(t, "root", nil, nil)
+	_, _, leaf, _ := testCertificate(t, "leaf", root, rootKey)
+	// The pinned CA of another chain is appended by the server
+	_, _, pinned, _ := testCertificate(t, "pinned", nil, nil)
+	config := PinningConfig{Pins: map[string][]string{"example.com": {SPKIPin(pinned)}}}
+
+	tests := []struct {
+		name     string
+		cs       tls.ConnectionState
+		insecure bool
+		wantErr  bool
+	}{
+		{
+			name: "ok case - pin in the verified chain",
+			cs: tls.ConnectionState{
+				ServerName:       "example.com",
+				PeerCertificates: []*x509.Certificate{leaf},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, pinned}},
+			},
+		},
+		{
+			name: "nok case - pin sent out of the verified chain",
+			cs: tls.ConnectionState{
+				ServerName:       "example.com",
+				PeerCertificates: []*x509.Certificate{leaf, pinned},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, root}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ok case - leaf pinned without verification",
+			cs: tls.ConnectionState{
+				ServerName:       "example.com",
+				PeerCertificates: []*x509.Certificate{pinned, root},
+			},
+			insecure: true,
+		},
+		{
+			name: "nok case - pin after the leaf without verification",
+			cs: tls.ConnectionState{
+				ServerName:       "example.com",
+				PeerCertificates: []*x509.Certificate{leaf, pinned},
+			},
+			insecure: true,
+			wantErr:  true,
+		},
+		{
+			name: "nok case - no verified chain",
+			cs: tls.ConnectionState{
+				ServerName:       "example.com",
+				PeerCertificates: []*x509.Certificate{pinned},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config.verifyConnection(tt.cs, tt.insecure)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PinningConfig.verifyConnection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPinningConfig_pinsOf(t *testing.T) {
+	config := PinningConfig{Pins: map[string][]string{"*.payment.com": {"wildcard"}, "api.payment.com": {"exact"}}}
+	tests := map[string]string{"api.payment.com": "exact", "EU.payment.com": "wildcard", "a.b.payment.com": "", "payment.com": ""}
+	for host, want := range tests {
+		got := ""
+		if pins := config.pinsOf(host); len(pins) > 0 {
+			got = pins[0]
+		}
+		if got != want {
+			t.Errorf("PinningConfig.pinsOf(%s) = %v, want %v", host, got, want)
+		}
+	}
+}
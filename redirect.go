@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrTooManyRedirects   = errors.New("httpclient: too many redirects")
+	ErrRedirectNotAllowed = errors.New("httpclient: redirect not allowed")
+)
+
+const defaultMaxRedirects = 10
+
+// Headers removed on the cross-origin redirects
+var defaultSensitiveHeaders = []string{authorizationHeaderKey, proxyAuthorizationHeaderKey, "Cookie"}
+
+// Redirect is a redirect followed by the client
+type Redirect struct {
+	// StatusCode of the redirect response
+	StatusCode int
+	// URL of the next request
+	URL *url.URL
+}
+
+// redirectConfig is the redirect policy of the client
+type redirectConfig struct {
+	max              int
+	disabled         bool
+	sameHost         bool
+	policy           func(r *http.Request, via []*http.Request) error
+	sensitiveHeaders []string
+}
+
+func (cc *clientConfig) redirectConfig() *redirectConfig {
+	if cc.redirect == nil {
+		cc.redirect = &redirectConfig{max: defaultMaxRedirects}
+	}
+	return cc.redirect
+}
+
+// WithMaxRedirects is to set the maximum number of redirects followed, 10 by default.
+// Zero is like WithoutRedirects, the 3xx response is returned.
+func WithMaxRedirects(max int) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.redirectConfig().max = max
+	}
+}
+
+// WithoutRedirects is to not follow the redirects, the 3xx response is returned
+func WithoutRedirects() ClientsOption {
+	return func(cc *clientConfig) {
+		cc.redirectConfig().disabled = true
+	}
+}
+
+// WithSameHostRedirects is to follow only the redirects to the host of the request
+func WithSameHostRedirects() ClientsOption {
+	return func(cc *clientConfig) {
+		cc.redirectConfig().sameHost = true
+	}
+}
+
+// WithRedirectPolicy is to check the redirects with a callback, like the CheckRedirect
+// of http.Client. It's called after the other checks.
+func WithRedirectPolicy(policy func(r *http.Request, via []*http.Request) error) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.redirectConfig().policy = policy
+	}
+}
+
+// WithSensitiveHeaders is to remove headers on the cross-origin redirects in addition
+// to Authorization, Proxy-Authorization and Cookie
+func WithSensitiveHeaders(headers ...string) ClientsOption {
+	return func(cc *clientConfig) {
+		config := cc.redirectConfig()
+		config.sensitiveHeaders = append(config.sensitiveHeaders, headers...)
+	}
+}
+
+// checkRedirect returns the CheckRedirect of the http.Client.
+// The method and the body are kept on 307 and 308 by the http.Client.
+func (c *redirectConfig) checkRedirect(r *http.Request, via []*http.Request) error {
+	if c.disabled || c.max <= 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= c.max {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, c.max)
+	}
+	first := via[0].URL
+	if c.sameHost && !strings.EqualFold(r.URL.Host, first.Host) {
+		return fmt.Errorf("%w: %s is not %s", ErrRedirectNotAllowed, r.URL.Host, first.Host)
+	}
+	// The headers are copied from the first request at every hop
+	if !sameOrigin(r.URL, first) {
+		for _, header := range defaultSensitiveHeaders {
+			r.Header.Del(header)
+		}
+		for _, header := range c.sensitiveHeaders {
+			r.Header.Del(header)
+		}
+	}
+	if c.policy != nil {
+		return c.policy(r, via)
+	}
+	return nil
+}
+
+// sameOrigin compares the scheme, the host and the port of the URLs
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		portOrDefault(a) == portOrDefault(b)
+}
+
+func portOrDefault(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+// redirectsOf returns the redirects followed to get the response
+func redirectsOf(resp *http.Response) []Redirect {
+	if resp == nil {
+		return nil
+	}
+	var redirects []Redirect
+	for r := resp.Request; r != nil && r.Response != nil; r = r.Response.Request {
+		redirects = append(redirects, Redirect{StatusCode: r.Response.StatusCode, URL: r.URL})
+	}
+	// The chain is walked from the last request
+	for i, j := 0, len(redirects)-1; i < j; i, j = i+1, j-1 {
+		redirects[i], redirects[j] = redirects[j], redirects[i]
+	}
+	return redirects
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Redirects(t *testing.T) {
+	// echo returns the method, the body and the headers of the request
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"method":        r.Method,
+			"body":          string(body),
+			"authorization": r.Header.Get(authorizationHeaderKey),
+			"token":         r.Header.Get("X-Token"),
+		})
+	}
+	other := httptest.NewServer(http.HandlerFunc(echo))
+	defer other.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			http.Redirect(w, r, "/moved", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/echo", http.StatusMovedPermanently)
+		case "/temporary":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/cross":
+			http.Redirect(w, r, other.URL+"/echo", http.StatusTemporaryRedirect)
+		default:
+			echo(w, r)
+		}
+	}))
+	defer s.Close()
+
+	headers := http.Header{}
+	headers.Set(authorizationHeaderKey, "Bearer secret")
+	headers.Set("X-Token", "secret")
+
+	tests := []struct {
+		name          string
+		opts          []ClientsOption
+		path          string
+		want          map[string]string
+		wantStatus    int
+		wantRedirects []int
+		wantErr       error
+	}{
+		{
+			name:          "ok case - chain recorded and POST changed to GET",
+			path:          "/found",
+			want:          map[string]string{"method": http.MethodGet, "body": "", "authorization": "Bearer secret", "token": "secret"},
+			wantStatus:    http.StatusOK,
+			wantRedirects: []int{http.StatusFound, http.StatusMovedPermanently},
+		},
+		{
+			name:          "ok case - method and body kept on 307",
+			path:          "/temporary",
+			want:          map[string]string{"method": http.MethodPost, "body": "payload", "authorization": "Bearer secret", "token": "secret"},
+			wantStatus:    http.StatusOK,
+			wantRedirects: []int{http.StatusTemporaryRedirect},
+		},
+		{
+			name:          "ok case - sensitive headers removed on cross-origin",
+			opts:          []ClientsOption{WithSensitiveHeaders("X-Token")},
+			path:          "/cross",
+			want:          map[string]string{"method": http.MethodPost, "body": "payload", "authorization": "", "token": ""},
+			wantStatus:    http.StatusOK,
+			wantRedirects: []int{http.StatusTemporaryRedirect},
+		},
+		{
+			name:       "ok case - redirects disabled",
+			opts:       []ClientsOption{WithoutRedirects()},
+			path:       "/found",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "ok case - zero max redirects",
+			opts:       []ClientsOption{WithMaxRedirects(0)},
+			path:       "/found",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:    "nok case - max redirects",
+			opts:    []ClientsOption{WithMaxRedirects(1)},
+			path:    "/found",
+			wantErr: ErrTooManyRedirects,
+		},
+		{
+			name:    "nok case - loop",
+			path:    "/loop",
+			opts:    []ClientsOption{WithSameHostRedirects()},
+			wantErr: ErrTooManyRedirects,
+		},
+		{
+			name:    "nok case - same host only",
+			opts:    []ClientsOption{WithSameHostRedirects()},
+			path:    "/cross",
+			wantErr: ErrRedirectNotAllowed,
+		},
+		{
+			name: "nok case - policy",
+			opts: []ClientsOption{WithRedirectPolicy(func(r *http.Request, via []*http.Request) error {
+				return ErrRedirectNotAllowed
+			})},
+			path:    "/temporary",
+			wantErr: ErrRedirectNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(s.URL, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var got map[string]string
+			resp, err := c.Post(context.Background(), tt.path, "payload", &got, nil, WithHeaders(headers))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Client.Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if resp.RawResponse.StatusCode != tt.wantStatus {
+				t.Errorf("Client.Post() status = %v, want %v", resp.RawResponse.StatusCode, tt.wantStatus)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("Client.Post() %s = %v, want %v", key, got[key], value)
+				}
+			}
+			if len(resp.Redirects) != len(tt.wantRedirects) {
+				t.Fatalf("Client.Post() redirects = %+v, want %v", resp.Redirects, tt.wantRedirects)
+			}
+			for i, status := range tt.wantRedirects {
+				if resp.Redirects[i].StatusCode != status {
+					t.Errorf("Client.Post() redirect %d = %+v, want %v", i, resp.Redirects[i], status)
+				}
+			}
+		})
+	}
+}
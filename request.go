@@ -10,14 +10,16 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 var ErrResponseBodyTooLarge = errors.New("httpclient: response body too large")
 
 // Constants for some headers
 const (
-	userAgentHeaderKey   = "User-Agent"
-	contentTypeHeaderKey = "Content-Type"
+	userAgentHeaderKey     = "User-Agent"
+	contentTypeHeaderKey   = "Content-Type"
+	contentLengthHeaderKey = "Content-Length"
 )
 
 // NewRequest method returns the http.Request if your need to create your own request.
@@ -51,10 +53,43 @@ func (c *Client) newRequestWithContext(ctx context.Context,
 	body any,
 	config *requestConfig) (*http.Request, error) {
 
+	// An option may have failed
+	if config.err != nil {
+		return nil, config.err
+	}
+
 	// Set the URL
+	route := path
+	if config.pathParams != nil {
+		var err error
+		path, err = ExpandURITemplate(path, config.pathParams)
+		if err != nil {
+			return nil, err
+		}
+	}
 	uri := c.baseURL + path
+	// An absolute URL, supplied by a user for example, is not under the base URL
+	if u, err := url.Parse(path); err == nil && u.IsAbs() && u.Host != "" {
+		uri = path
+	}
+
+	var (
+		reader          io.Reader
+		soapContentType string
+	)
+	// Wrap the body in a SOAP envelope
+	if config.soap != nil && !config.soap.enabled {
+		return nil, ErrMissingSOAP
+	}
+	if config.soap != nil {
+		var err error
+		reader, soapContentType, err = encodeSOAP(body, config.soap)
+		if err != nil {
+			return nil, err
+		}
+		body = nil
+	}
 
-	var reader io.Reader
 	// Add Body here
 	switch body := body.(type) {
 	case []byte:
@@ -110,21 +145,50 @@ func (c *Client) newRequestWithContext(ctx context.Context,
 	} else if config.isXml {
 		r.Header.Set(contentTypeHeaderKey, "application/xml")
 	}
+	if config.soap != nil {
+		r.Header.Set(contentTypeHeaderKey, soapContentType)
+		if config.soap.version == SOAP11 {
+			r.Header.Set(soapActionHeaderKey, fmt.Sprintf(`"%s"`, escapeQuotes(config.soap.action)))
+		}
+	}
+
+	// Default headers of the session, overridden by the headers of the request
+	if c.session != nil {
+		for key, values := range c.session.Header() {
+			r.Header[key] = values
+		}
+	}
 
 	// Add headers
-	for key := range config.headers {
-		r.Header.Set(key, config.headers.Get(key))
+	for key, values := range config.headers {
+		r.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
 	}
 	r.Header.Set(userAgentHeaderKey, c.userAgent)
+	if err := c.setIdempotencyKey(r, config.idempotencyKey); err != nil {
+		return nil, err
+	}
+	if err := c.compressBody(r, config.compression); err != nil {
+		return nil, err
+	}
 
-	// Add queries
-	var queries url.Values
-	for p, v := range config.queries {
-		queries.Add(p, v)
+	// Add queries, keep the queries already present in the path
+	if len(config.queries) > 0 || len(config.queryValues) > 0 {
+		queries := r.URL.Query()
+		for p, v := range config.queries {
+			queries.Add(p, v)
+		}
+		for p, v := range config.queryValues {
+			queries[p] = append(queries[p], v...)
+		}
+		r.URL.RawQuery = queries.Encode()
 	}
-	r.URL.RawQuery = queries.Encode()
 
-	return r, nil
+	// Keep the unexpanded path as route
+	ctx = withRoute(r.Context(), route)
+	if config.timeout != nil {
+		ctx = context.WithValue(ctx, timeoutContextKey{}, *config.timeout)
+	}
+	return r.WithContext(ctx), nil
 }
 
 // RequestOption is to create convenient request options like wait custom fields for http.request
@@ -132,10 +196,23 @@ type RequestOption func(*requestConfig)
 
 // requestConfig is to create http.request options
 type requestConfig struct {
-	isJson  bool
-	isXml   bool
-	headers http.Header
-	queries map[string]string
+	isJson      bool
+	isXml       bool
+	headers     http.Header
+	queries     map[string]string
+	queryValues url.Values
+	pathParams  map[string]any
+	soap        *soapConfig
+	timeout     *time.Duration
+
+	// Idempotency-Key of the caller
+	idempotencyKey string
+
+	// Compression of the body, the one of the host when nil
+	compression *BodyCompression
+
+	// First error raised by an option
+	err error
 }
 
 func newRequestConfig() *requestConfig {
@@ -160,10 +237,11 @@ func WithIsXml() RequestOption {
 	}
 }
 
-// WithHeaders is when you need to add specific headers in your request
+// WithHeaders is when you need to add specific headers in your request.
+// The headers are copied, the other options don't modify them.
 func WithHeaders(headers http.Header) RequestOption {
 	return func(rc *requestConfig) {
-		rc.headers = headers
+		rc.headers = headers.Clone()
 	}
 }
 
@@ -174,3 +252,19 @@ func WithQueries(
 		rc.queries = queries
 	}
 }
+
+// WithPathParams is when the path is a RFC6570 URI template like /users/{id}/repos{?type,sort}.
+// The path is expanded with the params and the template is kept as route, see [Route].
+// The params are copied, the other options don't modify them.
+func WithPathParams(params map[string]any) RequestOption {
+	return func(rc *requestConfig) {
+		if params == nil {
+			rc.pathParams = nil
+			return
+		}
+		rc.pathParams = make(map[string]any, len(params))
+		for key, value := range params {
+			rc.pathParams[key] = value
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -10,4 +11,33 @@ type Response struct {
 	Request *http.Request
 	// Raw response receive by the client
 	RawResponse *http.Response
+	// Redirects followed to get the response
+	Redirects []Redirect
+}
+
+// StatusError is returned when the server refuses the request with
+// a status code upper or equal to 400
+type StatusError struct {
+	// Status code of the response
+	StatusCode int
+	// Status of the response like 404 Not Found
+	Status string
+	// Raw body of the response
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: the server return %s", e.Status)
+}
+
+// newStatusError returns a *StatusError if the response is an error
+func newStatusError(r *http.Response, body []byte) error {
+	if r == nil || r.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	return &StatusError{
+		StatusCode: r.StatusCode,
+		Status:     r.Status,
+		Body:       body,
+	}
 }
@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is the policy of the retries of WithRetry
+type RetryPolicy struct {
+	// MaxAttempts of a request including the first one, 3 by default
+	MaxAttempts int
+	// Backoff before the first retry, doubled at every retry with a jitter, 100ms by default
+	Backoff time.Duration
+	// MaxBackoff between two attempts, 5 seconds by default. The Retry-After
+	// of the server is not shortened by MaxBackoff.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the longest Retry-After waited, 1 minute by default.
+	// The response is returned without retry when the server asks a longer wait.
+	MaxRetryAfter time.Duration
+	// Retryable returns true if the attempt must be retried, by default
+	// the errors of the connection and the 429, 502, 503 and 504 responses
+	Retryable func(resp *http.Response, err error) bool
+}
+
+// retryable is the default Retryable of a RetryPolicy
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// canRetry returns true if the request can be sent again: an idempotent
+// method or an Idempotency-Key, and a body which can be rewound
+func canRetry(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	return isIdempotentMethod(r.Method) || r.Header.Get(idempotencyKeyHeaderKey) != ""
+}
+
+// retryAfter returns the delay of the Retry-After header, in seconds or
+// an HTTP-date as in the section 10.2.3 of RFC 9110
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	if wait := time.Until(date); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// WithRetry is a Decorator sending the request again after a retryable error or
+// response. The POST and PATCH requests are retried only with an Idempotency-Key,
+// see WithIdempotencyKeys. A retry never exceeds the deadline of the request.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithDecorator(httpclient.WithRetry(httpclient.RetryPolicy{
+//			MaxAttempts: 4,
+//			Backoff:     200 * time.Millisecond,
+//		})))
+func WithRetry(policy RetryPolicy) Decorator {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 5 * time.Second
+	}
+	if policy.MaxRetryAfter <= 0 {
+		policy.MaxRetryAfter = time.Minute
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = retryable
+	}
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := d.Do(r)
+			if !canRetry(r) {
+				return resp, err
+			}
+			backoff := policy.Backoff
+			for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+				if r.Context().Err() != nil || !policy.Retryable(resp, err) {
+					return resp, err
+				}
+				retry, rewindErr := rewindRequest(r)
+				if rewindErr != nil {
+					return resp, err
+				}
+
+				// Full jitter, the Retry-After of the server is respected
+				wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+				if after := retryAfter(resp); after > policy.MaxRetryAfter {
+					return resp, err
+				} else if after > wait {
+					wait = after
+				}
+				if deadline, ok := r.Context().Deadline(); ok && time.Until(deadline) < wait {
+					return resp, err
+				}
+				if resp != nil {
+					_, _ = io.Copy(io.Discard, resp.Body)
+					_ = resp.Body.Close()
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-r.Context().Done():
+					timer.Stop()
+					return nil, r.Context().Err()
+				case <-timer.C:
+				}
+
+				resp, err = d.Do(retry)
+				if backoff *= 2; backoff > policy.MaxBackoff {
+					backoff = policy.MaxBackoff
+				}
+			}
+			return resp, err
+		})
+	}
+}
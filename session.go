@@ -0,0 +1,320 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionOptions is the configuration of a Session
+type SessionOptions struct {
+	// PublicSuffixList of the cookie jar, like publicsuffix.List of golang.org/x/net.
+	// Without it, a cookie can be set for a whole top-level domain.
+	PublicSuffixList cookiejar.PublicSuffixList
+	// Path of the JSON file of the session, loaded by NewSession and written by Save.
+	// The session is not persisted when empty.
+	Path string
+	// Headers sent with every request of the session, like Authorization
+	Headers http.Header
+}
+
+// Session keeps the cookies and the default headers of the requests between the
+// calls, and between the runs when it is persisted. It's the http.CookieJar of the client.
+type Session struct {
+	psl  cookiejar.PublicSuffixList
+	path string
+
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]sessionCookie
+	headers http.Header
+}
+
+// sessionCookie is a cookie of the session with the URL which set it
+type sessionCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Path     string        `json:"path,omitempty"`
+	Domain   string        `json:"domain,omitempty"`
+	Expires  time.Time     `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// sessionFile is the JSON file of a persisted session
+type sessionFile struct {
+	Headers http.Header     `json:"headers,omitempty"`
+	Cookies []sessionCookie `json:"cookies,omitempty"`
+}
+
+// NewSession returns a session, loaded from its file if it exists
+func NewSession(options SessionOptions) (*Session, error) {
+	s := &Session{
+		psl:     options.PublicSuffixList,
+		path:    options.Path,
+		headers: options.Headers.Clone(),
+	}
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	if err := s.reset(); err != nil {
+		return nil, err
+	}
+	if s.path == "" {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file sessionFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	// The headers of the options take precedence over the persisted ones
+	for key, values := range file.Headers {
+		if _, ok := s.headers[key]; !ok {
+			s.headers[key] = values
+		}
+	}
+	for _, cookie := range file.Cookies {
+		u, err := url.Parse(cookie.URL)
+		if err != nil {
+			continue
+		}
+		s.SetCookies(u, []*http.Cookie{cookie.cookie()})
+	}
+	return s, nil
+}
+
+// reset creates an empty cookie jar
+func (s *Session) reset() error {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: s.psl})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jar = jar
+	s.cookies = map[string]sessionCookie{}
+	return nil
+}
+
+// SetCookies stores the cookies received from the URL, it implements http.CookieJar
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		record := sessionCookie{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}
+		if cookie.MaxAge > 0 {
+			record.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		key := u.Hostname() + ";" + cookie.Domain + ";" + cookie.Path + ";" + cookie.Name
+		if cookie.MaxAge < 0 || (!record.Expires.IsZero() && record.Expires.Before(now)) {
+			delete(s.cookies, key)
+			continue
+		}
+		// The cookies refused by the jar, like for a public suffix, are not kept
+		if s.accepted(u, cookie) {
+			s.cookies[key] = record
+		}
+	}
+}
+
+// accepted returns true if the jar sends the cookie back to the URL, the lock must be held
+func (s *Session) accepted(u *url.URL, cookie *http.Cookie) bool {
+	check := *u
+	if cookie.Path != "" {
+		check.Path = cookie.Path
+	}
+	if cookie.Secure {
+		check.Scheme = "https"
+	}
+	for _, c := range s.jar.Cookies(&check) {
+		if c.Name == cookie.Name && c.Value == cookie.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cookies returns the cookies to send to the URL, it implements http.CookieJar
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jar.Cookies(u)
+}
+
+// AllCookies returns the cookies of the session which are not expired
+func (s *Session) AllCookies() []*http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	cookies := make([]*http.Cookie, 0, len(s.cookies))
+	for _, record := range s.records() {
+		if record.Expires.IsZero() || record.Expires.After(now) {
+			cookie := record.cookie()
+			if cookie.Domain == "" {
+				if u, err := url.Parse(record.URL); err == nil {
+					cookie.Domain = u.Hostname()
+				}
+			}
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+// ClearCookies removes all the cookies of the session
+func (s *Session) ClearCookies() {
+	_ = s.reset()
+}
+
+// Header returns a copy of the default headers of the session
+func (s *Session) Header() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers.Clone()
+}
+
+// SetHeader sets a default header of the session, it's removed when the value is empty
+func (s *Session) SetHeader(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value == "" {
+		s.headers.Del(key)
+		return
+	}
+	s.headers.Set(key, value)
+}
+
+// Save writes the session in its file, the file is replaced atomically
+// and readable only by its owner because it contains credentials
+func (s *Session) Save() error {
+	if s.path == "" {
+		return errors.New("httpclient: missing path of the session")
+	}
+	s.mu.Lock()
+	now := time.Now()
+	file := sessionFile{Headers: s.headers.Clone()}
+	for _, record := range s.records() {
+		if record.Expires.IsZero() || record.Expires.After(now) {
+			file.Cookies = append(file.Cookies, record)
+		}
+	}
+	s.mu.Unlock()
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// records returns the cookies sorted by key, the lock must be held
+func (s *Session) records() []sessionCookie {
+	keys := make([]string, 0, len(s.cookies))
+	for key := range s.cookies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	records := make([]sessionCookie, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, s.cookies[key])
+	}
+	return records
+}
+
+func (c sessionCookie) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+// WithSession is to keep the cookies and the default headers of the session
+//
+// For Example:
+//
+//	session, err := httpclient.NewSession(httpclient.SessionOptions{
+//		PublicSuffixList: publicsuffix.List,
+//		Path:             "session.json",
+//	})
+//	client, err := httpclient.NewClient("https://app.example.com", httpclient.WithSession(session))
+//	defer session.Save()
+func WithSession(session *Session) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.session = session
+	}
+}
+
+// jarContextKey is the key of the cookie jar of the client in the context of the
+// requests, the http.Client adds the cookies of the jar after the Decorators
+type jarContextKey struct{}
+
+// withJar returns the request with the cookie jar of the client in its context
+func (c *Client) withJar(r *http.Request) *http.Request {
+	if c.httpClient.Jar == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), jarContextKey{}, c.httpClient.Jar))
+}
+
+// ForSession returns a client sharing the configuration and the connections of
+// the client with the cookies and the default headers of another session
+func (c *Client) ForSession(session *Session) *Client {
+	derived := *c
+	httpClient := *c.httpClient
+	httpClient.Jar = nil
+	if session != nil {
+		httpClient.Jar = session
+	}
+	derived.httpClient = &httpClient
+	derived.session = session
+	return &derived
+}
@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tldList is a public suffix list where the suffix is the last label
+type tldList struct{}
+
+func (tldList) PublicSuffix(domain string) string {
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func (tldList) String() string { return "tld" }
+
+func TestClient_WithSession(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Path: "/"})
+			w.WriteHeader(http.StatusNoContent)
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			cookie, err := r.Cookie("sid")
+			if err != nil || cookie.Value != "abc" || r.Header.Get("X-Tenant") != "acme" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	session, err := NewSession(SessionOptions{Path: path, Headers: http.Header{"X-Tenant": {"acme"}}})
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	c, err := NewClient(s.URL, WithSession(session))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.Get(ctx, "/login", nil, nil); err != nil {
+		t.Fatalf("Client.Get() error = %v", err)
+	}
+	if resp, _ := c.Get(ctx, "/me", nil, nil); resp.RawResponse.StatusCode != http.StatusNoContent {
+		t.Errorf("Client.Get() status = %v, want 204", resp.RawResponse.StatusCode)
+	}
+	if cookies := session.AllCookies(); len(cookies) != 2 || cookies[0].Domain != "127.0.0.1" {
+		t.Errorf("Session.AllCookies() = %v", cookies)
+	}
+
+	// A derived client is isolated from the session of the client
+	other, _ := NewSession(SessionOptions{})
+	if resp, _ := c.ForSession(other).Get(ctx, "/me", nil, nil); resp.RawResponse.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Client.ForSession().Get() status = %v, want 401", resp.RawResponse.StatusCode)
+	}
+
+	// The session is restored from its file
+	if err := session.Save(); err != nil {
+		t.Fatalf("Session.Save() error = %v", err)
+	}
+	restored, err := NewSession(SessionOptions{Path: path})
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	if got := restored.Header().Get("X-Tenant"); got != "acme" {
+		t.Errorf("Session.Header() X-Tenant = %v, want acme", got)
+	}
+	if resp, _ := c.ForSession(restored).Get(ctx, "/me", nil, nil); resp.RawResponse.StatusCode != http.StatusNoContent {
+		t.Errorf("Client.ForSession().Get() status = %v, want 204", resp.RawResponse.StatusCode)
+	}
+
+	// The deleted cookies are removed
+	if _, err := c.Get(ctx, "/logout", nil, nil); err != nil {
+		t.Fatalf("Client.Get() error = %v", err)
+	}
+	if cookies := session.AllCookies(); len(cookies) != 1 || cookies[0].Name != "theme" {
+		t.Errorf("Session.AllCookies() after logout = %v", cookies)
+	}
+	session.ClearCookies()
+	if cookies := session.AllCookies(); len(cookies) != 0 {
+		t.Errorf("Session.AllCookies() after clear = %v", cookies)
+	}
+}
+
+func TestSession_PublicSuffixList(t *testing.T) {
+	session, err := NewSession(SessionOptions{PublicSuffixList: tldList{}})
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	u, _ := url.Parse("https://shop.example.com/")
+	session.SetCookies(u, []*http.Cookie{
+		{Name: "tracker", Value: "1", Domain: "com"},
+		{Name: "sid", Value: "2", Domain: "example.com"},
+	})
+	cookies := session.AllCookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" {
+		t.Errorf("Session.AllCookies() = %v, want only sid", cookies)
+	}
+	other, _ := url.Parse("https://other.com/")
+	if got := session.Cookies(other); len(got) != 0 {
+		t.Errorf("Session.Cookies() = %v, want none", got)
+	}
+
+	if err := session.Save(); err == nil {
+		t.Errorf("Session.Save() without path error = %v", err)
+	}
+}
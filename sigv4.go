@@ -0,0 +1,603 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants of the AWS Signature Version 4
+const (
+	sigV4Algorithm        = "AWS4-HMAC-SHA256"
+	sigV4PayloadAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4TimeFormat       = "20060102T150405Z"
+	sigV4DateFormat       = "20060102"
+	sigV4Terminator       = "aws4_request"
+
+	// UnsignedPayload is the payload hash when the body is not signed
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// StreamingPayload is the payload hash when the body is sent in signed chunks
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	amzDateHeaderKey          = "X-Amz-Date"
+	amzSecurityTokenHeaderKey = "X-Amz-Security-Token"
+	amzContentSHA256HeaderKey = "X-Amz-Content-Sha256"
+	amzDecodedLengthHeaderKey = "X-Amz-Decoded-Content-Length"
+
+	// emptySHA256 is the hash of an empty payload
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var ErrAWSCredentialsNotFound = errors.New("httpclient: AWS credentials not found")
+
+// Headers never signed because they can be changed by proxies or the transport
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// AWSCredentials are the credentials to sign the requests
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken of temporary credentials, sent in X-Amz-Security-Token
+	SessionToken string
+}
+
+// AWSCredentialsProvider provides the credentials to sign the requests
+type AWSCredentialsProvider interface {
+	Retrieve(ctx context.Context) (AWSCredentials, error)
+}
+
+// StaticAWSCredentials provides always the same credentials
+type StaticAWSCredentials AWSCredentials
+
+// Retrieve returns the credentials
+func (c StaticAWSCredentials) Retrieve(context.Context) (AWSCredentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return AWSCredentials{}, ErrAWSCredentialsNotFound
+	}
+	return AWSCredentials(c), nil
+}
+
+// EnvAWSCredentials provides the credentials from the environment variables
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+type EnvAWSCredentials struct{}
+
+// Retrieve returns the credentials of the environment
+func (EnvAWSCredentials) Retrieve(context.Context) (AWSCredentials, error) {
+	credentials := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID == "" {
+		credentials.AccessKeyID = os.Getenv("AWS_ACCESS_KEY")
+	}
+	if credentials.SecretAccessKey == "" {
+		credentials.SecretAccessKey = os.Getenv("AWS_SECRET_KEY")
+	}
+	return StaticAWSCredentials(credentials).Retrieve(context.Background())
+}
+
+// SharedAWSCredentials provides the credentials from the shared credentials file
+type SharedAWSCredentials struct {
+	// Filename of the file, AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials by default
+	Filename string
+	// Profile in the file, AWS_PROFILE or default by default
+	Profile string
+
+	once        sync.Once
+	credentials AWSCredentials
+	err         error
+}
+
+// Retrieve returns the credentials of the profile, the file is read once
+func (s *SharedAWSCredentials) Retrieve(context.Context) (AWSCredentials, error) {
+	s.once.Do(func() {
+		s.credentials, s.err = s.load()
+	})
+	return s.credentials, s.err
+}
+
+func (s *SharedAWSCredentials) load() (AWSCredentials, error) {
+	filename := s.Filename
+	if filename == "" {
+		filename = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		filename = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := s.Profile
+	if profile == "" {
+		profile = defaultString(os.Getenv("AWS_PROFILE"), "default")
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("%w: %v", ErrAWSCredentialsNotFound, err)
+	}
+	defer f.Close()
+
+	// Parse the ini file
+	var credentials AWSCredentials
+	section := ""
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		found = true
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "aws_access_key_id":
+			credentials.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			credentials.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			credentials.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return AWSCredentials{}, err
+	}
+	if !found {
+		return AWSCredentials{}, fmt.Errorf("%w: missing profile %s in %s", ErrAWSCredentialsNotFound, profile, filename)
+	}
+	return StaticAWSCredentials(credentials).Retrieve(context.Background())
+}
+
+// AWSCredentialsChain provides the credentials of the first provider without error
+type AWSCredentialsChain []AWSCredentialsProvider
+
+// Retrieve returns the credentials of the first provider without error
+func (c AWSCredentialsChain) Retrieve(ctx context.Context) (AWSCredentials, error) {
+	errs := make([]error, 0, len(c))
+	for _, provider := range c {
+		credentials, err := provider.Retrieve(ctx)
+		if err == nil {
+			return credentials, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return AWSCredentials{}, ErrAWSCredentialsNotFound
+	}
+	return AWSCredentials{}, errors.Join(errs...)
+}
+
+// DefaultAWSCredentials returns the chain of the environment and the shared credentials file
+func DefaultAWSCredentials() AWSCredentialsChain {
+	return AWSCredentialsChain{EnvAWSCredentials{}, &SharedAWSCredentials{}}
+}
+
+// SigV4Signer signs the requests with the AWS Signature Version 4
+type SigV4Signer struct {
+	// Credentials to sign the requests
+	Credentials AWSCredentialsProvider
+	// Region like us-east-1
+	Region string
+	// Service like s3 or execute-api
+	Service string
+	// UnsignedPayload is to not hash the body, X-Amz-Content-Sha256 is UNSIGNED-PAYLOAD
+	UnsignedPayload bool
+	// StreamingThreshold is the size of the body above which the body is sent
+	// in signed chunks (aws-chunked), 0 disables the streaming
+	StreamingThreshold int64
+	// ChunkSize is the size of the chunks in streaming, 64KB by default
+	ChunkSize int
+	// Now returns the time of the signature, time.Now by default
+	Now func() time.Time
+}
+
+// s3 returns true for the S3 service which does not normalize the path
+func (s *SigV4Signer) s3() bool {
+	return s.Service == "s3"
+}
+
+func (s *SigV4Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// Sign signs the request in the Authorization header, the body is read to
+// be hashed and replaced if the request can't be rewound
+func (s *SigV4Signer) Sign(r *http.Request) error {
+	credentials, err := s.Credentials.Retrieve(r.Context())
+	if err != nil {
+		return err
+	}
+	t := s.now()
+
+	r.Header.Del(authorizationHeaderKey)
+	r.Header.Set(amzDateHeaderKey, t.Format(sigV4TimeFormat))
+	if credentials.SessionToken != "" {
+		r.Header.Set(amzSecurityTokenHeaderKey, credentials.SessionToken)
+	}
+
+	streaming := s.StreamingThreshold > 0 && r.ContentLength > s.StreamingThreshold
+	var payloadHash string
+	switch {
+	case streaming:
+		payloadHash = StreamingPayload
+		// The encodings of the content like gzip follow aws-chunked
+		encoding := "aws-chunked"
+		if existing := r.Header.Get(contentEncodingHeaderKey); existing != "" {
+			encoding += "," + existing
+		}
+		r.Header.Set(contentEncodingHeaderKey, encoding)
+		r.Header.Set(amzDecodedLengthHeaderKey, strconv.FormatInt(r.ContentLength, 10))
+		r.ContentLength = awsChunkedLength(r.ContentLength, s.chunkSize())
+	case s.UnsignedPayload:
+		payloadHash = UnsignedPayload
+	default:
+		payloadHash, err = hashBody(r)
+		if err != nil {
+			return err
+		}
+	}
+	if s.s3() || streaming || s.UnsignedPayload {
+		r.Header.Set(amzContentSHA256HeaderKey, payloadHash)
+	}
+
+	scope := s.scope(t)
+	canonical, signedHeaders := s.canonicalRequest(r, r.URL.Query(), payloadHash, true)
+	signingKey := sigV4SigningKey(credentials.SecretAccessKey, t, s.Region, s.Service)
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign(t, scope, canonical)))
+
+	r.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+
+	if streaming {
+		body := r.Body
+		getBody := r.GetBody
+		newReader := func(body io.ReadCloser) io.ReadCloser {
+			return &awsChunkedReader{
+				body:      body,
+				chunkSize: s.chunkSize(),
+				key:       signingKey,
+				date:      t.Format(sigV4TimeFormat),
+				scope:     scope,
+				signature: signature,
+			}
+		}
+		r.Body = newReader(body)
+		if getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newReader(body), nil
+			}
+		}
+	}
+	return nil
+}
+
+// Presign returns the URL of the request signed in the queries, valid for the duration
+func (s *SigV4Signer) Presign(r *http.Request, expires time.Duration) (string, error) {
+	credentials, err := s.Credentials.Retrieve(r.Context())
+	if err != nil {
+		return "", err
+	}
+	t := s.now()
+	scope := s.scope(t)
+
+	u := *r.URL
+	queries := u.Query()
+	queries.Set("X-Amz-Algorithm", sigV4Algorithm)
+	queries.Set("X-Amz-Credential", credentials.AccessKeyID+"/"+scope)
+	queries.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	queries.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	if credentials.SessionToken != "" {
+		queries.Set(amzSecurityTokenHeaderKey, credentials.SessionToken)
+	}
+
+	presigned := r.Clone(r.Context())
+	presigned.URL = &u
+	_, signedHeaders := s.canonicalRequest(presigned, nil, "", false)
+	queries.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	payloadHash := UnsignedPayload
+	if !s.s3() {
+		if payloadHash, err = hashBody(presigned); err != nil {
+			return "", err
+		}
+	}
+	canonical, _ := s.canonicalRequest(presigned, queries, payloadHash, false)
+	signingKey := sigV4SigningKey(credentials.SecretAccessKey, t, s.Region, s.Service)
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign(t, scope, canonical)))
+
+	u.RawQuery = canonicalQuery(queries) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+func (s *SigV4Signer) chunkSize() int {
+	if s.ChunkSize <= 0 {
+		return 64 * 1024
+	}
+	return s.ChunkSize
+}
+
+// scope returns the credential scope
+func (s *SigV4Signer) scope(t time.Time) string {
+	return strings.Join([]string{t.Format(sigV4DateFormat), s.Region, s.Service, sigV4Terminator}, "/")
+}
+
+// canonicalRequest returns the canonical request and the signed headers.
+// All the headers are signed, only the host is signed in a presigned URL.
+func (s *SigV4Signer) canonicalRequest(r *http.Request, queries url.Values, payloadHash string, allHeaders bool) (string, string) {
+	headers := map[string][]string{}
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	headers["host"] = []string{host}
+	if allHeaders {
+		for key, values := range r.Header {
+			key = strings.ToLower(key)
+			if sigV4IgnoredHeaders[key] {
+				continue
+			}
+			headers[key] = append(headers[key], values...)
+		}
+		// The length of the encoded body is signed in streaming
+		if payloadHash == StreamingPayload {
+			headers["content-length"] = []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var canonicalHeaders strings.Builder
+	for _, key := range keys {
+		values := make([]string, 0, len(headers[key]))
+		for _, value := range headers[key] {
+			values = append(values, strings.Join(strings.Fields(value), " "))
+		}
+		canonicalHeaders.WriteString(key + ":" + strings.Join(values, ",") + "\n")
+	}
+	signedHeaders := strings.Join(keys, ";")
+
+	canonical := strings.Join([]string{
+		r.Method,
+		s.canonicalURI(r.URL),
+		canonicalQuery(queries),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	return canonical, signedHeaders
+}
+
+// canonicalURI returns the path normalized with the encoded segments, the path of
+// S3 is not normalized. The escaped path keeps the encoded slashes as they are sent.
+func (s *SigV4Signer) canonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = u.Opaque
+	}
+	if p == "" {
+		return "/"
+	}
+	if !s.s3() {
+		trailing := strings.HasSuffix(p, "/")
+		p = path.Clean("/" + p)
+		if trailing && p != "/" {
+			p += "/"
+		}
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = encodeTemplateValue(segment, false)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the queries sorted and encoded
+func canonicalQuery(queries url.Values) string {
+	keys := make([]string, 0, len(queries))
+	for key := range queries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(queries))
+	for _, key := range keys {
+		values := append([]string(nil), queries[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, encodeTemplateValue(key, false)+"="+encodeTemplateValue(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// stringToSign returns the string to sign of the canonical request
+func stringToSign(t time.Time, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{
+		sigV4Algorithm,
+		t.Format(sigV4TimeFormat),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+// sigV4SigningKey derives the signing key of the day, the region and the service
+func sigV4SigningKey(secret string, t time.Time, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), t.Format(sigV4DateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, sigV4Terminator)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// hashBody returns the hex SHA-256 of the body, the body is replaced
+// if it can't be read again with GetBody
+func hashBody(r *http.Request) (string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return emptySHA256, nil
+	}
+	h := sha256.New()
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	_ = r.Body.Close()
+	h.Write(payload)
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// awsChunkedLength returns the length of the body encoded in signed chunks
+func awsChunkedLength(length int64, chunkSize int) int64 {
+	chunkLength := func(size int64) int64 {
+		// hex(size);chunk-signature=<64>\r\n<data>\r\n
+		return int64(len(strconv.FormatInt(size, 16))) + 17 + 64 + 2 + size + 2
+	}
+	full := length / int64(chunkSize)
+	total := full * chunkLength(int64(chunkSize))
+	if rest := length % int64(chunkSize); rest > 0 {
+		total += chunkLength(rest)
+	}
+	return total + chunkLength(0)
+}
+
+// awsChunkedReader encodes the body in chunks signed with the signature of the previous chunk
+type awsChunkedReader struct {
+	body      io.ReadCloser
+	chunkSize int
+	key       []byte
+	date      string
+	scope     string
+	signature string
+
+	buf  bytes.Buffer
+	done bool
+}
+
+func (a *awsChunkedReader) Read(p []byte) (int, error) {
+	for a.buf.Len() == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		chunk := make([]byte, a.chunkSize)
+		n, err := io.ReadFull(a.body, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		a.writeChunk(chunk[:n])
+		if n == 0 {
+			a.done = true
+		}
+	}
+	return a.buf.Read(p)
+}
+
+// writeChunk signs and writes a chunk in the buffer
+func (a *awsChunkedReader) writeChunk(chunk []byte) {
+	hash := sha256.Sum256(chunk)
+	a.signature = hex.EncodeToString(hmacSHA256(a.key, strings.Join([]string{
+		sigV4PayloadAlgorithm,
+		a.date,
+		a.scope,
+		a.signature,
+		emptySHA256,
+		hex.EncodeToString(hash[:]),
+	}, "\n")))
+	fmt.Fprintf(&a.buf, "%x;chunk-signature=%s\r\n", len(chunk), a.signature)
+	a.buf.Write(chunk)
+	a.buf.WriteString("\r\n")
+}
+
+func (a *awsChunkedReader) Close() error {
+	return a.body.Close()
+}
+
+// WithSigV4 is a Decorator signing the requests with the AWS Signature Version 4.
+// It must be the last Decorator to sign the headers added by the other ones.
+//
+// For Example:
+//
+//	signer := &httpclient.SigV4Signer{
+//		Credentials: httpclient.DefaultAWSCredentials(),
+//		Region:      "eu-west-1",
+//		Service:     "execute-api",
+//	}
+//	client, err := httpclient.NewClient("https://abc.execute-api.eu-west-1.amazonaws.com",
+//		httpclient.WithDecorator(httpclient.WithSigV4(signer)))
+func WithSigV4(signer *SigV4Signer) Decorator {
+	return func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			signed := r.Clone(r.Context())
+			if err := signer.Sign(signed); err != nil {
+				return nil, err
+			}
+			return d.Do(signed)
+		})
+	}
+}
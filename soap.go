@@ -0,0 +1,426 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// SOAPVersion is the version of the SOAP protocol
+type SOAPVersion int
+
+const (
+	// SOAP11 is SOAP 1.1, the action is sent in the SOAPAction header
+	SOAP11 SOAPVersion = iota
+	// SOAP12 is SOAP 1.2, the action is sent in the Content-Type
+	SOAP12
+)
+
+// Namespaces of the SOAP envelopes and XOP
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+	xopNamespace    = "http://www.w3.org/2004/08/xop/include"
+)
+
+const soapActionHeaderKey = "SOAPAction"
+
+var (
+	ErrMissingSOAPBody = errors.New("httpclient: missing SOAP body in the response")
+	ErrMissingSOAP     = errors.New("httpclient: SOAP headers or attachments without WithSOAP")
+)
+
+// SOAPFault is the Fault element of a SOAP response, the fields of SOAP 1.1
+// (faultcode, faultstring, faultactor) are mapped to Code, Reason and Node
+type SOAPFault struct {
+	// HTTP status code of the response
+	StatusCode int
+	// Code of the fault like soap:Server or env:Receiver
+	Code string
+	// Subcodes of the fault for SOAP 1.2
+	Subcodes []string
+	// Reason is the human readable explanation of the fault
+	Reason string
+	// Node is the SOAP node which raised the fault
+	Node string
+	// Role is the role of the node for SOAP 1.2
+	Role string
+	// Detail is the raw content of the detail element
+	Detail []byte
+}
+
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("soap: fault %s: %s", f.Code, f.Reason)
+}
+
+// DecodeDetail decodes the content of the detail element of the fault in v,
+// the fields of v are the children of the detail element
+func (f *SOAPFault) DecodeDetail(v any) error {
+	detail := make([]byte, 0, len(f.Detail)+17)
+	detail = append(detail, "<detail>"...)
+	detail = append(detail, f.Detail...)
+	detail = append(detail, "</detail>"...)
+	return xml.Unmarshal(detail, v)
+}
+
+// SOAPAttachment is a binary attachment sent with MTOM/XOP, it is referenced
+// in the payload with a XOPInclude with the same ContentID
+type SOAPAttachment struct {
+	ContentID   string
+	ContentType string
+	io.Reader
+}
+
+// XOPInclude is the element to reference an attachment in the payload
+type XOPInclude struct {
+	XMLName xml.Name `xml:"xop:Include"`
+	XMLNS   string   `xml:"xmlns:xop,attr"`
+	Href    string   `xml:"href,attr"`
+}
+
+// NewXOPInclude returns the reference to the attachment with the content id
+func NewXOPInclude(contentID string) XOPInclude {
+	return XOPInclude{
+		XMLNS: xopNamespace,
+		Href:  "cid:" + contentID,
+	}
+}
+
+// soapConfig is the SOAP options of a request
+type soapConfig struct {
+	// The envelope is enabled by WithSOAP only
+	enabled     bool
+	version     SOAPVersion
+	action      string
+	headers     []any
+	attachments []SOAPAttachment
+}
+
+// WithSOAP is to wrap the body of the request in a SOAP envelope and to
+// unwrap the body of the response in the result. A Fault in the response
+// is returned as *SOAPFault.
+//
+// For Example:
+//
+//	var quote GetQuoteResponse
+//	_, err := client.Post(ctx, "/stock", GetQuote{Symbol: "ACME"}, &quote, nil,
+//		httpclient.WithSOAP(httpclient.SOAP11, "urn:GetQuote"))
+func WithSOAP(version SOAPVersion, action string) RequestOption {
+	return func(rc *requestConfig) {
+		if rc.soap == nil {
+			rc.soap = new(soapConfig)
+		}
+		rc.soap.enabled = true
+		rc.soap.version = version
+		rc.soap.action = action
+		rc.isXml = true
+		rc.isJson = false
+	}
+}
+
+// WithSOAPHeaders is to add header blocks in the SOAP envelope, the blocks are
+// encoded with encoding/xml. It must be used with WithSOAP.
+func WithSOAPHeaders(blocks ...any) RequestOption {
+	return func(rc *requestConfig) {
+		if rc.soap == nil {
+			rc.soap = new(soapConfig)
+		}
+		rc.soap.headers = append(rc.soap.headers, blocks...)
+	}
+}
+
+// WithSOAPAttachments is to send the envelope with binary attachments
+// following MTOM/XOP in a multipart/related body. It must be used with WithSOAP.
+func WithSOAPAttachments(attachments ...SOAPAttachment) RequestOption {
+	return func(rc *requestConfig) {
+		if rc.soap == nil {
+			rc.soap = new(soapConfig)
+		}
+		rc.soap.attachments = append(rc.soap.attachments, attachments...)
+	}
+}
+
+// namespace returns the namespace of the envelope
+func (s *soapConfig) namespace() string {
+	if s.version == SOAP12 {
+		return soap12Namespace
+	}
+	return soap11Namespace
+}
+
+// contentType returns the Content-Type of the envelope
+func (s *soapConfig) contentType() string {
+	if s.version == SOAP12 {
+		if s.action == "" {
+			return "application/soap+xml; charset=utf-8"
+		}
+		return fmt.Sprintf(`application/soap+xml; charset=utf-8; action="%s"`, escapeQuotes(s.action))
+	}
+	return "text/xml; charset=utf-8"
+}
+
+// soapEnvelope is the envelope of a request, the prefix soap is used to keep
+// the default namespace of the payload
+type soapEnvelope struct {
+	XMLName xml.Name    `xml:"soap:Envelope"`
+	XMLNS   string      `xml:"xmlns:soap,attr"`
+	Header  *soapHeader `xml:"soap:Header,omitempty"`
+	Body    soapBody    `xml:"soap:Body"`
+}
+
+type soapHeader struct {
+	Content []byte `xml:",innerxml"`
+}
+
+type soapBody struct {
+	Content []byte `xml:",innerxml"`
+}
+
+// encodeSOAP encodes the body in a SOAP envelope and returns the body and its Content-Type
+func encodeSOAP(body any, config *soapConfig) (io.Reader, string, error) {
+	envelope := soapEnvelope{XMLNS: config.namespace()}
+	if len(config.headers) > 0 {
+		envelope.Header = &soapHeader{}
+		for _, block := range config.headers {
+			content, err := xml.Marshal(block)
+			if err != nil {
+				return nil, "", err
+			}
+			envelope.Header.Content = append(envelope.Header.Content, content...)
+		}
+	}
+	switch body := body.(type) {
+	case nil:
+	case string:
+		envelope.Body.Content = []byte(body)
+	case []byte:
+		envelope.Body.Content = body
+	default:
+		content, err := xml.Marshal(body)
+		if err != nil {
+			return nil, "", err
+		}
+		envelope.Body.Content = content
+	}
+	payload, err := xml.Marshal(envelope)
+	if err != nil {
+		return nil, "", err
+	}
+	payload = append([]byte(xml.Header), payload...)
+
+	if len(config.attachments) == 0 {
+		return bytes.NewReader(payload), config.contentType(), nil
+	}
+	return encodeMTOM(payload, config)
+}
+
+// encodeMTOM encodes the envelope and the attachments in a multipart/related body
+func encodeMTOM(envelope []byte, config *soapConfig) (io.Reader, string, error) {
+	startInfo := "text/xml"
+	if config.version == SOAP12 {
+		startInfo = "application/soap+xml"
+	}
+	rootType := fmt.Sprintf(`application/xop+xml; charset=UTF-8; type="%s"`, startInfo)
+	if config.version == SOAP12 && config.action != "" {
+		rootType = fmt.Sprintf(`application/xop+xml; charset=UTF-8; type="%s; action=\"%s\""`,
+			startInfo, escapeQuotes(config.action))
+	}
+
+	// The parts of a multipart/related body have no Content-Disposition
+	buffer := &bytes.Buffer{}
+	w := multipart.NewWriter(buffer)
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		contentTypeHeaderKey: {rootType},
+		"Content-ID":         {"<root.message>"},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(envelope); err != nil {
+		return nil, "", err
+	}
+	for _, attachment := range config.attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			contentTypeHeaderKey: {contentType},
+			"Content-ID":         {"<" + attachment.ContentID + ">"},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.Copy(part, attachment.Reader); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buffer, fmt.Sprintf(`multipart/related; type="application/xop+xml"; start="<root.message>"; start-info="%s"; boundary=%s`,
+		startInfo, w.Boundary()), nil
+}
+
+// doSOAP sends the request and decodes the SOAP response in the result
+func (c *Client) doSOAP(r *http.Request, result any) (Response, error) {
+	httpresponse, body, err := c.send(r)
+	response := Response{Request: r, RawResponse: httpresponse}
+	if err != nil {
+		return response, err
+	}
+	if httpresponse.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(body)) == 0 {
+		return response, newStatusError(httpresponse, body)
+	}
+
+	envelope, err := soapRootPart(httpresponse.Header.Get(contentTypeHeaderKey), body)
+	if err != nil {
+		return response, err
+	}
+	if err := decodeSOAPEnvelope(envelope, result); err != nil {
+		var fault *SOAPFault
+		if errors.As(err, &fault) {
+			fault.StatusCode = httpresponse.StatusCode
+		} else if statusErr := newStatusError(httpresponse, body); statusErr != nil {
+			return response, statusErr
+		}
+		return response, err
+	}
+	return response, newStatusError(httpresponse, body)
+}
+
+// soapRootPart returns the envelope of a MTOM response or the body
+func soapRootPart(contentType string, body []byte) ([]byte, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return body, nil
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	var first []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		// The root part is the start part or the first one
+		if params["start"] == "" || part.Header.Get("Content-ID") == params["start"] {
+			return content, nil
+		}
+		if first == nil {
+			first = content
+		}
+	}
+	if first == nil {
+		return nil, ErrMissingSOAPBody
+	}
+	return first, nil
+}
+
+// soapFaultXML is the Fault element of SOAP 1.1 and SOAP 1.2
+type soapFaultXML struct {
+	// SOAP 1.1
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	FaultActor  string `xml:"faultactor"`
+	// SOAP 1.2
+	Code   soapFaultCode `xml:"Code"`
+	Reason struct {
+		Text []string `xml:"Text"`
+	} `xml:"Reason"`
+	Node string `xml:"Node"`
+	Role string `xml:"Role"`
+	// Both
+	Detail11 *soapDetail `xml:"detail"`
+	Detail12 *soapDetail `xml:"Detail"`
+}
+
+type soapFaultCode struct {
+	Value   string         `xml:"Value"`
+	Subcode *soapFaultCode `xml:"Subcode"`
+}
+
+type soapDetail struct {
+	Content []byte `xml:",innerxml"`
+}
+
+// fault converts the Fault element in a *SOAPFault
+func (f *soapFaultXML) fault() *SOAPFault {
+	fault := &SOAPFault{
+		Code:   strings.TrimSpace(f.FaultCode),
+		Reason: strings.TrimSpace(f.FaultString),
+		Node:   strings.TrimSpace(f.FaultActor),
+		Role:   strings.TrimSpace(f.Role),
+	}
+	if fault.Code == "" {
+		fault.Code = strings.TrimSpace(f.Code.Value)
+		for sub := f.Code.Subcode; sub != nil; sub = sub.Subcode {
+			fault.Subcodes = append(fault.Subcodes, strings.TrimSpace(sub.Value))
+		}
+	}
+	if fault.Reason == "" && len(f.Reason.Text) > 0 {
+		fault.Reason = strings.TrimSpace(f.Reason.Text[0])
+	}
+	if fault.Node == "" {
+		fault.Node = strings.TrimSpace(f.Node)
+	}
+	if f.Detail11 != nil {
+		fault.Detail = f.Detail11.Content
+	} else if f.Detail12 != nil {
+		fault.Detail = f.Detail12.Content
+	}
+	return fault
+}
+
+// decodeSOAPEnvelope decodes the first element of the Body in the result,
+// a Fault element is returned as *SOAPFault
+func decodeSOAPEnvelope(payload []byte, result any) error {
+	d := xml.NewDecoder(bytes.NewReader(payload))
+	inBody := false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return ErrMissingSOAPBody
+		}
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if !inBody {
+				inBody = token.Name.Local == "Body" &&
+					(token.Name.Space == soap11Namespace || token.Name.Space == soap12Namespace)
+				continue
+			}
+			if token.Name.Local == "Fault" {
+				var fault soapFaultXML
+				if err := d.DecodeElement(&fault, &token); err != nil {
+					return err
+				}
+				return fault.fault()
+			}
+			if result == nil {
+				return nil
+			}
+			return d.DecodeElement(result, &token)
+		case xml.EndElement:
+			// Empty body
+			if inBody {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type GetQuote struct {
+	XMLName xml.Name `xml:"urn:stock GetQuote"`
+	Symbol  string   `xml:"Symbol"`
+}
+
+type GetQuoteResponse struct {
+	XMLName xml.Name `xml:"GetQuoteResponse"`
+	Price   float64  `xml:"Price"`
+}
+
+type AuthHeader struct {
+	XMLName xml.Name `xml:"urn:auth Auth"`
+	Token   string   `xml:"Token"`
+}
+
+type UploadDocument struct {
+	XMLName xml.Name `xml:"urn:doc Upload"`
+	Content struct {
+		Include XOPInclude
+	} `xml:"Content"`
+}
+
+func TestClient_Post_WithSOAP(t *testing.T) {
+	var (
+		gotAction      string
+		gotContentType string
+		gotEnvelope    string
+		gotAttachment  string
+		gotDisposition string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAction = r.Header.Get(soapActionHeaderKey)
+		gotContentType = r.Header.Get(contentTypeHeaderKey)
+		mediaType, params, _ := mime.ParseMediaType(gotContentType)
+		if mediaType == "multipart/related" {
+			reader := multipart.NewReader(r.Body, params["boundary"])
+			root, _ := reader.NextPart()
+			envelope, _ := io.ReadAll(root)
+			gotEnvelope = string(envelope)
+			attachment, _ := reader.NextPart()
+			content, _ := io.ReadAll(attachment)
+			gotAttachment = attachment.Header.Get("Content-ID") + ":" + string(content)
+			gotDisposition = root.Header.Get("Content-Disposition") + attachment.Header.Get("Content-Disposition")
+		} else {
+			envelope, _ := io.ReadAll(r.Body)
+			gotEnvelope = string(envelope)
+		}
+
+		switch {
+		case strings.Contains(gotEnvelope, "FAIL"):
+			w.Header().Set(contentTypeHeaderKey, "application/soap+xml")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body>
+    <env:Fault>
+      <env:Code><env:Value>env:Sender</env:Value><env:Subcode><env:Value>m:UnknownSymbol</env:Value></env:Subcode></env:Code>
+      <env:Reason><env:Text xml:lang="en">Unknown symbol</env:Text></env:Reason>
+      <env:Detail><Symbol>FAIL</Symbol></env:Detail>
+    </env:Fault>
+  </env:Body>
+</env:Envelope>`))
+		case strings.Contains(gotEnvelope, "OLD"):
+			w.Header().Set(contentTypeHeaderKey, "text/xml")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body><soap:Fault><faultcode>soap:Server</faultcode><faultstring>Server error</faultstring></soap:Fault></soap:Body>
+</soap:Envelope>`))
+		default:
+			w.Header().Set(contentTypeHeaderKey, "text/xml")
+			_, _ = w.Write([]byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:stock">
+  <soap:Header><m:Trace>1</m:Trace></soap:Header>
+  <soap:Body><m:GetQuoteResponse><m:Price>34.5</m:Price></m:GetQuoteResponse></soap:Body>
+</soap:Envelope>`))
+		}
+	}))
+	defer s.Close()
+
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("ok case - SOAP 1.1", func(t *testing.T) {
+		var result GetQuoteResponse
+		_, err := c.Post(ctx, "/stock", GetQuote{Symbol: "ACME"}, &result, nil,
+			WithSOAP(SOAP11, "urn:GetQuote"),
+			WithSOAPHeaders(AuthHeader{Token: "secret"}))
+		if err != nil {
+			t.Fatalf("Client.Post() error = %v", err)
+		}
+		if result.Price != 34.5 {
+			t.Errorf("Client.Post() price = %v, want 34.5", result.Price)
+		}
+		if gotAction != `"urn:GetQuote"` || !strings.HasPrefix(gotContentType, "text/xml") {
+			t.Errorf("Client.Post() SOAPAction = %v, Content-Type = %v", gotAction, gotContentType)
+		}
+		want := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` +
+			`<soap:Header><Auth xmlns="urn:auth"><Token>secret</Token></Auth></soap:Header>` +
+			`<soap:Body><GetQuote xmlns="urn:stock"><Symbol>ACME</Symbol></GetQuote></soap:Body></soap:Envelope>`
+		if gotEnvelope != xml.Header+want {
+			t.Errorf("Client.Post() envelope = %v, want %v", gotEnvelope, want)
+		}
+	})
+
+	t.Run("nok case - SOAP 1.2 fault", func(t *testing.T) {
+		_, err := c.Post(ctx, "/stock", GetQuote{Symbol: "FAIL"}, &GetQuoteResponse{}, nil,
+			WithSOAP(SOAP12, "urn:GetQuote"))
+		var fault *SOAPFault
+		if !errors.As(err, &fault) {
+			t.Fatalf("Client.Post() error = %v, want *SOAPFault", err)
+		}
+		if fault.StatusCode != http.StatusInternalServerError || fault.Code != "env:Sender" ||
+			fault.Reason != "Unknown symbol" || len(fault.Subcodes) != 1 || fault.Subcodes[0] != "m:UnknownSymbol" {
+			t.Errorf("Client.Post() fault = %+v", fault)
+		}
+		var detail struct {
+			Symbol string `xml:"Symbol"`
+		}
+		if err := fault.DecodeDetail(&detail); err != nil || detail.Symbol != "FAIL" {
+			t.Errorf("SOAPFault.DecodeDetail() = %v, %v", detail, err)
+		}
+		if gotAction != "" || !strings.Contains(gotContentType, `action="urn:GetQuote"`) {
+			t.Errorf("Client.Post() SOAPAction = %v, Content-Type = %v", gotAction, gotContentType)
+		}
+	})
+
+	t.Run("nok case - SOAP 1.1 fault", func(t *testing.T) {
+		_, err := c.Post(ctx, "/stock", GetQuote{Symbol: "OLD"}, nil, nil, WithSOAP(SOAP11, ""))
+		var fault *SOAPFault
+		if !errors.As(err, &fault) || fault.Code != "soap:Server" || fault.Reason != "Server error" {
+			t.Fatalf("Client.Post() error = %v, want *SOAPFault", err)
+		}
+	})
+
+	t.Run("ok case - MTOM attachment", func(t *testing.T) {
+		var upload UploadDocument
+		upload.Content.Include = NewXOPInclude("doc1")
+		_, err := c.Post(ctx, "/upload", upload, nil, nil,
+			WithSOAP(SOAP11, "urn:Upload"),
+			WithSOAPAttachments(SOAPAttachment{ContentID: "doc1", Reader: strings.NewReader("binary")}))
+		if err != nil {
+			t.Fatalf("Client.Post() error = %v", err)
+		}
+		if !strings.HasPrefix(gotContentType, "multipart/related") {
+			t.Errorf("Client.Post() Content-Type = %v", gotContentType)
+		}
+		if !strings.Contains(gotEnvelope, `<xop:Include xmlns:xop="http://www.w3.org/2004/08/xop/include" href="cid:doc1">`) {
+			t.Errorf("Client.Post() envelope = %v", gotEnvelope)
+		}
+		if gotAttachment != "<doc1>:binary" || gotDisposition != "" {
+			t.Errorf("Client.Post() attachment = %v, Content-Disposition = %v", gotAttachment, gotDisposition)
+		}
+	})
+
+	t.Run("nok case - SOAP headers and attachments without WithSOAP", func(t *testing.T) {
+		for _, opt := range []RequestOption{
+			WithSOAPHeaders(AuthHeader{Token: "secret"}),
+			WithSOAPAttachments(SOAPAttachment{ContentID: "doc1", Reader: strings.NewReader("binary")}),
+		} {
+			_, err := c.Post(ctx, "/stock", GetQuote{Symbol: "ACME"}, nil, nil, opt)
+			if !errors.Is(err, ErrMissingSOAP) {
+				t.Errorf("Client.Post() error = %v, want ErrMissingSOAP", err)
+			}
+		}
+	})
+}
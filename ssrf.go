@@ -0,0 +1,195 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrSSRFBlocked = errors.New("httpclient: destination blocked by the SSRF protection")
+
+// Ranges blocked in addition to the loopback, private, link-local and multicast addresses
+var ssrfBlockedPrefixes = []netip.Prefix{
+	// This network
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Carrier-grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// Benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// Broadcast
+	netip.MustParsePrefix("255.255.255.255/32"),
+	// NAT64, 6to4 and Teredo with embedded IPv4
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001::/32"),
+}
+
+// SSRFConfig is the configuration of the protection against the Server-Side Request Forgery
+type SSRFConfig struct {
+	// AllowedSchemes of the URLs, http and https by default
+	AllowedSchemes []string
+	// AllowedPorts of the URLs, 80 and 443 by default
+	AllowedPorts []int
+	// Allow are CIDRs allowed even if they are in a blocked range
+	Allow []string
+	// Deny are CIDRs blocked in addition to the default ranges
+	Deny []string
+
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// WithSSRFProtection is to fetch URLs supplied by the users. The addresses are checked
+// when the connection is dialed, after the resolution of the host to defeat the DNS
+// rebinding, and every redirect is checked again. The proxies of the environment are
+// not used because they would dial the addresses on behalf of the client. The absolute
+// URLs are sent as they are, without the base URL of the client.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithSSRFProtection(httpclient.SSRFConfig{
+//			Allow: []string{"10.20.0.0/16"},
+//		}))
+//	// customerURL is an absolute URL like https://hooks.customer.org/events
+//	client.Get(ctx, customerURL, nil, nil)
+func WithSSRFProtection(config SSRFConfig) ClientsOption {
+	return func(cc *clientConfig) {
+		for _, cidrs := range []struct {
+			values   []string
+			prefixes *[]netip.Prefix
+		}{{config.Allow, &config.allow}, {config.Deny, &config.deny}} {
+			for _, cidr := range cidrs.values {
+				prefix, err := netip.ParsePrefix(cidr)
+				if err != nil {
+					cc.err = fmt.Errorf("httpclient: invalid CIDR %s: %w", cidr, err)
+					return
+				}
+				*cidrs.prefixes = append(*cidrs.prefixes, prefix.Masked())
+			}
+		}
+		if len(config.AllowedSchemes) == 0 {
+			config.AllowedSchemes = []string{"http", "https"}
+		}
+		if len(config.AllowedPorts) == 0 {
+			config.AllowedPorts = []int{80, 443}
+		}
+		cc.ssrf = &config
+	}
+}
+
+// applySSRF checks the addresses dialed by a copy of the transport and
+// wraps it to check the URLs of the requests and of the redirects
+func (cc *clientConfig) applySSRF() error {
+	if cc.ssrf == nil {
+		return nil
+	}
+	transport, ok := cc.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("httpclient: SSRF protection needs a *http.Transport")
+	}
+	// The addresses dialed by the TLS dial functions can't be checked
+	if transport.DialTLSContext != nil || transport.DialTLS != nil {
+		return errors.New("httpclient: SSRF protection can't check the addresses of DialTLSContext")
+	}
+	transport = transport.Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   cc.connectTimeout(),
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return cc.ssrf.checkAddress(address)
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	cc.Transport = &ssrfTransport{config: cc.ssrf, next: transport}
+	return nil
+}
+
+// checkAddress checks the resolved address dialed
+func (c *SSRFConfig) checkAddress(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: invalid address %s", ErrSSRFBlocked, address)
+	}
+	if !c.portAllowed(int(addrPort.Port())) {
+		return fmt.Errorf("%w: port %d", ErrSSRFBlocked, addrPort.Port())
+	}
+	if !c.ipAllowed(addrPort.Addr().Unmap()) {
+		return fmt.Errorf("%w: address %s", ErrSSRFBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+func (c *SSRFConfig) portAllowed(port int) bool {
+	for _, allowed := range c.AllowedPorts {
+		if port == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *SSRFConfig) ipAllowed(ip netip.Addr) bool {
+	for _, prefix := range c.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	for _, prefix := range c.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range ssrfBlockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ssrfTransport checks the scheme and the port of the requests before the dial
+type ssrfTransport struct {
+	config *SSRFConfig
+	next   http.RoundTripper
+}
+
+func (t *ssrfTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	scheme := strings.ToLower(r.URL.Scheme)
+	if !containsFold(t.config.AllowedSchemes, scheme) {
+		return nil, fmt.Errorf("%w: scheme %s", ErrSSRFBlocked, scheme)
+	}
+	port := r.URL.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	if p, err := strconv.Atoi(port); err != nil || !t.config.portAllowed(p) {
+		return nil, fmt.Errorf("%w: port %s", ErrSSRFBlocked, port)
+	}
+	// The literal addresses are rejected before the dial
+	if ip, err := netip.ParseAddr(strings.Trim(r.URL.Hostname(), "[]")); err == nil && !t.config.ipAllowed(ip.Unmap()) {
+		return nil, fmt.Errorf("%w: address %s", ErrSSRFBlocked, ip)
+	}
+	return t.next.RoundTrip(r)
+}
+
+// CloseIdleConnections closes the idle connections of the transport
+func (t *ssrfTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestSSRFConfig_ipAllowed(t *testing.T) {
+	cc := newclientConfig()
+	WithSSRFProtection(SSRFConfig{Allow: []string{"10.20.0.0/16"}, Deny: []string{"8.8.8.0/24"}})(cc)
+	if cc.err != nil {
+		t.Fatal(cc.err)
+	}
+	tests := map[string]bool{
+		"127.0.0.1":        false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00:ec2::254":    false,
+		"224.0.0.1":        false,
+		"0.0.0.0":          false,
+		"100.64.1.1":       false,
+		// 6to4 of 127.0.0.1 and 10.0.0.1, Teredo of 192.168.0.1
+		"2002:7f00:1::1":                       false,
+		"2002:a00:1::1":                        false,
+		"2001:0:4136:e378:8000:63bf:3f57:fffe": false,
+		"8.8.8.8":                              false,
+		"10.20.1.1":                            true,
+		"1.1.1.1":                              true,
+		"2606:4700::1111":                      true,
+	}
+	for address, want := range tests {
+		if got := cc.ssrf.ipAllowed(netip.MustParseAddr(address).Unmap()); got != want {
+			t.Errorf("SSRFConfig.ipAllowed(%s) = %v, want %v", address, got, want)
+		}
+	}
+
+	if _, err := NewClient("http://localhost", WithSSRFProtection(SSRFConfig{Deny: []string{"invalid"}})); err == nil {
+		t.Errorf("WithSSRFProtection() with invalid CIDR error = nil")
+	}
+	// The dial of the TLS connections would bypass the check of the addresses
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return tls.Dial(network, addr, nil)
+	}
+	if _, err := NewClient("https://localhost", WithTransport(transport), WithSSRFProtection(SSRFConfig{})); err == nil {
+		t.Errorf("WithSSRFProtection() with DialTLSContext error = nil")
+	}
+}
+
+func TestWithSSRFProtection(t *testing.T) {
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer blocked.Close()
+	blockedURL, _ := url.Parse(blocked.URL)
+	blockedPort, _ := strconv.Atoi(blockedURL.Port())
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost:"+blockedURL.Port()+"/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+	sURL, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(sURL.Port())
+
+	tests := []struct {
+		name    string
+		baseURL string
+		path    string
+		config  SSRFConfig
+		wantErr bool
+	}{
+		{
+			name:    "nok case - literal loopback",
+			baseURL: s.URL,
+			config:  SSRFConfig{AllowedPorts: []int{port}},
+			wantErr: true,
+		},
+		{
+			name:    "nok case - loopback resolved at dial time",
+			baseURL: "http://localhost:" + sURL.Port(),
+			config:  SSRFConfig{AllowedPorts: []int{port}},
+			wantErr: true,
+		},
+		{
+			name:    "nok case - port not allowed",
+			baseURL: s.URL,
+			config:  SSRFConfig{Allow: []string{"127.0.0.0/8", "::1/128"}},
+			wantErr: true,
+		},
+		{
+			name:    "nok case - scheme not allowed",
+			baseURL: "ftp://localhost:" + sURL.Port(),
+			config:  SSRFConfig{AllowedPorts: []int{port}, Allow: []string{"127.0.0.0/8", "::1/128"}},
+			wantErr: true,
+		},
+		{
+			name:    "ok case - allowed CIDR",
+			baseURL: "http://localhost:" + sURL.Port(),
+			config:  SSRFConfig{AllowedPorts: []int{port}, Allow: []string{"127.0.0.0/8", "::1/128"}},
+		},
+		{
+			name:    "ok case - absolute URL of a user",
+			baseURL: "https://api.example.com",
+			path:    "http://localhost:" + sURL.Port() + "/hook",
+			config:  SSRFConfig{AllowedPorts: []int{port}, Allow: []string{"127.0.0.0/8", "::1/128"}},
+		},
+		{
+			name:    "nok case - absolute URL of a user to a blocked address",
+			baseURL: "https://api.example.com",
+			path:    "http://localhost:" + blockedURL.Port() + "/hook",
+			config:  SSRFConfig{AllowedPorts: []int{blockedPort}},
+			wantErr: true,
+		},
+		{
+			name:    "nok case - redirect to a port not allowed",
+			baseURL: s.URL,
+			path:    "/redirect",
+			config:  SSRFConfig{AllowedPorts: []int{port}, Allow: []string{"127.0.0.0/8", "::1/128"}},
+			wantErr: true,
+		},
+		{
+			name:    "ok case - redirect allowed",
+			baseURL: s.URL,
+			path:    "/redirect",
+			config:  SSRFConfig{AllowedPorts: []int{port, blockedPort}, Allow: []string{"127.0.0.0/8", "::1/128"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(tt.baseURL, WithSSRFProtection(tt.config))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			_, err = c.Get(context.Background(), tt.path, nil, nil)
+			if (err == nil) == tt.wantErr || (tt.wantErr && !errors.Is(err, ErrSSRFBlocked)) {
+				t.Errorf("Client.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
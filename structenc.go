@@ -0,0 +1,327 @@
+package httpclient
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidStruct = errors.New("httpclient: value must be a struct or a pointer to a struct")
+
+// Tags used to encode a struct in the request
+const (
+	queryTag  = "url"
+	headerTag = "header"
+	pathTag   = "path"
+	layoutTag = "layout"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// tagOptions is the options after the name in a tag like `url:"name,omitempty,comma"`
+type tagOptions []string
+
+func (o tagOptions) has(option string) bool {
+	for _, opt := range o {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// WithQueryStruct is to encode the fields of a struct with the `url` tag as queries.
+//
+// The tag is `url:"name,options"`, the options are:
+//   - omitempty to skip the zero value and the nil pointer
+//   - comma, space or brackets for the slices, by default the key is repeated
+//     (ids=1&ids=2), with comma (ids=1,2), space (ids=1 2) or brackets (ids[]=1&ids[]=2)
+//   - unix or unixmilli for a time.Time, else the layout tag is used
+//     (`layout:"2006-01-02"`) or time.RFC3339 by default
+//   - int for a boolean encoded as 0 or 1
+//
+// The embedded structs are flattened and the nested structs are named with
+// brackets like user[name]. The types implementing [encoding.TextMarshaler] are
+// encoded with the MarshalText method.
+//
+// For Example:
+//
+//	type ListOptions struct {
+//		Page  int       `url:"page,omitempty"`
+//		IDs   []string  `url:"id,comma"`
+//		Since time.Time `url:"since,omitempty" layout:"2006-01-02"`
+//	}
+//	client.Get(ctx, "/items", &items, nil, httpclient.WithQueryStruct(ListOptions{Page: 2}))
+func WithQueryStruct(v any) RequestOption {
+	return func(rc *requestConfig) {
+		values, err := encodeStruct(v, queryTag)
+		if err != nil {
+			rc.err = err
+			return
+		}
+		if rc.queryValues == nil {
+			rc.queryValues = url.Values{}
+		}
+		for key, vs := range values {
+			rc.queryValues[key] = append(rc.queryValues[key], vs...)
+		}
+	}
+}
+
+// WithHeaderStruct is to encode the fields of a struct with the `header` tag
+// as headers like `header:"X-Request-Id,omitempty"`.
+// The options are the same as [WithQueryStruct].
+func WithHeaderStruct(v any) RequestOption {
+	return func(rc *requestConfig) {
+		values, err := encodeStruct(v, headerTag)
+		if err != nil {
+			rc.err = err
+			return
+		}
+		if rc.headers == nil {
+			rc.headers = http.Header{}
+		}
+		for key, vs := range values {
+			for _, value := range vs {
+				rc.headers.Add(key, value)
+			}
+		}
+	}
+}
+
+// WithPathStruct is to encode the fields of a struct with the `path` tag as
+// params of the URI template like `path:"id"`. The slices are lists of the template.
+// The options are the same as [WithQueryStruct].
+func WithPathStruct(v any) RequestOption {
+	return func(rc *requestConfig) {
+		values, err := encodeStruct(v, pathTag)
+		if err != nil {
+			rc.err = err
+			return
+		}
+		if rc.pathParams == nil {
+			rc.pathParams = make(map[string]any, len(values))
+		}
+		for key, vs := range values {
+			if len(vs) == 1 {
+				rc.pathParams[key] = vs[0]
+			} else {
+				rc.pathParams[key] = vs
+			}
+		}
+	}
+}
+
+// encodeStruct encodes the fields of the struct with the tag
+func encodeStruct(v any, tag string) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w, got %T", ErrInvalidStruct, v)
+	}
+	values := url.Values{}
+	return values, encodeStructFields(values, rv, tag, "")
+}
+
+func encodeStructFields(values url.Values, rv reflect.Value, tag string, scope string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		name, opts, tagged := parseStructTag(field, tag)
+		if name == "-" {
+			continue
+		}
+
+		// Flatten the embedded structs without name
+		if field.Anonymous && !tagged {
+			for value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					break
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct && !isScalarStruct(value.Type()) {
+				if err := encodeStructFields(values, value, tag, scope); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !tagged || !field.IsExported() {
+			continue
+		}
+		if scope != "" {
+			name = scope + "[" + name + "]"
+		}
+
+		if opts.has("omitempty") && isEmptyValue(value) {
+			continue
+		}
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				break
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Pointer {
+			values.Add(name, "")
+			continue
+		}
+
+		// Nested struct
+		if value.Kind() == reflect.Struct && !isScalarStruct(value.Type()) {
+			if err := encodeStructFields(values, value, tag, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Slices and arrays, []byte is kept as a string
+		if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) &&
+			value.Type().Elem().Kind() != reflect.Uint8 &&
+			!value.Type().Implements(textMarshalerType) {
+			items := make([]string, 0, value.Len())
+			for j := 0; j < value.Len(); j++ {
+				item, err := formatValue(value.Index(j), opts, field.Tag.Get(layoutTag))
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+			}
+			switch {
+			case opts.has("comma"):
+				values.Add(name, strings.Join(items, ","))
+			case opts.has("space"):
+				values.Add(name, strings.Join(items, " "))
+			case opts.has("brackets"):
+				values[name+"[]"] = append(values[name+"[]"], items...)
+			default:
+				values[name] = append(values[name], items...)
+			}
+			continue
+		}
+
+		s, err := formatValue(value, opts, field.Tag.Get(layoutTag))
+		if err != nil {
+			return err
+		}
+		values.Add(name, s)
+	}
+	return nil
+}
+
+// parseStructTag returns the name, the options of the tag and if the field has the tag
+func parseStructTag(field reflect.StructField, tag string) (string, tagOptions, bool) {
+	value, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return field.Name, nil, false
+	}
+	parts := strings.Split(value, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, tagOptions(parts[1:]), true
+}
+
+// isScalarStruct returns true if the struct is encoded as a single value
+func isScalarStruct(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// isEmptyValue returns true for the zero values, the nil pointers and the empty slices
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return v.IsZero()
+}
+
+// formatValue formats a scalar value
+func formatValue(v reflect.Value, opts tagOptions, layout string) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		switch {
+		case opts.has("unix"):
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case opts.has("unixmilli"):
+			return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10), nil
+		case layout != "":
+			return t.Format(layout), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	if m, ok := textMarshaler(v); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if opts.has("int") {
+			if v.Bool() {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		// []byte
+		return string(v.Bytes()), nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// textMarshaler returns the encoding.TextMarshaler of the value or its pointer
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return ptr.Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
@@ -0,0 +1,193 @@
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Pagination struct {
+	Page    int `url:"page,omitempty"`
+	PerPage int `url:"per_page,omitempty"`
+}
+
+type Filters struct {
+	Pagination
+	Query   string     `url:"q"`
+	IDs     []int      `url:"id"`
+	Tags    []string   `url:"tags,comma"`
+	Labels  []string   `url:"labels,brackets"`
+	Words   []string   `url:"words,space,omitempty"`
+	Since   time.Time  `url:"since" layout:"2006-01-02"`
+	Until   time.Time  `url:"until,unix"`
+	Before  *time.Time `url:"before,omitempty"`
+	Deleted bool       `url:"deleted,int"`
+	Limit   *int       `url:"limit"`
+	IP      net.IP     `url:"ip,omitempty"`
+	Owner   struct {
+		Name string `url:"name"`
+	} `url:"owner"`
+	Ignored string `url:"-"`
+	NoTag   string
+
+	RequestID string   `header:"X-Request-Id"`
+	Accepts   []string `header:"Accept"`
+	ID        string   `path:"id"`
+	Segments  []string `path:"segments"`
+}
+
+func TestEncodeStruct(t *testing.T) {
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	filters := Filters{
+		Pagination: Pagination{Page: 2},
+		Query:      "go lang",
+		IDs:        []int{1, 2},
+		Tags:       []string{"a", "b"},
+		Labels:     []string{"x", "y"},
+		Since:      date,
+		Until:      date,
+		Deleted:    true,
+		IP:         net.ParseIP("10.0.0.1"),
+		Ignored:    "ignored",
+		NoTag:      "ignored",
+		RequestID:  "abc",
+		Accepts:    []string{"application/json", "text/plain"},
+		ID:         "42",
+		Segments:   []string{"a", "b"},
+	}
+	filters.Owner.Name = "john"
+
+	tests := []struct {
+		name    string
+		v       any
+		tag     string
+		want    url.Values
+		wantErr bool
+	}{
+		{
+			name:    "nok case - not a struct",
+			v:       "string",
+			tag:     queryTag,
+			wantErr: true,
+		},
+		{
+			name: "ok case - nil pointer",
+			v:    (*Filters)(nil),
+			tag:  queryTag,
+			want: url.Values{},
+		},
+		{
+			name: "ok case - queries",
+			v:    &filters,
+			tag:  queryTag,
+			want: url.Values{
+				"page":        {"2"},
+				"q":           {"go lang"},
+				"id":          {"1", "2"},
+				"tags":        {"a,b"},
+				"labels[]":    {"x", "y"},
+				"since":       {"2025-01-02"},
+				"until":       {"1735787045"},
+				"deleted":     {"1"},
+				"limit":       {""},
+				"ip":          {"10.0.0.1"},
+				"owner[name]": {"john"},
+			},
+		},
+		{
+			name: "ok case - headers",
+			v:    filters,
+			tag:  headerTag,
+			want: url.Values{
+				"X-Request-Id": {"abc"},
+				"Accept":       {"application/json", "text/plain"},
+			},
+		},
+		{
+			name: "ok case - path",
+			v:    filters,
+			tag:  pathTag,
+			want: url.Values{
+				"id":       {"42"},
+				"segments": {"a", "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeStruct(tt.v, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeStruct() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidStruct) {
+				t.Errorf("encodeStruct() error = %v, want ErrInvalidStruct", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeStruct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_NewRequest_WithStruct(t *testing.T) {
+	params := struct {
+		Org   string   `path:"org"`
+		Type  string   `url:"type"`
+		IDs   []string `url:"id"`
+		Trace string   `header:"X-Trace"`
+	}{
+		Org:   "my org",
+		Type:  "public",
+		IDs:   []string{"1", "2"},
+		Trace: "t1",
+	}
+	c := &Client{baseURL: "http://example.com"}
+	r, err := c.NewRequest("/orgs/{org}/repos", http.MethodGet, nil,
+		WithPathStruct(params),
+		WithQueryStruct(params),
+		WithHeaderStruct(params))
+	if err != nil {
+		t.Fatalf("Client.NewRequest() error = %v", err)
+	}
+	if want := "http://example.com/orgs/my%20org/repos?id=1&id=2&type=public"; r.URL.String() != want {
+		t.Errorf("Client.NewRequest() url = %v, want %v", r.URL.String(), want)
+	}
+	if got := r.Header.Get("X-Trace"); got != "t1" {
+		t.Errorf("Client.NewRequest() header = %v, want t1", got)
+	}
+
+	// The maps of the caller are shared by the requests and not modified
+	headers := http.Header{"X-Tenant": {"a"}}
+	pathParams := map[string]any{"org": "acme"}
+	for i := 0; i < 2; i++ {
+		r, err = c.NewRequest("/orgs/{org}/repos/{repo}", http.MethodGet, nil,
+			WithHeaders(headers),
+			WithPathParams(pathParams),
+			WithHeaderStruct(params),
+			WithPathStruct(struct {
+				Repo string `path:"repo"`
+			}{Repo: "r1"}))
+		if err != nil {
+			t.Fatalf("Client.NewRequest() error = %v", err)
+		}
+		if got := r.Header.Values("X-Trace"); len(got) != 1 {
+			t.Errorf("Client.NewRequest() header = %v, want [t1]", got)
+		}
+		if want := "http://example.com/orgs/acme/repos/r1"; r.URL.String() != want {
+			t.Errorf("Client.NewRequest() url = %v, want %v", r.URL.String(), want)
+		}
+	}
+	if len(headers) != 1 || len(headers["X-Tenant"]) != 1 || len(pathParams) != 1 {
+		t.Errorf("caller maps modified, headers = %v, params = %v", headers, pathParams)
+	}
+
+	_, err = c.NewRequest("/orgs", http.MethodGet, nil, WithQueryStruct(42))
+	if !errors.Is(err, ErrInvalidStruct) {
+		t.Errorf("Client.NewRequest() error = %v, want ErrInvalidStruct", err)
+	}
+}
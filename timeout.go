@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBodyReadTimeout   = errors.New("httpclient: body read timeout")
+	ErrTimeoutsTransport = errors.New("httpclient: timeouts of the connections need a *http.Transport")
+)
+
+// Timeouts are the timeouts of the connections and of the attempts of the requests
+type Timeouts struct {
+	// Connect is the timeout of the TCP connection, the DialContext of a custom
+	// transport is kept and called with this timeout
+	Connect time.Duration
+	// TLSHandshake is the timeout of the TLS handshake
+	TLSHandshake time.Duration
+	// ResponseHeader is the timeout waiting for the headers of the response
+	// once the request is written
+	ResponseHeader time.Duration
+	// IdleConnection is the time an idle connection is kept in the pool
+	IdleConnection time.Duration
+	// BodyRead is the maximum time without data while reading the body of the response
+	BodyRead time.Duration
+	// Attempt is the timeout of every attempt of a request, like the retries
+	// of the decorators. An attempt never exceeds the deadline of the request.
+	Attempt time.Duration
+	// Header is sent with the remaining time of the attempt in milliseconds,
+	// like Request-Timeout, when the request has a deadline
+	Header string
+}
+
+// timeoutContextKey is the key to store the timeout of the request in the context
+type timeoutContextKey struct{}
+
+// WithDefaultTimeout is the timeout of the requests of the client, including the
+// read of the body of the response. It's overridden by WithTimeout.
+func WithDefaultTimeout(timeout time.Duration) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.timeout = timeout
+	}
+}
+
+// WithTimeouts is to set the timeouts of the connections and of the attempts
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://api.example.com",
+//		httpclient.WithDefaultTimeout(10*time.Second),
+//		httpclient.WithTimeouts(httpclient.Timeouts{
+//			Connect:        time.Second,
+//			ResponseHeader: 2 * time.Second,
+//			Attempt:        3 * time.Second,
+//			Header:         "Request-Timeout",
+//		}))
+func WithTimeouts(timeouts Timeouts) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.timeouts = &timeouts
+	}
+}
+
+// WithTimeout is the timeout of the request, there is no timeout when it's zero
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(rc *requestConfig) {
+		rc.timeout = &timeout
+	}
+}
+
+// withTimeout returns the request with the deadline of its timeout,
+// the one of the client when the request has none
+func (c *Client) withTimeout(r *http.Request) (*http.Request, context.CancelFunc) {
+	timeout := c.timeout
+	if t, ok := r.Context().Value(timeoutContextKey{}).(time.Duration); ok {
+		timeout = t
+	}
+	if timeout <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+// applyTimeouts sets the timeouts of the connections on the *http.Transport
+func (cc *clientConfig) applyTimeouts() error {
+	t := cc.timeouts
+	if t == nil || (t.Connect == 0 && t.TLSHandshake == 0 && t.ResponseHeader == 0 && t.IdleConnection == 0) {
+		return nil
+	}
+	transport, ok := cc.Transport.(*http.Transport)
+	if !ok {
+		return ErrTimeoutsTransport
+	}
+	transport = transport.Clone()
+	if t.Connect > 0 {
+		// The dialer of a custom transport, like a unix socket or a proxy, is kept
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+		}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, t.Connect)
+			defer cancel()
+			return dial(ctx, network, address)
+		}
+	}
+	if t.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = t.TLSHandshake
+	}
+	if t.ResponseHeader > 0 {
+		transport.ResponseHeaderTimeout = t.ResponseHeader
+	}
+	if t.IdleConnection > 0 {
+		transport.IdleConnTimeout = t.IdleConnection
+	}
+	cc.Transport = transport
+	return nil
+}
+
+// connectTimeout returns the timeout of the TCP connection
+func (cc *clientConfig) connectTimeout() time.Duration {
+	if cc.timeouts != nil && cc.timeouts.Connect > 0 {
+		return cc.timeouts.Connect
+	}
+	return 30 * time.Second
+}
+
+// applyAttemptTimeouts wraps the transport to limit every attempt
+func (cc *clientConfig) applyAttemptTimeouts() {
+	t := cc.timeouts
+	if t == nil || (t.Attempt == 0 && t.BodyRead == 0 && t.Header == "") {
+		return
+	}
+	cc.Transport = &timeoutTransport{timeouts: *t, next: cc.Transport}
+}
+
+// timeoutTransport limits the attempts and sends the remaining time to the server
+type timeoutTransport struct {
+	timeouts Timeouts
+	next     http.RoundTripper
+}
+
+func (t *timeoutTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, cancel := r.Context(), context.CancelFunc(func() {})
+	if t.timeouts.Attempt > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeouts.Attempt)
+	} else if t.timeouts.BodyRead > 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// The budget of the request is exhausted by the previous attempts
+	if err := ctx.Err(); err != nil {
+		cancel()
+		return nil, err
+	}
+	attempt := r.WithContext(ctx)
+	if deadline, ok := ctx.Deadline(); ok && t.timeouts.Header != "" {
+		attempt = r.Clone(ctx)
+		attempt.Header.Set(t.timeouts.Header, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
+	resp, err := t.next.RoundTrip(attempt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if t.timeouts.BodyRead > 0 {
+		resp.Body = newIdleTimeoutBody(resp.Body, t.timeouts.BodyRead, cancel)
+	} else {
+		// The attempt is over when the body is closed
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the transport
+func (t *timeoutTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// idleTimeoutBody cancels the attempt when no data is read for a while
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout, cancel: cancel}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.mu.Lock()
+		b.expired = true
+		b.mu.Unlock()
+		cancel()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.expired && err != nil && err != io.EOF {
+		return n, fmt.Errorf("%w: no data for %s", ErrBodyReadTimeout, b.timeout)
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Timeouts(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		case "/stalled":
+			w.Header().Set(contentTypeHeaderKey, "application/json")
+			_, _ = w.Write([]byte(`{"items": [`))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+			_, _ = w.Write([]byte(`]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		opts    []ClientsOption
+		path    string
+		reqOpts []RequestOption
+		wantErr error
+	}{
+		{
+			name:    "nok case - default timeout",
+			opts:    []ClientsOption{WithDefaultTimeout(50 * time.Millisecond)},
+			path:    "/slow",
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "ok case - request timeout overrides the default",
+			opts:    []ClientsOption{WithDefaultTimeout(50 * time.Millisecond)},
+			path:    "/slow",
+			reqOpts: []RequestOption{WithTimeout(time.Second)},
+		},
+		{
+			name:    "ok case - request without timeout",
+			opts:    []ClientsOption{WithDefaultTimeout(50 * time.Millisecond)},
+			path:    "/slow",
+			reqOpts: []RequestOption{WithTimeout(0)},
+		},
+		{
+			name:    "nok case - request timeout",
+			path:    "/slow",
+			reqOpts: []RequestOption{WithTimeout(50 * time.Millisecond)},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "nok case - response header timeout",
+			opts:    []ClientsOption{WithTimeouts(Timeouts{ResponseHeader: 50 * time.Millisecond})},
+			path:    "/slow",
+			wantErr: errTimeout,
+		},
+		{
+			name:    "nok case - body read timeout",
+			opts:    []ClientsOption{WithTimeouts(Timeouts{BodyRead: 50 * time.Millisecond})},
+			path:    "/stalled",
+			wantErr: ErrBodyReadTimeout,
+		},
+		{
+			name: "ok case - body read timeout not reached",
+			opts: []ClientsOption{WithTimeouts(Timeouts{BodyRead: time.Second})},
+			path: "/stalled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(s.URL, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			var result map[string]any
+			_, err = c.Get(context.Background(), tt.path, &result, nil, tt.reqOpts...)
+			if tt.wantErr == errTimeout {
+				if netErr, ok := err.(interface{ Timeout() bool }); !ok || !netErr.Timeout() {
+					t.Errorf("Client.Get() error = %v, want a timeout", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Client.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewClient(s.URL, WithTransport(http.NewFileTransport(http.Dir("."))),
+		WithTimeouts(Timeouts{Connect: time.Second})); !errors.Is(err, ErrTimeoutsTransport) {
+		t.Errorf("NewClient() without *http.Transport error = %v, want %v", err, ErrTimeoutsTransport)
+	}
+
+	// The dialer of the custom transport is kept with the connect timeout
+	var deadline time.Duration
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if d, ok := ctx.Deadline(); ok {
+			deadline = time.Until(d)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	c, err := NewClient(s.URL, WithTransport(transport), WithTimeouts(Timeouts{Connect: time.Second}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.Get(context.Background(), "/", nil, nil); err != nil {
+		t.Fatalf("Client.Get() error = %v", err)
+	}
+	if deadline <= 0 || deadline > time.Second {
+		t.Errorf("custom dialer deadline = %v, want at most %v", deadline, time.Second)
+	}
+}
+
+// errTimeout is the expected error of the net timeouts
+var errTimeout = errors.New("timeout")
+
+func TestClient_AttemptTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		budgets []int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget, _ := strconv.Atoi(r.Header.Get("Request-Timeout"))
+		mu.Lock()
+		budgets = append(budgets, budget)
+		mu.Unlock()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	// retry sends the request again after an error, as long as the context allows
+	retry := func(d Doer) Doer {
+		return DoerFunc(func(r *http.Request) (*http.Response, error) {
+			for {
+				resp, err := d.Do(r)
+				if err == nil || r.Context().Err() != nil {
+					return resp, err
+				}
+			}
+		})
+	}
+	c, err := NewClient(s.URL,
+		WithDecorator(retry),
+		WithTimeouts(Timeouts{Attempt: 60 * time.Millisecond, Header: "Request-Timeout"}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	start := time.Now()
+	_, err = c.Get(context.Background(), "/", nil, nil, WithTimeout(100*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Client.Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Client.Get() took %v, the deadline of the request is exceeded", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(budgets) != 2 {
+		t.Fatalf("attempts = %v, want 2", budgets)
+	}
+	// The first attempt has its own timeout, the second the remaining time of the request
+	if budgets[0] > 60 || budgets[0] < 40 {
+		t.Errorf("first Request-Timeout = %v, want about 60", budgets[0])
+	}
+	if budgets[1] > 45 {
+		t.Errorf("second Request-Timeout = %v, want the remaining 40", budgets[1])
+	}
+}
@@ -0,0 +1,202 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrTLSTransport = errors.New("httpclient: TLS options need a *http.Transport")
+
+// withTLS adds a change of the TLS configuration, the changes are applied
+// on the configuration of the transport when the Client is created
+func (cc *clientConfig) withTLS(set func(config *tls.Config)) {
+	cc.tls = append(cc.tls, set)
+}
+
+// applyTLS sets the TLS configuration of the options on a copy of the transport,
+// the configuration of the transport is kept for the fields without option
+func (cc *clientConfig) applyTLS() error {
+	if len(cc.tls) == 0 {
+		return nil
+	}
+	transport, ok := cc.Transport.(*http.Transport)
+	if !ok {
+		return ErrTLSTransport
+	}
+	transport = transport.Clone()
+	config := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		config = transport.TLSClientConfig.Clone()
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	for _, set := range cc.tls {
+		set(config)
+	}
+	transport.TLSClientConfig = config
+	cc.Transport = transport
+	return nil
+}
+
+// WithClientCertificate is to authenticate the client with the certificate and the key
+// of PEM files. The files are reloaded on the next TLS handshake when they change on disk,
+// the rotated certificate is used by the new connections without recreating the Client.
+//
+// For Example:
+//
+//	client, err := httpclient.NewClient("https://internal.mesh",
+//		httpclient.WithClientCertificate("/etc/certs/tls.crt", "/etc/certs/tls.key"),
+//		httpclient.WithRootCAFile("/etc/certs/ca.crt"))
+func WithClientCertificate(certFile, keyFile string) ClientsOption {
+	return func(cc *clientConfig) {
+		reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+		if _, err := reloader.load(); err != nil {
+			cc.err = err
+			return
+		}
+		cc.withTLS(func(config *tls.Config) {
+			config.GetClientCertificate = reloader.GetClientCertificate
+		})
+	}
+}
+
+// WithClientCertificatePEM is to authenticate the client with a PEM encoded certificate and key
+func WithClientCertificatePEM(certPEM, keyPEM []byte) ClientsOption {
+	return func(cc *clientConfig) {
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			cc.err = fmt.Errorf("httpclient: invalid client certificate: %w", err)
+			return
+		}
+		cc.withTLS(func(config *tls.Config) {
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &certificate, nil
+			}
+		})
+	}
+}
+
+// WithRootCAs is to verify the servers with the pool instead of the system pool
+func WithRootCAs(pool *x509.CertPool) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.withTLS(func(config *tls.Config) {
+			config.RootCAs = pool
+		})
+	}
+}
+
+// WithRootCAFile is to verify the servers with the PEM certificates of the file
+// instead of the system pool, they are added to the pool of the transport if any
+func WithRootCAFile(caFile string) ClientsOption {
+	return func(cc *clientConfig) {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			cc.err = err
+			return
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(content) {
+			cc.err = fmt.Errorf("httpclient: no certificate found in %s", caFile)
+			return
+		}
+		cc.withTLS(func(config *tls.Config) {
+			// The pool of the transport may be shared
+			if config.RootCAs == nil {
+				config.RootCAs = x509.NewCertPool()
+			} else {
+				config.RootCAs = config.RootCAs.Clone()
+			}
+			config.RootCAs.AppendCertsFromPEM(content)
+		})
+	}
+}
+
+// WithMinTLSVersion is to set the minimum TLS version, TLS 1.2 by default
+func WithMinTLSVersion(version uint16) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.withTLS(func(config *tls.Config) {
+			config.MinVersion = version
+		})
+	}
+}
+
+// WithCipherSuites is to set the cipher suites of TLS 1.0 to 1.2,
+// the cipher suites of TLS 1.3 are not configurable
+func WithCipherSuites(suites ...uint16) ClientsOption {
+	return func(cc *clientConfig) {
+		cc.withTLS(func(config *tls.Config) {
+			config.CipherSuites = suites
+		})
+	}
+}
+
+// certificateReloader reloads the client certificate when its files change
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// GetClientCertificate returns the certificate, reloaded if the files changed.
+// The previous certificate is kept when the new files are invalid, like during
+// the write of the certificate before the key.
+func (c *certificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	certificate := c.certificate
+	changed := c.changed()
+	c.mu.Unlock()
+	if !changed {
+		return certificate, nil
+	}
+	reloaded, err := c.load()
+	if err != nil {
+		return certificate, nil
+	}
+	return reloaded, nil
+}
+
+// changed returns true if a file changed since the last load, the lock must be held
+func (c *certificateReloader) changed() bool {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(c.certModTime) || !keyInfo.ModTime().Equal(c.keyModTime)
+}
+
+// load reads the certificate and the key
+func (c *certificateReloader) load() (*tls.Certificate, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid client certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.certificate = &certificate
+	c.certModTime = certInfo.ModTime()
+	c.keyModTime = keyInfo.ModTime()
+	return &certificate, nil
+}
@@ -0,0 +1,194 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a PEM certificate and key signed by the parent, self signed without parent
+func testCertificate(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, []byte, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		certificate, key
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	_, _, ca, caKey := testCertificate(t, "ca", nil, nil)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"cn": r.TLS.PeerCertificates[0].Subject.CommonName})
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	s.StartTLS()
+	defer s.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(s.Certificate())
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate := func(cn string, modTime time.Time) {
+		certPEM, keyPEM, _, _ := testCertificate(t, cn, ca, caKey)
+		for file, content := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+			if err := os.WriteFile(file, content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeCertificate("client-1", time.Now().Add(-time.Minute))
+
+	c, err := NewClient(s.URL,
+		WithClientCertificate(certFile, keyFile),
+		WithRootCAs(rootCAs),
+		WithMinTLSVersion(tls.VersionTLS13))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	ctx := context.Background()
+	var result map[string]string
+	if _, err := c.Get(ctx, "/", &result, nil); err != nil || result["cn"] != "client-1" {
+		t.Fatalf("Client.Get() = %v, %v", result, err)
+	}
+
+	// The rotated certificate is used by the new connections
+	writeCertificate("client-2", time.Now())
+	c.httpClient.CloseIdleConnections()
+	if _, err := c.Get(ctx, "/", &result, nil); err != nil || result["cn"] != "client-2" {
+		t.Errorf("Client.Get() after rotation = %v, %v", result, err)
+	}
+}
+
+func TestNewClient_TLSOptions(t *testing.T) {
+	certPEM, keyPEM, _, _ := testCertificate(t, "client", nil, nil)
+	tests := []struct {
+		name    string
+		opts    []ClientsOption
+		wantErr error
+	}{
+		{
+			name: "ok case - PEM certificate and cipher suites",
+			opts: []ClientsOption{
+				WithClientCertificatePEM(certPEM, keyPEM),
+				WithCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256),
+			},
+		},
+		{
+			name:    "nok case - invalid PEM certificate",
+			opts:    []ClientsOption{WithClientCertificatePEM(certPEM, certPEM)},
+			wantErr: errors.New("httpclient: invalid client certificate"),
+		},
+		{
+			name:    "nok case - missing files",
+			opts:    []ClientsOption{WithClientCertificate("missing.crt", "missing.key")},
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name:    "nok case - custom RoundTripper",
+			opts:    []ClientsOption{WithTransport(http.NewFileTransport(http.Dir("."))), WithMinTLSVersion(tls.VersionTLS13)},
+			wantErr: ErrTLSTransport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient("https://localhost", tt.opts...)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("NewClient() error = %v", err)
+				}
+				transport := c.httpClient.Transport.(*http.Transport)
+				if transport.TLSClientConfig.GetClientCertificate == nil || len(transport.TLSClientConfig.CipherSuites) != 1 {
+					t.Errorf("NewClient() TLS config = %+v", transport.TLSClientConfig)
+				}
+				return
+			}
+			if err == nil || (!errors.Is(err, tt.wantErr) && !strings.HasPrefix(err.Error(), tt.wantErr.Error())) {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClient_TLSOptionsWithTransport(t *testing.T) {
+	caPEM, _, ca, _ := testCertificate(t, "ca", nil, nil)
+	otherPEM, _, _, _ := testCertificate(t, "other", nil, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, otherPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	transport := &http.Transport{TLSClientConfig: &tls.Config{
+		ServerName: "internal.mesh",
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS13,
+	}}
+
+	c, err := NewClient("https://localhost",
+		WithTransport(transport),
+		WithCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256),
+		WithRootCAFile(caFile))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	config := c.httpClient.Transport.(*http.Transport).TLSClientConfig
+	if config.ServerName != "internal.mesh" || config.MinVersion != tls.VersionTLS13 || len(config.CipherSuites) != 1 {
+		t.Errorf("NewClient() TLS config = %+v", config)
+	}
+	if config.RootCAs == nil || config.RootCAs.Equal(pool) {
+		t.Fatalf("NewClient() RootCAs = %v, want the pool of the transport and the file", config.RootCAs)
+	}
+	if _, err := ca.Verify(x509.VerifyOptions{Roots: config.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("Certificate.Verify() error = %v, want the CA of the transport", err)
+	}
+	// The configuration and the pool of the transport are not modified
+	want := x509.NewCertPool()
+	want.AppendCertsFromPEM(caPEM)
+	if transport.TLSClientConfig.CipherSuites != nil || !pool.Equal(want) {
+		t.Errorf("transport TLS config modified = %+v", transport.TLSClientConfig)
+	}
+}
@@ -0,0 +1,364 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidURITemplate = errors.New("httpclient: invalid uri template")
+
+// routeContextKey is the key to store the route of the request in the context
+type routeContextKey struct{}
+
+// withRoute stores the unexpanded route in the context
+func withRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeContextKey{}, route)
+}
+
+// Route returns the unexpanded path of the request, like /users/{id}/repos{?type,sort}.
+// This label is stable between requests and can be used for metrics and logging.
+// If the request was not created by the client, the path of the URL is returned.
+func Route(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if route, ok := r.Context().Value(routeContextKey{}).(string); ok {
+		return route
+	}
+	if r.URL == nil {
+		return ""
+	}
+	return r.URL.Path
+}
+
+// templateOperator describes the expansion behavior of an expression operator
+// as defined in the appendix A of RFC6570.
+type templateOperator struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var templateOperators = map[byte]templateOperator{
+	0:   {first: "", sep: ","},
+	'+': {first: "", sep: ",", allowReserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+	'#': {first: "#", sep: ",", allowReserved: true},
+}
+
+// templateVar is a varspec of an expression
+type templateVar struct {
+	name    string
+	prefix  int
+	explode bool
+}
+
+// ExpandURITemplate expands the template with the values following the
+// RFC6570 (levels 1 to 4).
+//
+// The values can be:
+//   - a string, a boolean, a number or a fmt.Stringer for a simple value
+//   - a slice or an array for a list
+//   - a map with string keys for an associative array, the keys are sorted
+//
+// For Example:
+//
+//	path, err := httpclient.ExpandURITemplate("/users/{id}/repos{?type,sort}",
+//		map[string]any{"id": "john doe", "type": "owner"})
+//	// path = /users/john%20doe/repos?type=owner
+func ExpandURITemplate(template string, values map[string]any) (string, error) {
+	var b strings.Builder
+	for len(template) > 0 {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			if strings.IndexByte(template, '}') >= 0 {
+				return "", fmt.Errorf("%w: unexpected '}'", ErrInvalidURITemplate)
+			}
+			b.WriteString(encodeTemplateLiteral(template))
+			break
+		}
+		if strings.IndexByte(template[:start], '}') >= 0 {
+			return "", fmt.Errorf("%w: unexpected '}'", ErrInvalidURITemplate)
+		}
+		b.WriteString(encodeTemplateLiteral(template[:start]))
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("%w: unclosed expression", ErrInvalidURITemplate)
+		}
+		if err := expandExpression(&b, template[start+1:start+end], values); err != nil {
+			return "", err
+		}
+		template = template[start+end+1:]
+	}
+	return b.String(), nil
+}
+
+// expandExpression expands one expression without the braces
+func expandExpression(b *strings.Builder, expression string, values map[string]any) error {
+	if expression == "" {
+		return fmt.Errorf("%w: empty expression", ErrInvalidURITemplate)
+	}
+	var op templateOperator
+	switch c := expression[0]; c {
+	case '+', '.', '/', ';', '?', '&', '#':
+		op = templateOperators[c]
+		expression = expression[1:]
+	case '=', ',', '!', '@', '|':
+		return fmt.Errorf("%w: reserved operator %q", ErrInvalidURITemplate, c)
+	default:
+		op = templateOperators[0]
+	}
+
+	first := true
+	for _, spec := range strings.Split(expression, ",") {
+		v, err := parseTemplateVar(spec)
+		if err != nil {
+			return err
+		}
+		value, ok := normalizeTemplateValue(values[v.name])
+		if !ok {
+			continue
+		}
+		if first {
+			b.WriteString(op.first)
+			first = false
+		} else {
+			b.WriteString(op.sep)
+		}
+		switch value := value.(type) {
+		case string:
+			if op.named {
+				b.WriteString(v.name)
+				if value == "" {
+					b.WriteString(op.ifEmpty)
+					continue
+				}
+				b.WriteByte('=')
+			}
+			if v.prefix > 0 {
+				value = truncateRunes(value, v.prefix)
+			}
+			b.WriteString(encodeTemplateValue(value, op.allowReserved))
+		case []string:
+			expandList(b, v, op, value)
+		case [][2]string:
+			expandPairs(b, v, op, value)
+		}
+	}
+	return nil
+}
+
+// expandList expands a list value
+func expandList(b *strings.Builder, v templateVar, op templateOperator, list []string) {
+	if !v.explode {
+		if op.named {
+			b.WriteString(v.name + "=")
+		}
+		for i, item := range list {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(encodeTemplateValue(item, op.allowReserved))
+		}
+		return
+	}
+	for i, item := range list {
+		if i > 0 {
+			b.WriteString(op.sep)
+		}
+		if op.named {
+			b.WriteString(v.name)
+			if item == "" {
+				b.WriteString(op.ifEmpty)
+				continue
+			}
+			b.WriteByte('=')
+		}
+		b.WriteString(encodeTemplateValue(item, op.allowReserved))
+	}
+}
+
+// expandPairs expands an associative array value
+func expandPairs(b *strings.Builder, v templateVar, op templateOperator, pairs [][2]string) {
+	if !v.explode {
+		if op.named {
+			b.WriteString(v.name + "=")
+		}
+		for i, pair := range pairs {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(encodeTemplateValue(pair[0], op.allowReserved))
+			b.WriteByte(',')
+			b.WriteString(encodeTemplateValue(pair[1], op.allowReserved))
+		}
+		return
+	}
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteString(op.sep)
+		}
+		b.WriteString(encodeTemplateValue(pair[0], op.allowReserved))
+		if op.named && pair[1] == "" {
+			b.WriteString(op.ifEmpty)
+			continue
+		}
+		b.WriteByte('=')
+		b.WriteString(encodeTemplateValue(pair[1], op.allowReserved))
+	}
+}
+
+// parseTemplateVar parses a varspec like name, name:3 or name*
+func parseTemplateVar(spec string) (templateVar, error) {
+	v := templateVar{name: spec}
+	if strings.HasSuffix(spec, "*") {
+		v.name = spec[:len(spec)-1]
+		v.explode = true
+	} else if i := strings.IndexByte(spec, ':'); i >= 0 {
+		prefix, err := strconv.Atoi(spec[i+1:])
+		if err != nil || prefix <= 0 || prefix >= 10000 {
+			return v, fmt.Errorf("%w: invalid prefix in %q", ErrInvalidURITemplate, spec)
+		}
+		v.name = spec[:i]
+		v.prefix = prefix
+	}
+	if v.name == "" {
+		return v, fmt.Errorf("%w: empty variable name", ErrInvalidURITemplate)
+	}
+	for i := 0; i < len(v.name); i++ {
+		c := v.name[i]
+		if !isAlphaNum(c) && c != '_' && c != '.' && c != '%' {
+			return v, fmt.Errorf("%w: invalid variable name %q", ErrInvalidURITemplate, v.name)
+		}
+	}
+	return v, nil
+}
+
+// normalizeTemplateValue converts the value into a string, a []string or a [][2]string.
+// It returns false if the value is undefined.
+func normalizeTemplateValue(value any) (any, bool) {
+	switch value := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return value, true
+	case []string:
+		return value, len(value) > 0
+	case fmt.Stringer:
+		// A nil pointer of a fmt.Stringer is undefined, its String method may panic
+		switch rv := reflect.ValueOf(value); rv.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+			if rv.IsNil() {
+				return nil, false
+			}
+		}
+		return value.String(), true
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return nil, false
+		}
+		list := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, fmt.Sprint(rv.Index(i).Interface()))
+		}
+		return list, true
+	case reflect.Map:
+		if rv.Len() == 0 || rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		pairs := make([][2]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, [2]string{iter.Key().String(), fmt.Sprint(iter.Value().Interface())})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+		return pairs, true
+	default:
+		return fmt.Sprint(rv.Interface()), true
+	}
+}
+
+// truncateRunes returns the first n characters of s
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+const upperHex = "0123456789ABCDEF"
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isUnreserved(c byte) bool {
+	return isAlphaNum(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isReserved(c byte) bool {
+	return strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0
+}
+
+func isPctEncoded(s string, i int) bool {
+	return s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2])
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// encodeTemplateValue percent-encodes the value, the reserved characters
+// and the pct-encoded triplets are kept if allowReserved is set.
+func encodeTemplateValue(s string, allowReserved bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case allowReserved && (isReserved(c) || isPctEncoded(s, i)):
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&15])
+		}
+	}
+	return b.String()
+}
+
+// encodeTemplateLiteral encodes the literals of the template
+func encodeTemplateLiteral(s string) string {
+	return encodeTemplateValue(s, true)
+}
@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// templateID is a fmt.Stringer which panics when it's nil
+type templateID struct {
+	value int
+}
+
+func (id *templateID) String() string {
+	return strconv.Itoa(id.value)
+}
+
+func TestExpandURITemplate(t *testing.T) {
+	// Values from the section 3.2 of RFC6570
+	values := map[string]any{
+		"count":      []string{"one", "two", "three"},
+		"dom":        []string{"example", "com"},
+		"dub":        "me/too",
+		"hello":      "Hello World!",
+		"half":       "50%",
+		"var":        "value",
+		"who":        "fred",
+		"base":       "http://example.com/home/",
+		"path":       "/foo/bar",
+		"list":       []string{"red", "green", "blue"},
+		"keys":       map[string]string{"comma": ",", "dot": ".", "semi": ";"},
+		"v":          6,
+		"x":          1024,
+		"y":          768,
+		"empty":      "",
+		"empty_keys": map[string]string{},
+		"undef":      nil,
+		"id":         &templateID{value: 42},
+		"nil_id":     (*templateID)(nil),
+	}
+	tests := []struct {
+		template string
+		want     string
+		wantErr  bool
+	}{
+		// Level 1
+		{template: "{var}", want: "value"},
+		{template: "{hello}", want: "Hello%20World%21"},
+		// Level 2
+		{template: "{+var}", want: "value"},
+		{template: "{+hello}", want: "Hello%20World!"},
+		{template: "{+path}/here", want: "/foo/bar/here"},
+		{template: "here?ref={+path}", want: "here?ref=/foo/bar"},
+		{template: "X{#var}", want: "X#value"},
+		{template: "X{#hello}", want: "X#Hello%20World!"},
+		// Level 3
+		{template: "map?{x,y}", want: "map?1024,768"},
+		{template: "{x,hello,y}", want: "1024,Hello%20World%21,768"},
+		{template: "{+x,hello,y}", want: "1024,Hello%20World!,768"},
+		{template: "{+path,x}/here", want: "/foo/bar,1024/here"},
+		{template: "{#x,hello,y}", want: "#1024,Hello%20World!,768"},
+		{template: "X{.var}", want: "X.value"},
+		{template: "X{.x,y}", want: "X.1024.768"},
+		{template: "{/var}", want: "/value"},
+		{template: "{/var,x}/here", want: "/value/1024/here"},
+		{template: "{;x,y}", want: ";x=1024;y=768"},
+		{template: "{;x,y,empty}", want: ";x=1024;y=768;empty"},
+		{template: "{?x,y}", want: "?x=1024&y=768"},
+		{template: "{?x,y,empty}", want: "?x=1024&y=768&empty="},
+		{template: "?fixed=yes{&x}", want: "?fixed=yes&x=1024"},
+		{template: "{&x,y,empty}", want: "&x=1024&y=768&empty="},
+		// Level 4
+		{template: "{var:3}", want: "val"},
+		{template: "{var:30}", want: "value"},
+		{template: "{list}", want: "red,green,blue"},
+		{template: "{list*}", want: "red,green,blue"},
+		{template: "{keys}", want: "comma,%2C,dot,.,semi,%3B"},
+		{template: "{keys*}", want: "comma=%2C,dot=.,semi=%3B"},
+		{template: "{+path:6}/here", want: "/foo/b/here"},
+		{template: "{+list}", want: "red,green,blue"},
+		{template: "{+keys*}", want: "comma=,,dot=.,semi=;"},
+		{template: "{#keys*}", want: "#comma=,,dot=.,semi=;"},
+		{template: "X{.list*}", want: "X.red.green.blue"},
+		{template: "{/list*,path:4}", want: "/red/green/blue/%2Ffoo"},
+		{template: "{;list*}", want: ";list=red;list=green;list=blue"},
+		{template: "{;keys*}", want: ";comma=%2C;dot=.;semi=%3B"},
+		{template: "{?list}", want: "?list=red,green,blue"},
+		{template: "{?list*}", want: "?list=red&list=green&list=blue"},
+		{template: "{?keys*}", want: "?comma=%2C&dot=.&semi=%3B"},
+		{template: "{&list*}", want: "&list=red&list=green&list=blue"},
+		// Undefined values
+		{template: "{?undef,empty_keys}", want: ""},
+		{template: "/users{/undef}", want: "/users"},
+		{template: "/users{/nil_id}{?nil_id}", want: "/users"},
+		// fmt.Stringer
+		{template: "/users{/id}", want: "/users/42"},
+		// Invalid templates
+		{template: "{var", wantErr: true},
+		{template: "var}", wantErr: true},
+		{template: "{}", wantErr: true},
+		{template: "{=var}", wantErr: true},
+		{template: "{var:abc}", wantErr: true},
+		{template: "{va r}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ExpandURITemplate(tt.template, values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExpandURITemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr && !errors.Is(err, ErrInvalidURITemplate) {
+				t.Errorf("ExpandURITemplate() error = %v, want ErrInvalidURITemplate", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandURITemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Get_WithPathParams(t *testing.T) {
+	var gotURI string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	var route string
+	c := &Client{
+		baseURL:    s.URL,
+		httpClient: &http.Client{},
+		decorators: []Decorator{func(d Doer) Doer {
+			return DoerFunc(func(r *http.Request) (*http.Response, error) {
+				route = Route(r)
+				return d.Do(r)
+			})
+		}},
+	}
+	template := "/users/{id}/repos{?type,sort}"
+	resp, err := c.Get(context.Background(), template, nil, nil,
+		WithPathParams(map[string]any{"id": "a/b c", "type": "owner"}),
+		WithQueries(map[string]string{"page": "2"}))
+	if err != nil {
+		t.Fatalf("Client.Get() error = %v", err)
+	}
+	if want := "/users/a%2Fb%20c/repos?page=2&type=owner"; gotURI != want {
+		t.Errorf("Client.Get() request uri = %v, want %v", gotURI, want)
+	}
+	if route != template {
+		t.Errorf("Route() = %v, want %v", route, template)
+	}
+	if got := Route(resp.Request); got != template {
+		t.Errorf("Route() from the response = %v, want %v", got, template)
+	}
+}
@@ -0,0 +1,1015 @@
+package httpclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Decoder of the Zstandard frames of RFC 8878, without dictionaries
+
+var errZstdCorrupted = errors.New("httpclient: corrupted zstd stream")
+
+const (
+	zstdMagic         = 0xFD2FB528
+	zstdSkippableMask = 0xFFFFFFF0
+	zstdSkippableID   = 0x184D2A50
+	// Larger windows are refused to limit the memory
+	zstdMaxWindowSize = 1 << 27
+	zstdMaxBlockSize  = 1 << 17
+)
+
+// Baselines and numbers of extra bits of the literals length and match length codes
+var (
+	zstdLLBase = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	zstdLLBits = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	zstdMLBase = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	zstdMLBits = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// Predefined distributions of the literals length, match length and offset codes
+var (
+	zstdLLNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	zstdMLNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	zstdOFNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+	zstdLLDefault = mustFSETable(zstdLLNorm, 6)
+	zstdMLDefault = mustFSETable(zstdMLNorm, 6)
+	zstdOFDefault = mustFSETable(zstdOFNorm, 5)
+)
+
+// zstdReader decodes the frames of a zstd stream
+type zstdReader struct {
+	r   io.Reader
+	buf [8]byte
+
+	inFrame    bool
+	frames     int
+	windowSize int
+	checksum   bool
+	hash       xxh64
+
+	// Decoded bytes, the window of the frame followed by the bytes not read yet
+	hist []byte
+	pos  int
+
+	// State kept between the blocks of a frame
+	repeat  [3]int
+	huffman *huffmanTable
+	llTable *fseTable
+	ofTable *fseTable
+	mlTable *fseTable
+
+	block    []byte
+	literals []byte
+	err      error
+}
+
+func newZstdReader(r io.Reader) *zstdReader {
+	return &zstdReader{r: r}
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	for z.pos == len(z.hist) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.hist[z.pos:])
+	z.pos += n
+	return n, nil
+}
+
+// readFull reads n bytes in the buffer of the reader
+func (z *zstdReader) readFull(n int) ([]byte, error) {
+	if _, err := io.ReadFull(z.r, z.buf[:n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return z.buf[:n], nil
+}
+
+// next decodes the next frame header or block
+func (z *zstdReader) next() error {
+	// Only the window is kept, all the bytes are read
+	if len(z.hist) > 2*z.windowSize {
+		drop := len(z.hist) - z.windowSize
+		z.hist = z.hist[:copy(z.hist, z.hist[drop:])]
+		z.pos -= drop
+	}
+	if !z.inFrame {
+		return z.readFrameHeader()
+	}
+
+	header, err := z.readFull(3)
+	if err != nil {
+		return err
+	}
+	h := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+	last, size := h&1 == 1, int(h>>3)
+	start := len(z.hist)
+	switch (h >> 1) & 3 {
+	case 0:
+		if err := z.readBlock(size); err != nil {
+			return err
+		}
+		z.hist = append(z.hist, z.block...)
+	case 1:
+		b, err := z.readFull(1)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			z.hist = append(z.hist, b[0])
+		}
+	case 2:
+		if err := z.readBlock(size); err != nil {
+			return err
+		}
+		if err := z.decodeBlock(z.block); err != nil {
+			return err
+		}
+	default:
+		return errZstdCorrupted
+	}
+	if len(z.hist)-start > zstdMaxBlockSize {
+		return errZstdCorrupted
+	}
+	if z.checksum {
+		z.hash.write(z.hist[start:])
+	}
+
+	if last {
+		z.inFrame = false
+		z.frames++
+		if z.checksum {
+			sum, err := z.readFull(4)
+			if err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint32(sum) != uint32(z.hash.sum()) {
+				return fmt.Errorf("%w: invalid checksum", errZstdCorrupted)
+			}
+		}
+	}
+	return nil
+}
+
+// readBlock reads the content of a block
+func (z *zstdReader) readBlock(size int) error {
+	if size > zstdMaxBlockSize {
+		return errZstdCorrupted
+	}
+	if cap(z.block) < size {
+		z.block = make([]byte, size)
+	}
+	z.block = z.block[:size]
+	_, err := io.ReadFull(z.r, z.block)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readFrameHeader reads the header of the next frame, the skippable frames are ignored
+func (z *zstdReader) readFrameHeader() error {
+	if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+		if err == io.EOF && z.frames > 0 {
+			return io.EOF
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	magic := binary.LittleEndian.Uint32(z.buf[:4])
+	if magic&zstdSkippableMask == zstdSkippableID {
+		size, err := z.readFull(4)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(io.Discard, z.r, int64(binary.LittleEndian.Uint32(size)))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		z.frames++
+		return err
+	}
+	if magic != zstdMagic {
+		return fmt.Errorf("%w: invalid magic number", errZstdCorrupted)
+	}
+
+	b, err := z.readFull(1)
+	if err != nil {
+		return err
+	}
+	descriptor := b[0]
+	if descriptor&0x08 != 0 {
+		return errZstdCorrupted
+	}
+	single := descriptor&0x20 != 0
+	z.checksum = descriptor&0x04 != 0
+
+	windowSize := 0
+	if !single {
+		b, err := z.readFull(1)
+		if err != nil {
+			return err
+		}
+		base := 1 << (10 + b[0]>>3)
+		windowSize = base + base/8*int(b[0]&7)
+	}
+	if size := [4]int{0, 1, 2, 4}[descriptor&3]; size > 0 {
+		id, err := z.readFull(size)
+		if err != nil {
+			return err
+		}
+		for _, b := range id {
+			if b != 0 {
+				return errors.New("httpclient: zstd dictionaries are not supported")
+			}
+		}
+	}
+	fcsSize := [4]int{0, 2, 4, 8}[descriptor>>6]
+	if fcsSize == 0 && single {
+		fcsSize = 1
+	}
+	if fcsSize > 0 {
+		fcs, err := z.readFull(fcsSize)
+		if err != nil {
+			return err
+		}
+		var size uint64
+		for i := len(fcs) - 1; i >= 0; i-- {
+			size = size<<8 | uint64(fcs[i])
+		}
+		if fcsSize == 2 {
+			size += 256
+		}
+		if single {
+			if size > zstdMaxWindowSize {
+				return errors.New("httpclient: zstd window too large")
+			}
+			windowSize = int(size)
+		}
+	}
+	if windowSize > zstdMaxWindowSize {
+		return errors.New("httpclient: zstd window too large")
+	}
+	if windowSize < zstdMaxBlockSize {
+		windowSize = zstdMaxBlockSize
+	}
+
+	// The frames are independent
+	z.hist = z.hist[:0]
+	z.pos = 0
+	z.windowSize = windowSize
+	z.repeat = [3]int{1, 4, 8}
+	z.huffman, z.llTable, z.ofTable, z.mlTable = nil, nil, nil, nil
+	z.hash.reset()
+	z.inFrame = true
+	return nil
+}
+
+// decodeBlock decodes a compressed block
+func (z *zstdReader) decodeBlock(data []byte) error {
+	literals, n, err := z.decodeLiterals(data)
+	if err != nil {
+		return err
+	}
+	return z.decodeSequences(data[n:], literals)
+}
+
+// decodeLiterals returns the literals section and its size
+func (z *zstdReader) decodeLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupted
+	}
+	b0 := int(data[0])
+	format := (b0 >> 2) & 3
+	switch b0 & 3 {
+	case 0, 1:
+		var size, header int
+		switch format {
+		case 0, 2:
+			size, header = b0>>3, 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errZstdCorrupted
+			}
+			size, header = b0>>4|int(data[1])<<4, 2
+		default:
+			if len(data) < 3 {
+				return nil, 0, errZstdCorrupted
+			}
+			size, header = b0>>4|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if b0&3 == 0 {
+			if len(data) < header+size {
+				return nil, 0, errZstdCorrupted
+			}
+			return data[header : header+size], header + size, nil
+		}
+		if len(data) < header+1 {
+			return nil, 0, errZstdCorrupted
+		}
+		z.literals = z.literals[:0]
+		for i := 0; i < size; i++ {
+			z.literals = append(z.literals, data[header])
+		}
+		return z.literals, header + 1, nil
+	}
+
+	var regenerated, compressed, header int
+	streams := 4
+	switch format {
+	case 0, 1:
+		if len(data) < 3 {
+			return nil, 0, errZstdCorrupted
+		}
+		if format == 0 {
+			streams = 1
+		}
+		c := b0 | int(data[1])<<8 | int(data[2])<<16
+		regenerated, compressed, header = (c>>4)&0x3FF, (c>>14)&0x3FF, 3
+	case 2:
+		if len(data) < 4 {
+			return nil, 0, errZstdCorrupted
+		}
+		c := int(binary.LittleEndian.Uint32(data))
+		regenerated, compressed, header = (c>>4)&0x3FFF, (c>>18)&0x3FFF, 4
+	default:
+		if len(data) < 5 {
+			return nil, 0, errZstdCorrupted
+		}
+		c := int(binary.LittleEndian.Uint32(data)) | int(data[4])<<32
+		regenerated, compressed, header = (c>>4)&0x3FFFF, (c>>22)&0x3FFFF, 5
+	}
+	if len(data) < header+compressed || regenerated > zstdMaxBlockSize {
+		return nil, 0, errZstdCorrupted
+	}
+	src := data[header : header+compressed]
+	if b0&3 == 2 {
+		table, n, err := readHuffmanTable(src)
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huffman = table
+		src = src[n:]
+	} else if z.huffman == nil {
+		return nil, 0, errZstdCorrupted
+	}
+	literals, err := z.huffman.decode(z.literals[:0], src, regenerated, streams)
+	if err != nil {
+		return nil, 0, err
+	}
+	z.literals = literals
+	return literals, header + compressed, nil
+}
+
+// decodeSequences executes the sequences of the block with the literals
+func (z *zstdReader) decodeSequences(data []byte, literals []byte) error {
+	if len(data) == 0 {
+		return errZstdCorrupted
+	}
+	count, n := int(data[0]), 1
+	switch {
+	case count >= 255:
+		if len(data) < 3 {
+			return errZstdCorrupted
+		}
+		count, n = int(data[1])+int(data[2])<<8+0x7F00, 3
+	case count >= 128:
+		if len(data) < 2 {
+			return errZstdCorrupted
+		}
+		count, n = (count-128)<<8+int(data[1]), 2
+	}
+	data = data[n:]
+	if count == 0 {
+		z.hist = append(z.hist, literals...)
+		return nil
+	}
+
+	if len(data) == 0 || data[0]&3 != 0 {
+		return errZstdCorrupted
+	}
+	modes := data[0]
+	data = data[1:]
+	ll, err := sequenceTable(&data, modes>>6, &z.llTable, zstdLLDefault, 35, 9)
+	if err != nil {
+		return err
+	}
+	of, err := sequenceTable(&data, (modes>>4)&3, &z.ofTable, zstdOFDefault, 31, 8)
+	if err != nil {
+		return err
+	}
+	ml, err := sequenceTable(&data, (modes>>2)&3, &z.mlTable, zstdMLDefault, 52, 9)
+	if err != nil {
+		return err
+	}
+
+	br, err := newReverseBitReader(data)
+	if err != nil {
+		return err
+	}
+	llState := br.read(uint(ll.log))
+	ofState := br.read(uint(of.log))
+	mlState := br.read(uint(ml.log))
+	for i := 0; i < count; i++ {
+		llEntry, ofEntry, mlEntry := ll.entries[llState], of.entries[ofState], ml.entries[mlState]
+		if llEntry.symbol > 35 || ofEntry.symbol > 31 || mlEntry.symbol > 52 {
+			return errZstdCorrupted
+		}
+		offsetValue := int(1)<<ofEntry.symbol + int(br.read(uint(ofEntry.symbol)))
+		matchLength := int(zstdMLBase[mlEntry.symbol]) + int(br.read(uint(zstdMLBits[mlEntry.symbol])))
+		literalsLength := int(zstdLLBase[llEntry.symbol]) + int(br.read(uint(zstdLLBits[llEntry.symbol])))
+
+		// Repeated offsets of RFC 8878 section 3.1.2.5
+		var offset int
+		if offsetValue > 3 {
+			offset = offsetValue - 3
+			z.repeat = [3]int{offset, z.repeat[0], z.repeat[1]}
+		} else {
+			index := offsetValue - 1
+			if literalsLength == 0 {
+				index++
+			}
+			switch index {
+			case 0:
+				offset = z.repeat[0]
+			case 3:
+				offset = z.repeat[0] - 1
+			default:
+				offset = z.repeat[index]
+			}
+			if index == 1 {
+				z.repeat = [3]int{offset, z.repeat[0], z.repeat[2]}
+			} else if index > 1 {
+				z.repeat = [3]int{offset, z.repeat[0], z.repeat[1]}
+			}
+		}
+
+		if i < count-1 {
+			llState = int(llEntry.base) + int(br.read(uint(llEntry.bits)))
+			mlState = int(mlEntry.base) + int(br.read(uint(mlEntry.bits)))
+			ofState = int(ofEntry.base) + int(br.read(uint(ofEntry.bits)))
+		}
+
+		if literalsLength > len(literals) {
+			return errZstdCorrupted
+		}
+		z.hist = append(z.hist, literals[:literalsLength]...)
+		literals = literals[literalsLength:]
+		if offset <= 0 || offset > len(z.hist) || offset > z.windowSize {
+			return fmt.Errorf("%w: invalid offset", errZstdCorrupted)
+		}
+		start := len(z.hist) - offset
+		if offset >= matchLength {
+			z.hist = append(z.hist, z.hist[start:start+matchLength]...)
+		} else {
+			// The match overlaps the bytes it writes
+			for j := 0; j < matchLength; j++ {
+				z.hist = append(z.hist, z.hist[start+j])
+			}
+		}
+	}
+	z.hist = append(z.hist, literals...)
+	if !br.finished() {
+		return errZstdCorrupted
+	}
+	return nil
+}
+
+// sequenceTable reads the FSE table of a code in the mode of the block
+func sequenceTable(data *[]byte, mode byte, previous **fseTable, predefined *fseTable, maxSymbol int, maxLog int) (*fseTable, error) {
+	var table *fseTable
+	switch mode {
+	case 0:
+		table = predefined
+	case 1:
+		if len(*data) == 0 || int((*data)[0]) > maxSymbol {
+			return nil, errZstdCorrupted
+		}
+		table = &fseTable{entries: []fseEntry{{symbol: (*data)[0]}}}
+		*data = (*data)[1:]
+	case 2:
+		var (
+			n   int
+			err error
+		)
+		table, n, err = readFSETable(*data, maxSymbol, maxLog)
+		if err != nil {
+			return nil, err
+		}
+		*data = (*data)[n:]
+	default:
+		if *previous == nil {
+			return nil, errZstdCorrupted
+		}
+		table = *previous
+	}
+	*previous = table
+	return table, nil
+}
+
+// fseEntry is a state of a FSE decoding table
+type fseEntry struct {
+	symbol uint8
+	bits   uint8
+	base   uint16
+}
+
+// fseTable is a FSE decoding table
+type fseTable struct {
+	log     uint8
+	entries []fseEntry
+}
+
+func mustFSETable(norm []int16, log uint8) *fseTable {
+	table, err := buildFSETable(norm, log)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+// readFSETable reads the description of a FSE table and returns its size
+func readFSETable(data []byte, maxSymbol int, maxLog int) (*fseTable, int, error) {
+	fr := &forwardBitReader{data: data}
+	log := int(fr.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, errZstdCorrupted
+	}
+	norm := make([]int16, maxSymbol+1)
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := uint(log + 1)
+	symbol := 0
+	previousZero := false
+	for remaining > 1 && symbol <= maxSymbol {
+		if previousZero {
+			// Repeat flags of the symbols with a zero probability
+			n := symbol
+			for {
+				repeat := int(fr.read(2))
+				n += repeat
+				if repeat != 3 {
+					break
+				}
+			}
+			if n > maxSymbol {
+				return nil, 0, errZstdCorrupted
+			}
+			symbol = n
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int
+		if low := int(fr.peek(nbBits - 1)); low < max {
+			count = low
+			fr.skip(nbBits - 1)
+		} else {
+			count = int(fr.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			fr.skip(nbBits)
+		}
+		count--
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		if remaining < 1 {
+			return nil, 0, errZstdCorrupted
+		}
+		norm[symbol] = int16(count)
+		symbol++
+		previousZero = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || fr.pos > uint(len(data))*8 {
+		return nil, 0, errZstdCorrupted
+	}
+	table, err := buildFSETable(norm[:symbol], uint8(log))
+	return table, int((fr.pos + 7) / 8), err
+}
+
+// buildFSETable builds the decoding table of the normalized probabilities
+func buildFSETable(norm []int16, log uint8) (*fseTable, error) {
+	size := 1 << log
+	entries := make([]fseEntry, size)
+	next := make([]int, len(norm))
+	high := size - 1
+	for s, count := range norm {
+		if count == -1 {
+			entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = int(count)
+		}
+	}
+	position, step, mask := 0, size>>1+size>>3+3, size-1
+	for s, count := range norm {
+		for i := 0; i < int(count); i++ {
+			entries[position].symbol = uint8(s)
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+	if position != 0 {
+		return nil, errZstdCorrupted
+	}
+	for u := range entries {
+		state := next[entries[u].symbol]
+		next[entries[u].symbol]++
+		if state == 0 {
+			return nil, errZstdCorrupted
+		}
+		nb := int(log) - (bits.Len(uint(state)) - 1)
+		entries[u].bits = uint8(nb)
+		entries[u].base = uint16(state<<nb - size)
+	}
+	return &fseTable{log: log, entries: entries}, nil
+}
+
+// huffmanEntry is an entry of a Huffman decoding table
+type huffmanEntry struct {
+	symbol byte
+	bits   uint8
+}
+
+// huffmanTable is a Huffman decoding table indexed by the next maxBits bits
+type huffmanTable struct {
+	maxBits uint8
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads the description of a Huffman table and returns its size
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errZstdCorrupted
+	}
+	var (
+		weights []byte
+		n       int
+	)
+	if header := int(data[0]); header >= 128 {
+		count := header - 127
+		n = 1 + (count+1)/2
+		if len(data) < n {
+			return nil, 0, errZstdCorrupted
+		}
+		for i := 0; i < count; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, b>>4)
+			} else {
+				weights = append(weights, b&15)
+			}
+		}
+	} else {
+		n = 1 + header
+		if len(data) < n {
+			return nil, 0, errZstdCorrupted
+		}
+		var err error
+		if weights, err = decodeHuffmanWeights(data[1:n]); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The weight of the last symbol completes the sum to a power of 2
+	sum := 0
+	for _, w := range weights {
+		if w > 11 {
+			return nil, 0, errZstdCorrupted
+		}
+		if w > 0 {
+			sum += 1 << (w - 1)
+		}
+	}
+	if sum == 0 || len(weights) > 255 {
+		return nil, 0, errZstdCorrupted
+	}
+	maxBits := bits.Len(uint(sum))
+	rest := 1<<maxBits - sum
+	if rest&(rest-1) != 0 || maxBits > 11 {
+		return nil, 0, errZstdCorrupted
+	}
+	weights = append(weights, byte(bits.Len(uint(rest))))
+
+	var counts [13]int
+	for _, w := range weights {
+		counts[w]++
+	}
+	var starts [13]int
+	for w, position := 1, 0; w <= maxBits; w++ {
+		starts[w] = position
+		position += counts[w] << (w - 1)
+	}
+	table := &huffmanTable{maxBits: uint8(maxBits), entries: make([]huffmanEntry, 1<<maxBits)}
+	for s, w := range weights {
+		if w == 0 {
+			continue
+		}
+		length := 1 << (w - 1)
+		for i := starts[w]; i < starts[w]+length; i++ {
+			table.entries[i] = huffmanEntry{symbol: byte(s), bits: uint8(maxBits + 1 - int(w))}
+		}
+		starts[w] += length
+	}
+	return table, n, nil
+}
+
+// decodeHuffmanWeights decodes the weights compressed with FSE
+func decodeHuffmanWeights(data []byte) ([]byte, error) {
+	table, n, err := readFSETable(data, 15, 6)
+	if err != nil {
+		return nil, err
+	}
+	br, err := newReverseBitReader(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	// Two interleaved states until the bitstream overflows
+	states := [2]int{int(br.read(uint(table.log))), int(br.read(uint(table.log)))}
+	var weights []byte
+	for i := 0; ; i = 1 - i {
+		if len(weights) > 255 {
+			return nil, errZstdCorrupted
+		}
+		entry := table.entries[states[i]]
+		weights = append(weights, entry.symbol)
+		states[i] = int(entry.base) + int(br.read(uint(entry.bits)))
+		if br.overflow {
+			weights = append(weights, table.entries[states[1-i]].symbol)
+			return weights, nil
+		}
+	}
+}
+
+// decode appends the decoded streams to dst
+func (t *huffmanTable) decode(dst []byte, data []byte, regenerated int, streams int) ([]byte, error) {
+	if streams == 1 {
+		return t.decodeStream(dst, data, regenerated)
+	}
+	if len(data) < 6 {
+		return nil, errZstdCorrupted
+	}
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(data)),
+		int(binary.LittleEndian.Uint16(data[2:])),
+		int(binary.LittleEndian.Uint16(data[4:])),
+	}
+	sizes[3] = len(data) - 6 - sizes[0] - sizes[1] - sizes[2]
+	quarter := (regenerated + 3) / 4
+	if sizes[3] < 0 || regenerated < 3*quarter {
+		return nil, errZstdCorrupted
+	}
+	data = data[6:]
+	var err error
+	for i, size := range sizes {
+		n := quarter
+		if i == 3 {
+			n = regenerated - 3*quarter
+		}
+		if dst, err = t.decodeStream(dst, data[:size], n); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+	}
+	return dst, nil
+}
+
+// decodeStream appends n symbols of the stream to dst
+func (t *huffmanTable) decodeStream(dst []byte, data []byte, n int) ([]byte, error) {
+	br, err := newReverseBitReader(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		entry := t.entries[br.peek(uint(t.maxBits))]
+		dst = append(dst, entry.symbol)
+		br.skip(uint(entry.bits))
+	}
+	if !br.finished() {
+		return nil, errZstdCorrupted
+	}
+	return dst, nil
+}
+
+// reverseBitReader reads a bitstream from its end, the last byte
+// starts with a padding of zeros and a bit at 1
+type reverseBitReader struct {
+	data []byte
+	// off is the index of the next byte to load
+	off int
+	// value has the bits not read, the next bit is the most significant
+	value    uint64
+	bits     uint
+	overflow bool
+}
+
+func newReverseBitReader(data []byte) (*reverseBitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errZstdCorrupted
+	}
+	last := data[len(data)-1]
+	h := uint(bits.Len8(last) - 1)
+	return &reverseBitReader{data: data, off: len(data) - 2, value: uint64(last) & (1<<h - 1), bits: h}, nil
+}
+
+func (b *reverseBitReader) fill() {
+	for b.bits <= 56 && b.off >= 0 {
+		b.value = b.value<<8 | uint64(b.data[b.off])
+		b.bits += 8
+		b.off--
+	}
+}
+
+// peek returns the next n bits, completed with zeros at the end of the stream
+func (b *reverseBitReader) peek(n uint) int {
+	b.fill()
+	if n <= b.bits {
+		return int(b.value>>(b.bits-n)) & (1<<n - 1)
+	}
+	return int(b.value<<(n-b.bits)) & (1<<n - 1)
+}
+
+func (b *reverseBitReader) skip(n uint) {
+	if n > b.bits {
+		b.overflow = true
+		b.value, b.bits = 0, 0
+		return
+	}
+	b.bits -= n
+	b.value &= 1<<b.bits - 1
+}
+
+func (b *reverseBitReader) read(n uint) int {
+	if n == 0 {
+		return 0
+	}
+	v := b.peek(n)
+	b.skip(n)
+	return v
+}
+
+// finished returns true if all the bits are read
+func (b *reverseBitReader) finished() bool {
+	return b.off < 0 && b.bits == 0 && !b.overflow
+}
+
+// forwardBitReader reads a bitstream from its start, the least significant bits first
+type forwardBitReader struct {
+	data []byte
+	pos  uint
+}
+
+// peek returns the next n bits, completed with zeros at the end of the stream
+func (f *forwardBitReader) peek(n uint) uint32 {
+	var v uint32
+	for i := uint(0); i < n; i++ {
+		p := f.pos + i
+		if p/8 < uint(len(f.data)) && f.data[p/8]>>(p%8)&1 == 1 {
+			v |= 1 << i
+		}
+	}
+	return v
+}
+
+func (f *forwardBitReader) skip(n uint) {
+	f.pos += n
+}
+
+func (f *forwardBitReader) read(n uint) uint32 {
+	v := f.peek(n)
+	f.skip(n)
+	return v
+}
+
+// xxh64 is the XXH64 hash with a zero seed of the checksum of the frames
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+func (h *xxh64) reset() {
+	prime1, prime2 := xxhPrime1, xxhPrime2
+	*h = xxh64{v: [4]uint64{prime1 + prime2, prime2, 0, -prime1}}
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*xxhPrime2, 31) * xxhPrime1
+}
+
+func (h *xxh64) stripe(b []byte) {
+	for i := range h.v {
+		h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (h *xxh64) write(p []byte) {
+	h.total += uint64(len(p))
+	if h.n+len(p) < 32 {
+		h.n += copy(h.mem[h.n:], p)
+		return
+	}
+	if h.n > 0 {
+		p = p[copy(h.mem[h.n:], p):]
+		h.stripe(h.mem[:])
+		h.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		h.stripe(p)
+	}
+	h.n = copy(h.mem[:], p)
+}
+
+func (h *xxh64) sum() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = (acc^xxhRound(0, v))*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		acc = h.v[2] + xxhPrime5
+	}
+	acc += h.total
+
+	p := h.mem[:h.n]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		acc ^= uint64(b) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,475 @@
+package httpclient
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Encoder of zstd frames, the matches are found with a hash table and the
+// sequences are coded with the predefined distributions of RFC 8878
+
+const (
+	zstdHashLog  = 16
+	zstdMinMatch = 4
+	// Window of the frames larger than it, the decoders refuse the windows
+	// larger than 8 MiB by default
+	zstdWindowLog = 23
+	// The matches are in the window
+	zstdMaxOffset = 1 << zstdWindowLog
+)
+
+var (
+	zstdLLEncoder = newFSEEncoder(zstdLLDefault, zstdLLNorm)
+	zstdMLEncoder = newFSEEncoder(zstdMLDefault, zstdMLNorm)
+	zstdOFEncoder = newFSEEncoder(zstdOFDefault, zstdOFNorm)
+)
+
+// zstdSequence is a copy of literals followed by a match
+type zstdSequence struct {
+	literals int
+	match    int
+	offset   int
+}
+
+// zstdEncode returns the data compressed in a single frame with its checksum
+func zstdEncode(src []byte) []byte {
+	dst := make([]byte, 0, len(src)/2+32)
+	dst = binary.LittleEndian.AppendUint32(dst, zstdMagic)
+
+	// Single segment frame with the content size, the window is the content
+	// so the larger ones have a window descriptor
+	size := uint64(len(src))
+	switch {
+	case size > 1<<zstdWindowLog && size <= math.MaxUint32:
+		dst = append(dst, 0x84, (zstdWindowLog-10)<<3)
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(size))
+	case size > 1<<zstdWindowLog:
+		dst = append(dst, 0xC4, (zstdWindowLog-10)<<3)
+		dst = binary.LittleEndian.AppendUint64(dst, size)
+	case size < 256:
+		dst = append(dst, 0x24, byte(size))
+	case size < 256+1<<16:
+		dst = append(dst, 0x64)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(size-256))
+	default:
+		dst = append(dst, 0xA4)
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(size))
+	}
+
+	table := make([]int32, 1<<zstdHashLog)
+	for start := 0; ; {
+		end := start + zstdMaxBlockSize
+		if end > len(src) {
+			end = len(src)
+		}
+		dst = zstdEncodeBlock(dst, src, start, end, end == len(src), table)
+		if start = end; start == len(src) {
+			break
+		}
+	}
+
+	var h xxh64
+	h.reset()
+	h.write(src)
+	return binary.LittleEndian.AppendUint32(dst, uint32(h.sum()))
+}
+
+// zstdEncodeBlock appends the block of src[start:end], compressed if it's smaller
+func zstdEncodeBlock(dst []byte, src []byte, start, end int, last bool, table []int32) []byte {
+	literals, sequences := zstdFindSequences(src, start, end, table)
+	block := zstdEncodeHuffmanLiterals(nil, literals)
+	block = zstdEncodeSequences(block, sequences)
+
+	header := uint32(0)
+	if last {
+		header = 1
+	}
+	if len(block) >= end-start {
+		header |= uint32(end-start) << 3
+		dst = append(dst, byte(header), byte(header>>8), byte(header>>16))
+		return append(dst, src[start:end]...)
+	}
+	header |= 2<<1 | uint32(len(block))<<3
+	dst = append(dst, byte(header), byte(header>>8), byte(header>>16))
+	return append(dst, block...)
+}
+
+// zstdFindSequences returns the literals and the sequences of a block with a greedy
+// search of the matches, the table has the last positions of the hashes plus one
+func zstdFindSequences(src []byte, start, end int, table []int32) ([]byte, []zstdSequence) {
+	var (
+		literals  []byte
+		sequences []zstdSequence
+	)
+	hash := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:]) * 2654435761 >> (32 - zstdHashLog)
+	}
+	anchor := start
+	for i := start; i+zstdMinMatch <= end; {
+		h := hash(i)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || i-candidate > zstdMaxOffset ||
+			binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			// The search is faster in the data without matches
+			i += 1 + (i-anchor)>>6
+			continue
+		}
+		n := zstdMinMatch
+		for i+n < end && src[candidate+n] == src[i+n] {
+			n++
+		}
+		literals = append(literals, src[anchor:i]...)
+		sequences = append(sequences, zstdSequence{literals: i - anchor, match: n, offset: i - candidate})
+		for j := i + 1; j < i+n && j+zstdMinMatch <= end; j++ {
+			table[hash(j)] = int32(j + 1)
+		}
+		i += n
+		anchor = i
+	}
+	return append(literals, src[anchor:end]...), sequences
+}
+
+// zstdEncodeLiterals appends a raw literals section
+func zstdEncodeLiterals(dst []byte, literals []byte) []byte {
+	size := len(literals)
+	switch {
+	case size < 32:
+		dst = append(dst, byte(size<<3))
+	case size < 4096:
+		dst = append(dst, byte(size<<4|1<<2), byte(size>>4))
+	default:
+		dst = append(dst, byte(size<<4|3<<2), byte(size>>4), byte(size>>12))
+	}
+	return append(dst, literals...)
+}
+
+// zstdEncodeHuffmanLiterals appends a literals section compressed with a Huffman code,
+// or a RLE or raw literals section when it's smaller
+func zstdEncodeHuffmanLiterals(dst []byte, literals []byte) []byte {
+	var counts [256]int
+	maxSymbol := 0
+	for _, b := range literals {
+		counts[b]++
+		if int(b) > maxSymbol {
+			maxSymbol = int(b)
+		}
+	}
+	if len(literals) > 0 && counts[literals[0]] == len(literals) {
+		size := len(literals)
+		switch {
+		case size < 32:
+			return append(dst, byte(size<<3|1), literals[0])
+		case size < 4096:
+			return append(dst, byte(size<<4|1<<2|1), byte(size>>4), literals[0])
+		}
+		return append(dst, byte(size<<4|3<<2|1), byte(size>>4), byte(size>>12), literals[0])
+	}
+	// The weights are sent without compression, up to 128 of them
+	if len(literals) < 64 || maxSymbol > 128 {
+		return zstdEncodeLiterals(dst, literals)
+	}
+
+	lengths := huffmanLengths(counts[:maxSymbol+1], 11)
+	maxBits := 0
+	for _, l := range lengths {
+		if int(l) > maxBits {
+			maxBits = int(l)
+		}
+	}
+	// Codes of the decoding table of readHuffmanTable, by weight then by symbol
+	var starts [13]int
+	for _, l := range lengths {
+		if l > 0 {
+			starts[maxBits+1-int(l)] += 1 << (maxBits - int(l))
+		}
+	}
+	for w, position := 1, 0; w <= maxBits; w++ {
+		starts[w], position = position, position+starts[w]
+	}
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l > 0 {
+			w := maxBits + 1 - int(l)
+			codes[s] = uint16(starts[w] >> (maxBits - int(l)))
+			starts[w] += 1 << (maxBits - int(l))
+		}
+	}
+
+	// Weights of the symbols but the last one, two by byte
+	tree := []byte{byte(127 + maxSymbol)}
+	for s := 0; s < maxSymbol; s += 2 {
+		b := byte(0)
+		if lengths[s] > 0 {
+			b = byte(maxBits+1-int(lengths[s])) << 4
+		}
+		if s+1 < maxSymbol && lengths[s+1] > 0 {
+			b |= byte(maxBits + 1 - int(lengths[s+1]))
+		}
+		tree = append(tree, b)
+	}
+
+	// The symbols are written from the last one, the decoder reads the stream from its end
+	stream := func(dst []byte, literals []byte) []byte {
+		w := &zstdBitWriter{dst: dst}
+		for i := len(literals) - 1; i >= 0; i-- {
+			w.add(uint64(codes[literals[i]]), uint(lengths[literals[i]]))
+		}
+		return w.close()
+	}
+	compressed := tree
+	streams := 1
+	if len(literals) < 1024 {
+		compressed = stream(compressed, literals)
+	} else {
+		streams = 4
+		quarter := (len(literals) + 3) / 4
+		jump := len(compressed)
+		compressed = append(compressed, make([]byte, 6)...)
+		for i := 0; i < 4; i++ {
+			start, end := i*quarter, (i+1)*quarter
+			if end > len(literals) {
+				end = len(literals)
+			}
+			size := len(compressed)
+			compressed = stream(compressed, literals[start:end])
+			if size = len(compressed) - size; i < 3 {
+				if size > math.MaxUint16 {
+					return zstdEncodeLiterals(dst, literals)
+				}
+				binary.LittleEndian.PutUint16(compressed[jump+2*i:], uint16(size))
+			}
+		}
+	}
+
+	regenerated, size := len(literals), len(compressed)
+	switch {
+	case streams == 1 && size < 1024:
+		dst = append(dst, byte(2|regenerated<<4), byte(regenerated>>4|size<<6), byte(size>>2))
+	case regenerated < 1024 && size < 1024:
+		dst = append(dst, byte(2|1<<2|regenerated<<4), byte(regenerated>>4|size<<6), byte(size>>2))
+	case regenerated < 16384 && size < 16384:
+		dst = append(dst, byte(2|2<<2|regenerated<<4), byte(regenerated>>4), byte(regenerated>>12|size<<2), byte(size>>6))
+	default:
+		dst = append(dst, byte(2|3<<2|regenerated<<4), byte(regenerated>>4), byte(regenerated>>12|size<<6), byte(size>>2), byte(size>>10))
+	}
+	if len(dst)+size >= len(literals)+3 {
+		return zstdEncodeLiterals(dst[:0], literals)
+	}
+	return append(dst, compressed...)
+}
+
+// huffmanLengths returns the lengths of a complete Huffman code of the symbols
+// with a count, limited to maxBits
+func huffmanLengths(counts []int, maxBits int) []uint8 {
+	type node struct {
+		count       int
+		left, right int
+	}
+	var nodes []node
+	for s, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, left: -1, right: s})
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+
+	// Two queues, the leaves and the internal nodes created by increasing counts
+	leaves := len(nodes)
+	nextLeaf, nextNode := 0, leaves
+	smallest := func() int {
+		if nextLeaf < leaves && (nextNode >= len(nodes) || nodes[nextLeaf].count <= nodes[nextNode].count) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextNode++
+		return nextNode - 1
+	}
+	for i := 1; i < leaves; i++ {
+		a, b := smallest(), smallest()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, left: a, right: b})
+	}
+
+	lengths := make([]uint8, len(counts))
+	depths := make([]int, len(nodes))
+	for i := len(nodes) - 1; i >= leaves; i-- {
+		depths[nodes[i].left] = depths[i] + 1
+		depths[nodes[i].right] = depths[i] + 1
+	}
+	symbols := make([]int, leaves)
+	for i := 0; i < leaves; i++ {
+		symbols[i] = nodes[i].right
+		if depths[i] > maxBits {
+			depths[i] = maxBits
+		}
+		lengths[nodes[i].right] = uint8(depths[i])
+	}
+
+	// The limited lengths are fixed to complete the code, the leaves are sorted by
+	// increasing counts so by decreasing lengths
+	kraft, total := 0, 1<<maxBits
+	for _, s := range symbols {
+		kraft += 1 << (maxBits - int(lengths[s]))
+	}
+	for kraft > total {
+		for i := range symbols {
+			if l := int(lengths[symbols[i]]); l < maxBits {
+				lengths[symbols[i]]++
+				kraft -= 1 << (maxBits - l - 1)
+				break
+			}
+		}
+	}
+	for i := 0; kraft < total; i = (i + 1) % leaves {
+		if l := int(lengths[symbols[i]]); l > 1 && kraft+1<<(maxBits-l) <= total {
+			lengths[symbols[i]]--
+			kraft += 1 << (maxBits - l)
+		}
+	}
+	return lengths
+}
+
+// zstdEncodeSequences appends the sequences section coded with the predefined distributions
+func zstdEncodeSequences(dst []byte, sequences []zstdSequence) []byte {
+	n := len(sequences)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7F00:
+		dst = append(dst, byte(n>>8+128), byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+	dst = append(dst, 0)
+
+	// The sequences are coded from the last one, the decoder reads the bitstream from its end
+	type codes struct {
+		ll, ml, of int
+	}
+	codesOf := func(s zstdSequence) codes {
+		ll := s.literals
+		if ll >= 16 {
+			ll = 35
+			for int(zstdLLBase[ll]) > s.literals {
+				ll--
+			}
+		}
+		ml := 52
+		for int(zstdMLBase[ml]) > s.match {
+			ml--
+		}
+		return codes{ll: ll, ml: ml, of: bits.Len(uint(s.offset+3)) - 1}
+	}
+	w := &zstdBitWriter{dst: dst}
+	extra := func(s zstdSequence, c codes) {
+		w.add(uint64(s.literals-int(zstdLLBase[c.ll])), uint(zstdLLBits[c.ll]))
+		w.add(uint64(s.match-int(zstdMLBase[c.ml])), uint(zstdMLBits[c.ml]))
+		w.add(uint64(s.offset+3-1<<c.of), uint(c.of))
+	}
+
+	c := codesOf(sequences[n-1])
+	llState := zstdLLEncoder.init(c.ll)
+	mlState := zstdMLEncoder.init(c.ml)
+	ofState := zstdOFEncoder.init(c.of)
+	extra(sequences[n-1], c)
+	for i := n - 2; i >= 0; i-- {
+		c := codesOf(sequences[i])
+		ofState = zstdOFEncoder.encode(w, ofState, c.of)
+		mlState = zstdMLEncoder.encode(w, mlState, c.ml)
+		llState = zstdLLEncoder.encode(w, llState, c.ll)
+		extra(sequences[i], c)
+	}
+	w.add(uint64(mlState), uint(zstdMLEncoder.log))
+	w.add(uint64(ofState), uint(zstdOFEncoder.log))
+	w.add(uint64(llState), uint(zstdLLEncoder.log))
+	return w.close()
+}
+
+// fseEncoder is the FSE encoding table of a distribution
+type fseEncoder struct {
+	log    uint8
+	states []uint32
+	// Per symbol, the number of bits of the states and the first state in states
+	deltaBits  []uint32
+	deltaState []int
+}
+
+// newFSEEncoder returns the encoding table of the decoding table of the distribution
+func newFSEEncoder(table *fseTable, norm []int16) *fseEncoder {
+	size := 1 << table.log
+	e := &fseEncoder{
+		log:        table.log,
+		states:     make([]uint32, size),
+		deltaBits:  make([]uint32, len(norm)),
+		deltaState: make([]int, len(norm)),
+	}
+	cumul := make([]int, len(norm)+1)
+	for s, count := range norm {
+		if count == -1 {
+			count = 1
+		}
+		cumul[s+1] = cumul[s] + int(count)
+	}
+	next := append([]int(nil), cumul...)
+	for u, entry := range table.entries {
+		e.states[next[entry.symbol]] = uint32(size + u)
+		next[entry.symbol]++
+	}
+	for s, count := range norm {
+		switch {
+		case count == -1 || count == 1:
+			e.deltaBits[s] = uint32(table.log)<<16 - uint32(size)
+			e.deltaState[s] = cumul[s] - 1
+		case count > 1:
+			maxBits := uint32(table.log) - uint32(bits.Len(uint(count-1))-1)
+			e.deltaBits[s] = maxBits<<16 - uint32(count)<<maxBits
+			e.deltaState[s] = cumul[s] - int(count)
+		}
+	}
+	return e
+}
+
+// init returns the first state of the encoding with the symbol
+func (e *fseEncoder) init(symbol int) uint32 {
+	nbBits := (e.deltaBits[symbol] + 1<<15) >> 16
+	value := nbBits<<16 - e.deltaBits[symbol]
+	return e.states[int(value>>nbBits)+e.deltaState[symbol]]
+}
+
+// encode writes the bits of the state and returns the state of the symbol
+func (e *fseEncoder) encode(w *zstdBitWriter, state uint32, symbol int) uint32 {
+	nbBits := (state + e.deltaBits[symbol]) >> 16
+	w.add(uint64(state), uint(nbBits))
+	return e.states[int(state>>nbBits)+e.deltaState[symbol]]
+}
+
+// zstdBitWriter writes a bitstream read from its end by a reverseBitReader
+type zstdBitWriter struct {
+	dst   []byte
+	value uint64
+	bits  uint
+}
+
+func (w *zstdBitWriter) add(v uint64, n uint) {
+	w.value |= (v & (1<<n - 1)) << w.bits
+	w.bits += n
+	for w.bits >= 8 {
+		w.dst = append(w.dst, byte(w.value))
+		w.value >>= 8
+		w.bits -= 8
+	}
+}
+
+// close appends the bit at 1 before the padding of the last byte
+func (w *zstdBitWriter) close() []byte {
+	w.add(1, 1)
+	if w.bits > 0 {
+		w.dst = append(w.dst, byte(w.value))
+	}
+	return w.dst
+}